@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
@@ -15,6 +17,7 @@ import (
 	"github.com/sarulabs/di/v2"
 	"github.com/zekurio/daemon/internal/services/config"
 	"github.com/zekurio/daemon/internal/services/database"
+	"github.com/zekurio/daemon/internal/services/database/dberr"
 	"github.com/zekurio/daemon/internal/services/permissions"
 	"github.com/zekurio/daemon/internal/util/embedded"
 	"github.com/zekurio/daemon/internal/util/static"
@@ -34,11 +37,36 @@ func main() {
 		log.SetLevel(log.DebugLevel)
 	}
 
+	if flag.Arg(0) == "migrate" {
+		err := runMigrate(flag.Args()[1:])
+		if err == errMigrateUsage {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			os.Exit(2)
+		} else if err != nil {
+			log.With(err).Fatal("Migration failed")
+		}
+		return
+	}
+
 	diBuilder, err := di.NewBuilder()
 	if err != nil {
 		log.With(err).Fatal("Failed to create DI builder")
 	}
 
+	// Root context, canceled on shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err = diBuilder.Add(di.Def{
+		Name: static.DiContext,
+		Build: func(ctn di.Container) (interface{}, error) {
+			return ctx, nil
+		},
+	})
+	if err != nil {
+		log.With(err).Fatal("Context creation failed")
+	}
+
 	// Config
 	err = diBuilder.Add(di.Def{
 		Name: static.DiConfig,
@@ -50,6 +78,17 @@ func main() {
 		log.With(err).Fatal("Config parsing failed")
 	}
 
+	// Permissions cache
+	err = diBuilder.Add(di.Def{
+		Name: static.DiPermsCache,
+		Build: func(ctn di.Container) (interface{}, error) {
+			return permissions.NewMemberCache(), nil
+		},
+	})
+	if err != nil {
+		log.With(err).Fatal("Permissions cache creation failed")
+	}
+
 	// Database
 	err = diBuilder.Add(di.Def{
 		Name: static.DiDatabase,
@@ -63,7 +102,7 @@ func main() {
 			return nil
 		},
 	})
-	if err != nil && err.Error() == "unknown database driver" {
+	if err == dberr.ErrUnknownDriver {
 		log.With(err).Fatal("Database creation failed, unknown driver")
 	} else if err != nil {
 		log.With(err).Fatal("Database creation failed")
@@ -148,4 +187,8 @@ func main() {
 	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt, os.Kill)
 	<-sc
 
+	// Abort all running queries and commands before
+	// the dependencies are torn down.
+	cancel()
+
 }
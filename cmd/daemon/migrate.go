@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/zekurio/daemon/internal/inits"
+	"github.com/zekurio/daemon/internal/models"
+	"github.com/zekurio/daemon/internal/services/config"
+	"github.com/zekurio/daemon/internal/services/database"
+)
+
+const migrateUsage = "usage: daemon [-c config.toml] migrate up|down|status|to <version>"
+
+var errMigrateUsage = errors.New(migrateUsage)
+
+// runMigrate executes the migrate sub command with the
+// given arguments against the configured database.
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errMigrateUsage
+	}
+
+	cfg, err := config.Parse(*flagConfigPath, "DAEMON_", models.DefaultConfig)
+	if err != nil {
+		return err
+	}
+
+	db, err := inits.OpenDatabase(context.Background(), cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	m, ok := db.(database.Migratable)
+	if !ok {
+		return fmt.Errorf("database type %s does not support migrations", cfg.Database.Type)
+	}
+	migrator := m.Migrator()
+
+	switch args[0] {
+	case "up":
+		err = migrator.Up()
+	case "down":
+		err = migrator.Down()
+	case "status":
+		err = migrator.Status()
+	case "to":
+		if len(args) < 2 {
+			return errMigrateUsage
+		}
+		var version int64
+		version, err = strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %q: %w", args[1], err)
+		}
+		err = migrator.To(version)
+	default:
+		return errMigrateUsage
+	}
+	if err != nil {
+		return err
+	}
+
+	version, err := migrator.Version()
+	if err != nil {
+		return err
+	}
+
+	log.Info("Current database schema", "Version", version)
+	return nil
+}
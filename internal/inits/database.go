@@ -1,27 +1,73 @@
 package inits
 
 import (
+	"context"
+
 	"github.com/charmbracelet/log"
 	"github.com/sarulabs/di/v2"
 
 	"github.com/zekurio/daemon/internal/models"
 	"github.com/zekurio/daemon/internal/services/database"
+	"github.com/zekurio/daemon/internal/services/database/cache"
+	"github.com/zekurio/daemon/internal/services/database/dberr"
 	"github.com/zekurio/daemon/internal/services/database/postgres"
+	"github.com/zekurio/daemon/internal/services/database/sqlite"
+	"github.com/zekurio/daemon/internal/services/permissions"
 	"github.com/zekurio/daemon/internal/util/static"
 )
 
 func InitDatabase(ctn di.Container) (database.Database, error) {
-	var db database.Database
-	var err error
-
 	cfg := ctn.Get(static.DiConfig).(models.Config)
-	db, err = postgres.InitPostgres(cfg.Postgres)
+	ctx := ctn.Get(static.DiContext).(context.Context)
 
+	db, err := OpenDatabase(ctx, cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	log.Info("Connected to database")
+	log.Info("Connected to database", "Type", cfg.Database.Type)
+
+	if m, ok := db.(database.Migratable); ok && cfg.Database.AutoMigrate {
+		if err = m.Migrator().Up(); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	// Writes to permission rules invalidate the resolved
+	// rules of members cached by the permissions service.
+	permsCache := ctn.Get(static.DiPermsCache).(*permissions.MemberCache)
+
+	if !cfg.Cache.Enabled {
+		return permsCache.WatchDatabase(db), nil
+	}
+
+	var store cache.Store
+	if cfg.Cache.Redis.Addr != "" {
+		store, err = cache.NewRedis(ctx, cfg.Cache.Redis)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		log.Info("Connected to redis cache", "Addr", cfg.Cache.Redis.Addr)
+	} else {
+		store = cache.NewLRU(cfg.Cache.Size)
+	}
+
+	return permsCache.WatchDatabase(cache.New(db, store, cfg.Cache.TTL)), nil
+}
+
+// OpenDatabase connects to the database driver selected in
+// the config without applying any migrations.
+func OpenDatabase(ctx context.Context, cfg models.Config) (db database.Database, err error) {
+	switch cfg.Database.Type {
+	case "postgres", "":
+		db, err = postgres.InitPostgres(ctx, cfg.Postgres, cfg.Database.QueryTimeout)
+	case "sqlite":
+		db, err = sqlite.InitSQLite(cfg.SQLite, cfg.Database.QueryTimeout)
+	default:
+		err = dberr.ErrUnknownDriver
+	}
 
-	return db, nil
+	return
 }
@@ -32,9 +32,20 @@ func InitDiscord(ctn di.Container) (*discordgo.Session, error) {
 
 	s.AddHandler(listeners.NewListenerGuilds(ctn).Handler)
 
+	s.AddHandler(listeners.NewListenerGuildDelete(ctn).Handler)
+
 	s.AddHandler(listeners.NewListenerAutovoice(ctn).Handler)
 
 	s.AddHandler(listeners.NewListenerVote(ctn).Handler)
 
+	permsCache := listeners.NewListenerPermsCache(ctn)
+	s.AddHandler(permsCache.HandlerMemberUpdate)
+	s.AddHandler(permsCache.HandlerMemberRemove)
+	s.AddHandler(permsCache.HandlerRoleUpdate)
+	s.AddHandler(permsCache.HandlerRoleDelete)
+	s.AddHandler(permsCache.HandlerChannelUpdate)
+	s.AddHandler(permsCache.HandlerChannelDelete)
+	s.AddHandler(permsCache.HandlerGuildDelete)
+
 	return s, nil
 }
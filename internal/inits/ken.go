@@ -8,6 +8,7 @@ import (
 	"github.com/sarulabs/di/v2"
 	"github.com/zekrotja/ken"
 
+	"github.com/zekurio/daemon/internal/listeners"
 	"github.com/zekurio/daemon/internal/middlewares"
 	"github.com/zekurio/daemon/internal/services/permissions"
 	"github.com/zekurio/daemon/internal/slashcommands"
@@ -43,6 +44,7 @@ func InitKen(ctn di.Container) (*ken.Ken, error) {
 		new(slashcommands.Guild),
 		new(slashcommands.Perms),
 		new(slashcommands.Vote),
+		new(slashcommands.Privacy),
 
 		// usercommands
 		new(usercommands.About),
@@ -51,9 +53,16 @@ func InitKen(ctn di.Container) (*ken.Ken, error) {
 		return nil, err
 	}
 
+	p.Registry().Collect(k.GetCommandInfo())
+
+	// ken does not handle autocomplete interactions
+	// itself, so they are dispatched by a listener.
+	s.AddHandler(listeners.NewListenerAutocomplete(ctn).Handler)
+
 	err = k.RegisterMiddlewares(
 		p,
 		middlewares.NewDisableCommandsMiddleware(ctn),
+		middlewares.NewContextMiddleware(ctn),
 	)
 
 	return k, err
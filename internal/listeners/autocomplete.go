@@ -0,0 +1,138 @@
+package listeners
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/charmbracelet/log"
+	"github.com/sarulabs/di/v2"
+
+	"github.com/zekurio/daemon/internal/services/permissions"
+	"github.com/zekurio/daemon/internal/util/static"
+	"github.com/zekurio/daemon/pkg/perms"
+)
+
+// maxChoices is the maximum number of autocomplete
+// choices accepted by Discord.
+const maxChoices = 25
+
+type ListenerAutocomplete struct {
+	ctx   context.Context
+	perms *permissions.Permissions
+}
+
+func NewListenerAutocomplete(ctn di.Container) *ListenerAutocomplete {
+	return &ListenerAutocomplete{
+		ctx:   ctn.Get(static.DiContext).(context.Context),
+		perms: ctn.Get(static.DiPermissions).(*permissions.Permissions),
+	}
+}
+
+// Handler responds to autocomplete interactions of
+// command options with autocomplete enabled.
+func (l *ListenerAutocomplete) Handler(s *discordgo.Session, e *discordgo.InteractionCreate) {
+	if e.Type != discordgo.InteractionApplicationCommandAutocomplete {
+		return
+	}
+
+	data := e.ApplicationCommandData()
+	focused := focusedOption(data.Options)
+	if focused == nil {
+		return
+	}
+
+	var choices []*discordgo.ApplicationCommandOptionChoice
+
+	switch {
+	case data.Name == "perms" && focused.Name == "perm" && strings.HasPrefix(focused.StringValue(), "@"):
+		choices = l.groupChoices(e.GuildID, focused.StringValue())
+	case data.Name == "perms" && focused.Name == "perm":
+		choices = l.permChoices(focused.StringValue())
+	default:
+		return
+	}
+
+	err := s.InteractionRespond(e.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{
+			Choices: choices,
+		},
+	})
+	if err != nil {
+		log.With(err).Error("Failed responding to autocomplete", "Command", data.Name)
+	}
+}
+
+func (l *ListenerAutocomplete) permChoices(query string) []*discordgo.ApplicationCommandOptionChoice {
+	entries := l.perms.Registry().Search(query, maxChoices)
+
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, len(entries))
+	for i, e := range entries {
+		name := e.DN
+		if e.Description != "" {
+			name += " - " + e.Description
+		}
+		if r := []rune(name); len(r) > 100 {
+			name = string(r[:97]) + "..."
+		}
+		choices[i] = &discordgo.ApplicationCommandOptionChoice{
+			Name:  name,
+			Value: e.DN,
+		}
+	}
+
+	return choices
+}
+
+// groupChoices lists the references to the permission groups
+// of the guild which contain query.
+func (l *ListenerAutocomplete) groupChoices(guildID, query string) []*discordgo.ApplicationCommandOptionChoice {
+	groups, err := l.perms.Groups(l.ctx, guildID)
+	if err != nil {
+		log.With(err).Error("Failed getting permission groups", "GuildID", guildID)
+		return nil
+	}
+
+	// The query may be any part of the prefix while typing.
+	query = strings.ToLower(query)
+	if strings.HasPrefix(perms.GroupPrefix, query) {
+		query = ""
+	}
+	query = strings.TrimPrefix(query, perms.GroupPrefix)
+
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	for name := range groups {
+		if !strings.Contains(name, query) {
+			continue
+		}
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  perms.GroupPrefix + name,
+			Value: perms.GroupPrefix + name,
+		})
+	}
+
+	sort.Slice(choices, func(i, j int) bool {
+		return choices[i].Name < choices[j].Name
+	})
+	if len(choices) > maxChoices {
+		choices = choices[:maxChoices]
+	}
+
+	return choices
+}
+
+// focusedOption returns the option the user is currently
+// typing in, looking into sub commands and groups.
+func focusedOption(options []*discordgo.ApplicationCommandInteractionDataOption) *discordgo.ApplicationCommandInteractionDataOption {
+	for _, o := range options {
+		if o.Focused {
+			return o
+		}
+		if f := focusedOption(o.Options); f != nil {
+			return f
+		}
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package listeners
 
 import (
+	"context"
 	"strings"
 
 	"github.com/bwmarrin/discordgo"
@@ -11,12 +12,14 @@ import (
 )
 
 type ListenerAutovoice struct {
+	ctx             context.Context
 	db              database.Database
 	voiceStateCache map[string]*discordgo.VoiceState
 }
 
 func NewListenerAutovoice(ctn di.Container) *ListenerAutovoice {
 	return &ListenerAutovoice{
+		ctx:             ctn.Get(static.DiContext).(context.Context),
 		db:              ctn.Get(static.DiDatabase).(database.Database),
 		voiceStateCache: map[string]*discordgo.VoiceState{},
 	}
@@ -28,7 +31,7 @@ func (l *ListenerAutovoice) Handler(s *discordgo.Session, e *discordgo.VoiceStat
 
 	l.voiceStateCache[e.UserID] = newVState
 
-	ids, err := l.db.GetAutoVoice(e.GuildID)
+	ids, err := l.db.GetAutoVoice(l.ctx, e.GuildID)
 	if err != nil {
 		return
 	}
@@ -45,7 +48,7 @@ func (l *ListenerAutovoice) Handler(s *discordgo.Session, e *discordgo.VoiceStat
 			return
 		}
 
-		if err = l.db.AddUpdateAVChannel(av); err != nil {
+		if err = l.db.AddUpdateAVChannel(l.ctx, av); err != nil {
 			return
 		}
 
@@ -62,7 +65,7 @@ func (l *ListenerAutovoice) Handler(s *discordgo.Session, e *discordgo.VoiceStat
 					return
 				}
 
-				if err = l.db.AddUpdateAVChannel(av); err != nil {
+				if err = l.db.AddUpdateAVChannel(l.ctx, av); err != nil {
 					return
 				}
 			} else {
@@ -77,7 +80,7 @@ func (l *ListenerAutovoice) Handler(s *discordgo.Session, e *discordgo.VoiceStat
 					return
 				}
 
-				if err = l.db.DeleteAVChannel(avChannel.CreatedChannelID); err != nil {
+				if err = l.db.DeleteAVChannel(l.ctx, avChannel.CreatedChannelID); err != nil {
 					return
 				}
 			}
@@ -89,7 +92,7 @@ func (l *ListenerAutovoice) Handler(s *discordgo.Session, e *discordgo.VoiceStat
 				return
 			}
 
-			if err = l.db.DeleteAVChannel(avChannel.CreatedChannelID); err != nil {
+			if err = l.db.DeleteAVChannel(l.ctx, avChannel.CreatedChannelID); err != nil {
 				return
 			}
 		}
@@ -100,7 +103,7 @@ func (l *ListenerAutovoice) Handler(s *discordgo.Session, e *discordgo.VoiceStat
 				return
 			}
 
-			if err = l.db.DeleteAVChannel(avChannel.CreatedChannelID); err != nil {
+			if err = l.db.DeleteAVChannel(l.ctx, avChannel.CreatedChannelID); err != nil {
 				return
 			}
 		}
@@ -0,0 +1,52 @@
+package listeners
+
+import (
+	"context"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/charmbracelet/log"
+	"github.com/sarulabs/di/v2"
+
+	"github.com/zekurio/daemon/internal/models"
+	"github.com/zekurio/daemon/internal/services/database"
+	"github.com/zekurio/daemon/internal/util/static"
+)
+
+type ListenerGuildDelete struct {
+	ctx context.Context
+	cfg models.Config
+	db  database.Database
+}
+
+func NewListenerGuildDelete(ctn di.Container) *ListenerGuildDelete {
+	return &ListenerGuildDelete{
+		ctx: ctn.Get(static.DiContext).(context.Context),
+		cfg: ctn.Get(static.DiConfig).(models.Config),
+		db:  ctn.Get(static.DiDatabase).(database.Database),
+	}
+}
+
+// Handler marks the data of a guild which removed the bot
+// for deletion. It is flushed by the cleanup job in the ready
+// listener once the grace period is over.
+func (l *ListenerGuildDelete) Handler(s *discordgo.Session, e *discordgo.GuildDelete) {
+	// Guilds become unavailable during outages, which
+	// does not mean that the bot was removed.
+	if e.Unavailable {
+		return
+	}
+
+	gracePeriod := l.cfg.Database.GuildDataGracePeriod
+	if gracePeriod <= 0 {
+		return
+	}
+
+	deleteAfter := time.Now().Add(gracePeriod)
+	if err := l.db.MarkGuildDeletion(l.ctx, e.ID, deleteAfter); err != nil {
+		log.With(err).Error("Failed marking guild data for deletion", "GuildID", e.ID)
+		return
+	}
+
+	log.Info("Guild data marked for deletion", "GuildID", e.ID, "DeleteAfter", deleteAfter)
+}
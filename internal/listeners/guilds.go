@@ -1,6 +1,7 @@
 package listeners
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -15,18 +16,25 @@ import (
 )
 
 type ListenerGuilds struct {
+	ctx context.Context
 	cfg models.Config
 	db  database.Database
 }
 
 func NewListenerGuilds(ctn di.Container) *ListenerGuilds {
 	return &ListenerGuilds{
+		ctx: ctn.Get(static.DiContext).(context.Context),
 		cfg: ctn.Get(static.DiConfig).(models.Config),
 		db:  ctn.Get(static.DiDatabase).(database.Database),
 	}
 }
 
 func (g *ListenerGuilds) Handler(s *discordgo.Session, e *discordgo.GuildCreate) {
+	// The bot was added again, so keep the guild data.
+	if err := g.db.UnmarkGuildDeletion(g.ctx, e.Guild.ID); err != nil {
+		log.With(err).Error("Failed unmarking guild data for deletion", "GuildID", e.Guild.ID)
+	}
+
 	// check if the joinedAt is older than the time
 	if e.JoinedAt.Unix() <= time.Now().Unix() {
 		return
@@ -1,6 +1,7 @@
 package listeners
 
 import (
+	"context"
 	"github.com/bwmarrin/discordgo"
 	"github.com/charmbracelet/log"
 	"github.com/sarulabs/di/v2"
@@ -12,17 +13,19 @@ import (
 )
 
 type ListenerMembers struct {
-	db database.Database
+	ctx context.Context
+	db  database.Database
 }
 
 func NewListenerMembers(ctn di.Container) *ListenerMembers {
 	return &ListenerMembers{
-		db: ctn.Get(static.DiDatabase).(database.Database),
+		ctx: ctn.Get(static.DiContext).(context.Context),
+		db:  ctn.Get(static.DiDatabase).(database.Database),
 	}
 }
 
 func (g *ListenerMembers) Handler(s *discordgo.Session, e *discordgo.GuildMemberAdd) {
-	autoroleIDs, err := g.db.GetAutoRoles(e.GuildID)
+	autoroleIDs, err := g.db.GetAutoRoles(g.ctx, e.GuildID)
 	if err != nil && err != dberr.ErrNotFound {
 		log.With(err).Error("Failed getting auto role settings")
 		return
@@ -45,7 +48,7 @@ func (g *ListenerMembers) Handler(s *discordgo.Session, e *discordgo.GuildMember
 				newAutoRoleIDs = append(newAutoRoleIDs, rid)
 			}
 		}
-		err = g.db.SetAutoRoles(e.GuildID, newAutoRoleIDs)
+		err = g.db.SetAutoRoles(g.ctx, e.GuildID, newAutoRoleIDs)
 		if err != nil {
 			log.With(err).Error("Failed updating auto role settings")
 		}
@@ -0,0 +1,50 @@
+package listeners
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"github.com/sarulabs/di/v2"
+
+	"github.com/zekurio/daemon/internal/services/permissions"
+	"github.com/zekurio/daemon/internal/util/static"
+)
+
+// ListenerPermsCache invalidates the cached permissions of
+// members when their roles, the roles of the guild or the
+// channel hierarchy change.
+type ListenerPermsCache struct {
+	cache *permissions.MemberCache
+}
+
+func NewListenerPermsCache(ctn di.Container) *ListenerPermsCache {
+	return &ListenerPermsCache{
+		cache: ctn.Get(static.DiPermsCache).(*permissions.MemberCache),
+	}
+}
+
+func (l *ListenerPermsCache) HandlerMemberUpdate(s *discordgo.Session, e *discordgo.GuildMemberUpdate) {
+	l.cache.InvalidateMember(e.GuildID, e.User.ID)
+}
+
+func (l *ListenerPermsCache) HandlerMemberRemove(s *discordgo.Session, e *discordgo.GuildMemberRemove) {
+	l.cache.InvalidateMember(e.GuildID, e.User.ID)
+}
+
+func (l *ListenerPermsCache) HandlerRoleUpdate(s *discordgo.Session, e *discordgo.GuildRoleUpdate) {
+	l.cache.InvalidateGuild(e.GuildID)
+}
+
+func (l *ListenerPermsCache) HandlerRoleDelete(s *discordgo.Session, e *discordgo.GuildRoleDelete) {
+	l.cache.InvalidateGuild(e.GuildID)
+}
+
+func (l *ListenerPermsCache) HandlerChannelUpdate(s *discordgo.Session, e *discordgo.ChannelUpdate) {
+	l.cache.InvalidateGuild(e.GuildID)
+}
+
+func (l *ListenerPermsCache) HandlerChannelDelete(s *discordgo.Session, e *discordgo.ChannelDelete) {
+	l.cache.InvalidateGuild(e.GuildID)
+}
+
+func (l *ListenerPermsCache) HandlerGuildDelete(s *discordgo.Session, e *discordgo.GuildDelete) {
+	l.cache.InvalidateGuild(e.ID)
+}
@@ -1,7 +1,10 @@
 package listeners
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"sync"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
@@ -9,6 +12,7 @@ import (
 	"github.com/sarulabs/di/v2"
 
 	"github.com/zekurio/daemon/internal/services/database"
+	"github.com/zekurio/daemon/internal/services/permissions"
 	"github.com/zekurio/daemon/internal/services/scheduler"
 	"github.com/zekurio/daemon/internal/util/autovoice"
 	"github.com/zekurio/daemon/internal/util/static"
@@ -17,12 +21,18 @@ import (
 )
 
 type ListenerReady struct {
+	ctx   context.Context
 	db    database.Database
 	sched scheduler.Provider
+
+	// startOnce guards the setup which must only run on the
+	// first ready event and not again after reconnects.
+	startOnce sync.Once
 }
 
 func NewListenerReady(ctn di.Container) *ListenerReady {
 	return &ListenerReady{
+		ctx:   ctn.Get(static.DiContext).(context.Context),
 		db:    ctn.Get(static.DiDatabase).(database.Database),
 		sched: ctn.Get(static.DiScheduler).(scheduler.Provider),
 	}
@@ -36,37 +46,29 @@ func (l *ListenerReady) Handler(s *discordgo.Session, e *discordgo.Ready) {
 	log.Info("Signed in!", "Username", fmt.Sprintf("%s#%s", s.State.User.Username, s.State.User.Discriminator), "ID", s.State.User.ID)
 	log.Infof("Invite link: %s", discordutils.GetInviteLink(s))
 
-	l.sched.Start()
-
-	_, err = l.sched.Schedule("*/30 * * * * *", func() {
-		votes, err := l.db.GetVotes()
-		if err != nil {
-			log.Error("Failed getting votes from database: %s", err.Error())
-			return
-		}
-		vote.VotesRunning = votes
-		now := time.Now()
-		for _, v := range vote.VotesRunning {
-			if (v.Expires != time.Time{}) && v.Expires.Before(now) {
-				v.Close(s, vote.StateExpired)
-				if err = l.db.DeleteVote(v.ID); err != nil {
-					log.Error("Failed deleting vote from database: %s", err.Error())
-				}
-			}
-		}
+	var startup bool
+	l.startOnce.Do(func() {
+		startup = true
 	})
-	if err != nil {
-		log.Error("Failed scheduling vote cleanup: %s", err.Error())
+
+	// The running votes are kept in memory across reconnects,
+	// so they are only loaded once.
+	if startup {
+		l.loadVotes(s, e.Guilds)
+		l.scheduleJobs(s)
 	}
 
-	autovoices, err := l.db.GetAVChannels()
-	if err != nil {
-	} else {
-		autovoice.ActiveChannels = autovoices
-		for _, av := range autovoice.ActiveChannels {
+	for _, g := range e.Guilds {
+		autovoices, err := l.db.GetAVChannels(l.ctx, g.ID)
+		if err != nil {
+			log.With(err).Error("Failed getting autovoice channels from database", "GuildID", g.ID)
+			continue
+		}
+		for _, av := range autovoices {
+			autovoice.ActiveChannels[av.CreatedChannelID] = av
 			members, err := discordutils.GetVoiceMembers(s, av.GuildID, av.CreatedChannelID)
 			if err != nil || len(members) == 0 {
-				if err = l.db.DeleteAVChannel(av.CreatedChannelID); err != nil {
+				if err = l.db.DeleteAVChannel(l.ctx, av.CreatedChannelID); err != nil {
 					log.Error("Failed deleting AV channel from database: %s", err.Error())
 				}
 
@@ -91,3 +93,232 @@ func (l *ListenerReady) Handler(s *discordgo.Session, e *discordgo.Ready) {
 	}
 
 }
+
+// loadVotes restores the running votes of the given guilds
+// from the database.
+func (l *ListenerReady) loadVotes(s *discordgo.Session, guilds []*discordgo.Guild) {
+	for _, g := range guilds {
+		votes, err := l.db.GetVotes(l.ctx, g.ID)
+		if err != nil {
+			log.With(err).Error("Failed getting votes from database", "GuildID", g.ID)
+			continue
+		}
+		for _, v := range votes {
+			// Claims left over by a previous process which
+			// stopped while posting are released, so the
+			// vote is posted again.
+			if v.Posting() {
+				if _, err = l.db.SwapVoteMsgID(l.ctx, v.ID, vote.MsgIDPosting, ""); err != nil {
+					log.With(err).Error("Failed releasing scheduled vote", "VoteID", v.ID)
+				}
+				continue
+			}
+			if !v.Closed.IsZero() || v.Pending() {
+				continue
+			}
+			l.loadVote(s, v)
+		}
+	}
+}
+
+// loadVote adds the vote to the running votes and migrates
+// it to buttons if needed.
+func (l *ListenerReady) loadVote(s *discordgo.Session, v vote.Vote) {
+	unlock := vote.Lock(v.ID)
+	defer unlock()
+
+	vote.SetRunning(v)
+
+	// Votes created before the buttons were introduced
+	// are still ticked with reactions.
+	migrated, err := v.MigrateReactions(s)
+	if err != nil {
+		log.With(err).Warn("Failed migrating vote to buttons", "VoteID", v.ID)
+	} else if migrated {
+		log.Info("Migrated vote from reactions to buttons", "VoteID", v.ID)
+	}
+}
+
+// scheduleJobs starts the scheduler with the recurring
+// jobs of the bot.
+func (l *ListenerReady) scheduleJobs(s *discordgo.Session) {
+	l.sched.Start()
+
+	_, err := l.sched.Schedule("*/30 * * * * *", func() {
+		votes, err := l.db.GetExpiredVotes(l.ctx, time.Now())
+		if err != nil {
+			log.With(err).Error("Failed getting expired votes from database")
+			return
+		}
+		for _, v := range votes {
+			l.closeExpiredVote(s, v.ID)
+		}
+	})
+	if err != nil {
+		log.Error("Failed scheduling vote cleanup: %s", err.Error())
+	}
+
+	_, err = l.sched.Schedule("*/30 * * * * *", func() {
+		l.postDueVotes(s)
+	})
+	if err != nil {
+		log.With(err).Error("Failed scheduling posting of scheduled votes")
+	}
+
+	_, err = l.sched.Schedule("*/30 * * * * *", func() {
+		l.revokeExpiredGrants(s)
+	})
+	if err != nil {
+		log.With(err).Error("Failed scheduling permission grant cleanup")
+	}
+
+	_, err = l.sched.Schedule("0 */10 * * * *", func() {
+		l.flushRemovedGuilds(s)
+	})
+	if err != nil {
+		log.With(err).Error("Failed scheduling guild data cleanup")
+	}
+}
+
+// closeExpiredVote closes the expired vote. Ticks recorded
+// since it was read from the database are kept. Votes which
+// have been closed in the meantime are skipped.
+func (l *ListenerReady) closeExpiredVote(s *discordgo.Session, id string) {
+	unlock := vote.Lock(id)
+	defer unlock()
+
+	v, ok := vote.GetRunning(id)
+	if !ok || !v.Closed.IsZero() {
+		return
+	}
+
+	if err := v.Close(s, vote.StateExpired); err != nil {
+		log.With(err).Warn("Failed updating expired vote message", "VoteID", v.ID)
+	}
+	if err := l.db.AddUpdateVote(l.ctx, v); err != nil {
+		log.With(err).Error("Failed storing expired vote in database", "VoteID", v.ID)
+	}
+}
+
+// flushRemovedGuilds deletes the data of all guilds which
+// removed the bot and whose grace period is over.
+func (l *ListenerReady) flushRemovedGuilds(s *discordgo.Session) {
+	guildIDs, err := l.db.GetDueGuildDeletions(l.ctx, time.Now())
+	if err != nil {
+		log.With(err).Error("Failed getting guilds marked for deletion")
+		return
+	}
+
+	for _, guildID := range guildIDs {
+		// The bot might have been added again while the
+		// guild create event was missed.
+		if _, err = s.State.Guild(guildID); err == nil {
+			if err = l.db.UnmarkGuildDeletion(l.ctx, guildID); err != nil {
+				log.With(err).Error("Failed unmarking guild data for deletion", "GuildID", guildID)
+			}
+			continue
+		}
+
+		if err = l.db.FlushGuildData(l.ctx, guildID); err != nil {
+			log.With(err).Error("Failed flushing guild data", "GuildID", guildID)
+			continue
+		}
+
+		for _, v := range vote.RunningVotes() {
+			if v.GuildID == guildID {
+				vote.RemoveRunning(v.ID)
+			}
+		}
+
+		for id, av := range autovoice.ActiveChannels {
+			if av.GuildID == guildID {
+				delete(autovoice.ActiveChannels, id)
+			}
+		}
+
+		log.Info("Flushed data of removed guild", "GuildID", guildID)
+	}
+}
+
+// postDueVotes posts all scheduled votes whose start time
+// has passed. Each vote is claimed before it is posted, so
+// overlapping runs do not post it twice. Votes whose
+// channel is gone or can not be written to anymore are
+// deleted, other failures are retried on the next run.
+func (l *ListenerReady) postDueVotes(s *discordgo.Session) {
+	votes, err := l.db.GetDueVotes(l.ctx, time.Now())
+	if err != nil {
+		log.With(err).Error("Failed getting scheduled votes from database")
+		return
+	}
+
+	for _, v := range votes {
+		claimed, err := l.db.SwapVoteMsgID(l.ctx, v.ID, "", vote.MsgIDPosting)
+		if err != nil {
+			log.With(err).Error("Failed claiming scheduled vote", "VoteID", v.ID)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		if _, err = v.Post(s); err != nil {
+			if apiErr, ok := err.(*discordgo.RESTError); ok && (apiErr.Response.StatusCode == http.StatusNotFound ||
+				apiErr.Response.StatusCode == http.StatusForbidden) {
+				log.With(err).Warn("Dropping scheduled vote which can not be posted", "VoteID", v.ID)
+				if err = l.db.DeleteVote(l.ctx, v.ID); err != nil {
+					log.With(err).Error("Failed deleting scheduled vote from database", "VoteID", v.ID)
+				}
+				continue
+			}
+			log.With(err).Warn("Failed posting scheduled vote", "VoteID", v.ID)
+			if _, err = l.db.SwapVoteMsgID(l.ctx, v.ID, vote.MsgIDPosting, ""); err != nil {
+				log.With(err).Error("Failed releasing scheduled vote", "VoteID", v.ID)
+			}
+			continue
+		}
+
+		if err = l.db.AddUpdateVote(l.ctx, v); err != nil {
+			log.With(err).Error("Failed storing posted vote in database", "VoteID", v.ID)
+			_ = s.ChannelMessageDelete(v.ChannelID, v.MsgID)
+			if _, err = l.db.SwapVoteMsgID(l.ctx, v.ID, vote.MsgIDPosting, ""); err != nil {
+				log.With(err).Error("Failed releasing scheduled vote", "VoteID", v.ID)
+			}
+			continue
+		}
+
+		vote.SetRunning(v)
+	}
+}
+
+// revokeExpiredGrants removes the rules of all expired
+// permission grants and announces it in the channel the
+// rule was granted in.
+func (l *ListenerReady) revokeExpiredGrants(s *discordgo.Session) {
+	grants, err := l.db.GetExpiredPermissionGrants(l.ctx, time.Now())
+	if err != nil {
+		log.With(err).Error("Failed getting expired permission grants")
+		return
+	}
+
+	for _, g := range grants {
+		removed, err := permissions.RevokeGrant(l.ctx, l.db, g)
+		if err != nil {
+			log.With(err).Error("Failed revoking permission grant", "GuildID", g.GuildID, "Rule", g.Rule)
+			continue
+		}
+
+		if !removed || g.ChannelID == "" {
+			continue
+		}
+
+		_, err = s.ChannelMessageSendEmbed(g.ChannelID, &discordgo.MessageEmbed{
+			Color: static.ColorGrey,
+			Description: fmt.Sprintf("The temporary permission `%s` of %s expired and has been removed.",
+				g.Rule, permissions.GrantSource(g)),
+		})
+		if err != nil {
+			log.With(err).Warn("Failed announcing expired permission grant", "ChannelID", g.ChannelID)
+		}
+	}
+}
@@ -1,55 +1,159 @@
 package listeners
 
 import (
+	"context"
+	"errors"
+	"fmt"
+
 	"github.com/bwmarrin/discordgo"
 	"github.com/charmbracelet/log"
 	"github.com/sarulabs/di/v2"
 
 	"github.com/zekurio/daemon/internal/services/database"
+	"github.com/zekurio/daemon/internal/services/database/dberr"
 	"github.com/zekurio/daemon/internal/util/static"
 	"github.com/zekurio/daemon/internal/util/vote"
-	"github.com/zekurio/daemon/pkg/discordutils"
 )
 
 type ListenerVote struct {
-	db database.Database
+	ctx context.Context
+	db  database.Database
 }
 
 func NewListenerVote(container di.Container) *ListenerVote {
 	return &ListenerVote{
-		db: container.Get(static.DiDatabase).(database.Database),
+		ctx: container.Get(static.DiContext).(context.Context),
+		db:  container.Get(static.DiDatabase).(database.Database),
+	}
+}
+
+// Handler ticks votes when a member clicks a button or
+// selects a choice of the vote message and cancels pending
+// votes selected in the list of /vote list.
+func (l *ListenerVote) Handler(s *discordgo.Session, e *discordgo.InteractionCreate) {
+	if e.Type != discordgo.InteractionMessageComponent || e.Member == nil {
+		return
+	}
+
+	if userID, voteID, ok := vote.ParseCancel(e.MessageComponentData()); ok {
+		l.cancel(s, e, userID, voteID)
+		return
+	}
+
+	sel, ok := vote.ParseComponent(e.MessageComponentData())
+	if !ok {
+		return
+	}
+
+	// Ticks of the same vote are recorded one after another,
+	// so that no tick is lost when storing the vote.
+	unlock := vote.Lock(sel.VoteID)
+	defer unlock()
+
+	v, ok := vote.GetRunning(sel.VoteID)
+	if !ok || v.GuildID != e.GuildID {
+		l.respond(s, e, static.ColorRed, "This vote is closed.")
+		return
+	}
+
+	t, err := v.Tick(e.Member, sel)
+	if errors.Is(err, vote.ErrNotEligible) {
+		l.respond(s, e, static.ColorRed, "Only members with one of these roles may vote: "+v.FormatRoles())
+		return
+	}
+	if errors.Is(err, vote.ErrInvalidSelection) {
+		l.respond(s, e, static.ColorRed, "This selection is not valid for this vote.")
+		return
+	}
+	if err != nil {
+		log.With(err).Error("Failed ticking vote", "VoteID", v.ID)
+		return
+	}
+
+	if v.Mode == vote.ModeRanked {
+		l.respond(s, e, static.ColorGreen, "Your ranking has been recorded:\n"+v.FormatTick(t))
+	} else {
+		l.respond(s, e, static.ColorGreen, fmt.Sprintf("Your vote for %s has been recorded.", v.FormatTick(t)))
+	}
+	vote.SetRunning(v)
+
+	if err = v.Update(s); err != nil {
+		log.With(err).Error("Failed updating vote message", "VoteID", v.ID)
+	}
+	if err = l.db.AddUpdateVote(l.ctx, v); err != nil {
+		log.With(err).Error("Failed updating vote in database", "VoteID", v.ID)
 	}
 }
 
-func (l *ListenerVote) Handler(s *discordgo.Session, e *discordgo.MessageReactionAdd) {
-	user, err := discordutils.GetUser(s, e.UserID)
+// cancel deletes the pending vote and renders the list of
+// votes of the user again.
+func (l *ListenerVote) cancel(s *discordgo.Session, e *discordgo.InteractionCreate, userID, voteID string) {
+	if e.Member.User.ID != userID {
+		l.respond(s, e, static.ColorRed, "You can only cancel your own votes.")
+		return
+	}
+
+	v, err := l.db.GetVote(l.ctx, voteID)
+	if err != nil && err != dberr.ErrNotFound {
+		log.With(err).Error("Failed getting vote from database", "VoteID", voteID)
+		return
+	}
+	if err == dberr.ErrNotFound || !v.Pending() || v.GuildID != e.GuildID || v.CreatorID != userID {
+		l.respond(s, e, static.ColorRed, "This vote has already been posted or canceled.")
+		return
+	}
+
+	// The vote is claimed like for posting, so it is not
+	// posted while it is deleted.
+	claimed, err := l.db.SwapVoteMsgID(l.ctx, voteID, "", vote.MsgIDPosting)
 	if err != nil {
+		log.With(err).Error("Failed claiming scheduled vote", "VoteID", voteID)
+		return
+	}
+	if !claimed {
+		l.respond(s, e, static.ColorRed, "This vote has already been posted or canceled.")
 		return
 	}
 
-	if user == nil || user.Bot || user.ID == s.State.User.ID {
-		return
-	}
-	for _, v := range vote.VotesRunning {
-		if v.GuildID != e.GuildID || v.ChannelID != e.ChannelID || v.MsgID != e.MessageID {
-			continue
-		}
-		tick := -1
-		for i, ve := range vote.Emotes {
-			if e.Emoji.Name == ve {
-				tick = i
-			}
-		}
-		if tick > -1 {
-			go func() {
-				v.Tick(s, e.UserID, tick)
-				if err = l.db.AddUpdateVote(v); err != nil {
-					log.Errorf(e.GuildID, "Failed updating vote in database: %s", err.Error())
-				}
-			}()
-		}
-		if err = s.MessageReactionRemove(e.ChannelID, e.MessageID, e.Emoji.Name, e.UserID); err != nil {
-			log.Errorf(e.GuildID, "Failed removing reaction: %s", err.Error())
-		}
+	if err = l.db.DeleteVote(l.ctx, voteID); err != nil {
+		log.With(err).Error("Failed deleting scheduled vote from database", "VoteID", voteID)
+		return
+	}
+
+	guildVotes, err := l.db.GetVotes(l.ctx, e.GuildID)
+	if err != nil {
+		log.With(err).Error("Failed getting votes from database", "GuildID", e.GuildID)
+		return
+	}
+
+	votes := vote.UserVotes(guildVotes, e.GuildID, userID)
+	err = s.InteractionRespond(e.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     []*discordgo.MessageEmbed{vote.ListEmbed(votes)},
+			Components: vote.CancelComponents(userID, votes),
+		},
+	})
+	if err != nil {
+		log.With(err).Error("Failed updating vote list", "VoteID", voteID)
+	}
+}
+
+// respond answers the interaction with an ephemeral message.
+func (l *ListenerVote) respond(s *discordgo.Session, e *discordgo.InteractionCreate, color int, msg string) {
+	err := s.InteractionRespond(e.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+			Embeds: []*discordgo.MessageEmbed{
+				{
+					Color:       color,
+					Description: msg,
+				},
+			},
+		},
+	})
+	if err != nil {
+		log.With(err).Error("Failed responding to vote interaction")
 	}
 }
@@ -0,0 +1,53 @@
+package middlewares
+
+import (
+	"context"
+	"time"
+
+	"github.com/sarulabs/di/v2"
+	"github.com/zekrotja/ken"
+
+	"github.com/zekurio/daemon/internal/util/static"
+)
+
+const (
+	// commandTimeout matches the lifetime of an interaction token,
+	// after which responding to the command is not possible anymore.
+	commandTimeout = 15 * time.Minute
+
+	contextCancelKey = "context-cancel"
+)
+
+type ContextMiddleware struct {
+	root context.Context
+}
+
+var (
+	_ ken.Middleware = (*ContextMiddleware)(nil)
+)
+
+func NewContextMiddleware(ctn di.Container) *ContextMiddleware {
+	return &ContextMiddleware{
+		root: ctn.Get(static.DiContext).(context.Context),
+	}
+}
+
+// Before attaches a context to the command which is canceled
+// once the command finished or the application shuts down.
+//
+// It should be registered after all other middlewares because
+// After is not called when a command is aborted in Before. Until
+// then, getting static.DiContext falls back to the root context.
+func (m *ContextMiddleware) Before(ctx *ken.Ctx) (next bool, err error) {
+	reqCtx, cancel := context.WithTimeout(m.root, commandTimeout)
+	ctx.Set(static.DiContext, reqCtx)
+	ctx.Set(contextCancelKey, cancel)
+	return true, nil
+}
+
+func (m *ContextMiddleware) After(ctx *ken.Ctx, cmdError error) (err error) {
+	if cancel, ok := ctx.Get(contextCancelKey).(context.CancelFunc); ok {
+		cancel()
+	}
+	return nil
+}
@@ -1,6 +1,10 @@
 package models
 
-import "github.com/zekurio/daemon/internal/util/static"
+import (
+	"time"
+
+	"github.com/zekurio/daemon/internal/util/static"
+)
 
 var DefaultConfig = Config{
 	Discord: DiscordConfig{
@@ -9,9 +13,27 @@ var DefaultConfig = Config{
 		GuildLimit:       -1,
 		DisabledCommands: []string{},
 	},
+	Database: DatabaseConfig{
+		Type:                 "postgres",
+		AutoMigrate:          true,
+		QueryTimeout:         5 * time.Second,
+		GuildDataGracePeriod: 7 * 24 * time.Hour,
+	},
 	Postgres: PostgresConfig{
-		Host: "localhost",
-		Port: 5432,
+		Host:           "localhost",
+		Port:           5432,
+		SSLMode:        "disable",
+		MaxIdleConns:   2,
+		ConnectRetries: 5,
+		ConnectBackoff: time.Second,
+	},
+	SQLite: SQLiteConfig{
+		Path: "daemon.db",
+	},
+	Cache: CacheConfig{
+		Enabled: true,
+		Size:    1000,
+		TTL:     5 * time.Minute,
 	},
 	Permissions: PermissionRules{
 		UserRules:  static.DefaultUserRules,
@@ -26,21 +48,63 @@ type DiscordConfig struct {
 	DisabledCommands []string
 }
 
+type DatabaseConfig struct {
+	Type                 string
+	AutoMigrate          bool
+	QueryTimeout         time.Duration
+	GuildDataGracePeriod time.Duration
+}
+
 type PostgresConfig struct {
-	Host     string
-	Port     int
-	Database string
-	Username string
+	DSN             string
+	Host            string
+	Port            int
+	Database        string
+	Username        string
+	Password        string
+	SSLMode         string
+	SSLRootCert     string
+	SSLCert         string
+	SSLKey          string
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	ConnectRetries  int
+	ConnectBackoff  time.Duration
+}
+
+type SQLiteConfig struct {
+	Path string
+}
+
+type CacheConfig struct {
+	Enabled bool
+	Size    int
+	TTL     time.Duration
+	Redis   RedisConfig
+}
+
+type RedisConfig struct {
+	Addr     string
 	Password string
+	DB       int
 }
 
 type PermissionRules struct {
 	UserRules  []string
 	AdminRules []string
+	// Groups are instance wide permission groups by name,
+	// guilds can replace them with own groups of the same
+	// name.
+	Groups map[string][]string
 }
 
 type Config struct {
 	Discord     DiscordConfig
+	Database    DatabaseConfig
 	Postgres    PostgresConfig
+	SQLite      SQLiteConfig
+	Cache       CacheConfig
 	Permissions PermissionRules
 }
@@ -0,0 +1,288 @@
+// Package cache provides a database.Database decorator
+// which caches frequently queried guild settings and
+// permissions in a Store.
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/zekurio/daemon/internal/services/database"
+	"github.com/zekurio/daemon/internal/services/database/dberr"
+	"github.com/zekurio/daemon/pkg/perms"
+)
+
+const (
+	keyAutoRoles   = "autoroles"
+	keyAutoVoice   = "autovoice"
+	keyPermissions = "permissions"
+	keyUserPerms   = "userpermissions"
+	keyScopedPerms = "scopedpermissions"
+	keyPermGroups  = "permissiongroups"
+)
+
+// Cache wraps a database.Database and caches the results
+// of GetAutoRoles, GetAutoVoice and the permission getters.
+// All other methods are passed through to the wrapped database.
+//
+// Writes always go to the database first, afterwards the
+// affected cache entries are invalidated. Errors of the
+// store are logged and the database is queried instead.
+type Cache struct {
+	database.Database
+
+	store Store
+	ttl   time.Duration
+
+	// generation is increased on every invalidation so that
+	// values fetched before a write are not cached afterwards.
+	generation atomic.Uint64
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// Stats holds the number of cache hits and misses.
+type Stats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// entry is the encoded value in the store. NotFound is
+// set when the database returned dberr.ErrNotFound, so
+// that missing guilds are cached as well.
+type entry[T any] struct {
+	Value    T
+	NotFound bool `json:",omitempty"`
+}
+
+var _ database.Database = (*Cache)(nil)
+
+// New returns a Cache in front of db which keeps the
+// entries in store for the duration of ttl.
+func New(db database.Database, store Store, ttl time.Duration) *Cache {
+	return &Cache{
+		Database: db,
+		store:    store,
+		ttl:      ttl,
+	}
+}
+
+// Stats returns the number of cache hits and misses
+// since the cache was created.
+func (c *Cache) Stats() Stats {
+	return Stats{
+		Hits:   c.hits.Load(),
+		Misses: c.misses.Load(),
+	}
+}
+
+func (c *Cache) Close() error {
+	stats := c.Stats()
+	log.Debug("Closing database cache", "Hits", stats.Hits, "Misses", stats.Misses)
+
+	return errors.Join(c.store.Close(), c.Database.Close())
+}
+
+// GUILDS
+
+func (c *Cache) GetAutoRoles(ctx context.Context, guildID string) ([]string, error) {
+	return get(ctx, c, key(keyAutoRoles, guildID), func() ([]string, error) {
+		return c.Database.GetAutoRoles(ctx, guildID)
+	})
+}
+
+func (c *Cache) SetAutoRoles(ctx context.Context, guildID string, roleIDs []string) error {
+	if err := c.Database.SetAutoRoles(ctx, guildID, roleIDs); err != nil {
+		return err
+	}
+	return c.invalidate(ctx, key(keyAutoRoles, guildID))
+}
+
+func (c *Cache) GetAutoVoice(ctx context.Context, guildID string) ([]string, error) {
+	return get(ctx, c, key(keyAutoVoice, guildID), func() ([]string, error) {
+		return c.Database.GetAutoVoice(ctx, guildID)
+	})
+}
+
+func (c *Cache) SetAutoVoice(ctx context.Context, guildID string, channelIDs []string) error {
+	if err := c.Database.SetAutoVoice(ctx, guildID, channelIDs); err != nil {
+		return err
+	}
+	return c.invalidate(ctx, key(keyAutoVoice, guildID))
+}
+
+// PERMISSIONS
+
+func (c *Cache) GetPermissions(ctx context.Context, guildID string) (map[string]perms.Array, error) {
+	return get(ctx, c, key(keyPermissions, guildID), func() (map[string]perms.Array, error) {
+		return c.Database.GetPermissions(ctx, guildID)
+	})
+}
+
+func (c *Cache) SetPermissions(ctx context.Context, guildID, roleID string, p perms.Array) error {
+	if err := c.Database.SetPermissions(ctx, guildID, roleID, p); err != nil {
+		return err
+	}
+	return c.invalidate(ctx, key(keyPermissions, guildID))
+}
+
+func (c *Cache) GetUserPermissions(ctx context.Context, guildID string) (map[string]perms.Array, error) {
+	return get(ctx, c, key(keyUserPerms, guildID), func() (map[string]perms.Array, error) {
+		return c.Database.GetUserPermissions(ctx, guildID)
+	})
+}
+
+func (c *Cache) SetUserPermissions(ctx context.Context, guildID, userID string, p perms.Array) error {
+	if err := c.Database.SetUserPermissions(ctx, guildID, userID, p); err != nil {
+		return err
+	}
+	return c.invalidate(ctx, key(keyUserPerms, guildID))
+}
+
+func (c *Cache) GetScopedPermissions(ctx context.Context, guildID string) (database.ScopedPermissions, error) {
+	return get(ctx, c, key(keyScopedPerms, guildID), func() (database.ScopedPermissions, error) {
+		return c.Database.GetScopedPermissions(ctx, guildID)
+	})
+}
+
+func (c *Cache) SetScopedPermissions(ctx context.Context, guildID, scopeID, targetID string, p perms.Array) error {
+	if err := c.Database.SetScopedPermissions(ctx, guildID, scopeID, targetID, p); err != nil {
+		return err
+	}
+	return c.invalidate(ctx, key(keyScopedPerms, guildID))
+}
+
+func (c *Cache) GetPermissionGroups(ctx context.Context, guildID string) (map[string]perms.Array, error) {
+	return get(ctx, c, key(keyPermGroups, guildID), func() (map[string]perms.Array, error) {
+		return c.Database.GetPermissionGroups(ctx, guildID)
+	})
+}
+
+func (c *Cache) SetPermissionGroup(ctx context.Context, guildID, name string, p perms.Array) error {
+	if err := c.Database.SetPermissionGroup(ctx, guildID, name, p); err != nil {
+		return err
+	}
+	return c.invalidate(ctx, key(keyPermGroups, guildID))
+}
+
+// DATA MANAGEMENT
+
+func (c *Cache) FlushGuildData(ctx context.Context, guildID string) error {
+	if err := c.Database.FlushGuildData(ctx, guildID); err != nil {
+		return err
+	}
+	return c.invalidate(ctx,
+		key(keyAutoRoles, guildID),
+		key(keyAutoVoice, guildID),
+		key(keyPermissions, guildID),
+		key(keyUserPerms, guildID),
+		key(keyScopedPerms, guildID),
+		key(keyPermGroups, guildID))
+}
+
+func (c *Cache) ImportGuildData(ctx context.Context, guildID string, data database.GuildData) error {
+	if err := c.Database.ImportGuildData(ctx, guildID, data); err != nil {
+		return err
+	}
+	return c.invalidate(ctx,
+		key(keyAutoRoles, guildID),
+		key(keyAutoVoice, guildID),
+		key(keyPermissions, guildID),
+		key(keyUserPerms, guildID),
+		key(keyScopedPerms, guildID),
+		key(keyPermGroups, guildID))
+}
+
+// DeleteUserData invalidates the user and scoped permissions
+// of all guilds the user had rules in before the deletion.
+func (c *Cache) DeleteUserData(ctx context.Context, userID string) error {
+	data, err := c.Database.GetUserData(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err = c.Database.DeleteUserData(ctx, userID); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(data.Permissions)+len(data.ScopedPermissions))
+	for guildID := range data.Permissions {
+		keys = append(keys, key(keyUserPerms, guildID))
+	}
+	for guildID := range data.ScopedPermissions {
+		keys = append(keys, key(keyScopedPerms, guildID))
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	return c.invalidate(ctx, keys...)
+}
+
+//
+// HELPERS
+//
+
+// invalidate removes the given keys from the store. A failing
+// store is not ignored here because it would keep serving the
+// stale values until they expire.
+func (c *Cache) invalidate(ctx context.Context, keys ...string) error {
+	c.generation.Add(1)
+	if err := c.store.Delete(ctx, keys...); err != nil {
+		log.With(err).Error("Failed invalidating cache entries", "Keys", keys)
+		return err
+	}
+	return nil
+}
+
+// get returns the cached value for key or, on a miss,
+// the value returned by fetch, which is then cached.
+func get[T any](ctx context.Context, c *Cache, key string, fetch func() (T, error)) (T, error) {
+	raw, ok, err := c.store.Get(ctx, key)
+	if err != nil {
+		log.With(err).Warn("Failed reading from cache", "Key", key)
+	}
+
+	if ok {
+		var e entry[T]
+		if err = json.Unmarshal(raw, &e); err == nil {
+			c.hits.Add(1)
+			if e.NotFound {
+				return e.Value, dberr.ErrNotFound
+			}
+			return e.Value, nil
+		}
+		log.With(err).Warn("Failed decoding cache entry", "Key", key)
+	}
+
+	c.misses.Add(1)
+
+	generation := c.generation.Load()
+	value, err := fetch()
+	if err != nil && err != dberr.ErrNotFound {
+		return value, err
+	}
+	if c.generation.Load() != generation {
+		return value, err
+	}
+
+	raw, encErr := json.Marshal(entry[T]{Value: value, NotFound: err == dberr.ErrNotFound})
+	if encErr == nil {
+		encErr = c.store.Set(ctx, key, raw, c.ttl)
+	}
+	if encErr != nil {
+		log.With(encErr).Warn("Failed writing to cache", "Key", key)
+	}
+
+	return value, err
+}
+
+func key(kind, guildID string) string {
+	return "daemon:" + kind + ":" + guildID
+}
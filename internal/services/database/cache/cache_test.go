@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zekurio/daemon/internal/models"
+	"github.com/zekurio/daemon/internal/services/database"
+	"github.com/zekurio/daemon/internal/services/database/dberr"
+	"github.com/zekurio/daemon/internal/services/database/dbtest"
+	"github.com/zekurio/daemon/internal/services/database/memory"
+	"github.com/zekurio/daemon/pkg/perms"
+)
+
+const guildID = "1096747334987161600"
+
+func TestConformance(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T) database.Database {
+		return New(memory.New(), NewLRU(100), time.Minute)
+	})
+}
+
+// The Redis tests require a running Redis instance and are
+// skipped unless DAEMON_TEST_REDIS_ADDR is set. The database
+// given is flushed before each test.
+func TestConformanceRedis(t *testing.T) {
+	addr := os.Getenv("DAEMON_TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("DAEMON_TEST_REDIS_ADDR is not set")
+	}
+
+	dbtest.Run(t, func(t *testing.T) database.Database {
+		r, err := NewRedis(context.Background(), models.RedisConfig{Addr: addr})
+		require.Nil(t, err)
+		require.Nil(t, r.client.FlushDB(context.Background()).Err())
+		return New(memory.New(), r, time.Minute)
+	})
+}
+
+func TestHitsAndMisses(t *testing.T) {
+	ctx := context.Background()
+	db := memory.New()
+	c := New(db, NewLRU(100), time.Minute)
+
+	_, err := c.GetAutoRoles(ctx, guildID)
+	assert.ErrorIs(t, err, dberr.ErrNotFound)
+	_, err = c.GetAutoRoles(ctx, guildID)
+	assert.ErrorIs(t, err, dberr.ErrNotFound)
+	assert.Equal(t, Stats{Hits: 1, Misses: 1}, c.Stats())
+
+	require.Nil(t, c.SetAutoRoles(ctx, guildID, []string{"1"}))
+	roles, err := c.GetAutoRoles(ctx, guildID)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"1"}, roles)
+	assert.Equal(t, Stats{Hits: 1, Misses: 2}, c.Stats())
+
+	// Writes bypassing the cache are not visible until
+	// the entry expired or was invalidated.
+	require.Nil(t, db.SetAutoRoles(ctx, guildID, []string{"2"}))
+	roles, err = c.GetAutoRoles(ctx, guildID)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"1"}, roles)
+	assert.Equal(t, Stats{Hits: 2, Misses: 2}, c.Stats())
+
+	require.Nil(t, c.SetPermissions(ctx, guildID, "10", perms.Array{"+dm.chat.*"}))
+	_, err = c.GetPermissions(ctx, guildID)
+	require.Nil(t, err)
+	require.Nil(t, c.FlushGuildData(ctx, guildID))
+	p, err := c.GetPermissions(ctx, guildID)
+	require.Nil(t, err)
+	assert.Empty(t, p)
+	assert.Equal(t, Stats{Hits: 2, Misses: 4}, c.Stats())
+}
+
+func TestExpiry(t *testing.T) {
+	ctx := context.Background()
+	db := memory.New()
+	c := New(db, NewLRU(100), 10*time.Millisecond)
+
+	require.Nil(t, c.SetAutoVoice(ctx, guildID, []string{"1"}))
+	_, err := c.GetAutoVoice(ctx, guildID)
+	require.Nil(t, err)
+
+	require.Nil(t, db.SetAutoVoice(ctx, guildID, []string{"2"}))
+	time.Sleep(20 * time.Millisecond)
+
+	channels, err := c.GetAutoVoice(ctx, guildID)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"2"}, channels)
+	assert.Equal(t, Stats{Hits: 0, Misses: 2}, c.Stats())
+}
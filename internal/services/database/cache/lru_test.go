@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRUEviction(t *testing.T) {
+	ctx := context.Background()
+	l := NewLRU(2)
+
+	require.Nil(t, l.Set(ctx, "a", []byte("1"), time.Minute))
+	require.Nil(t, l.Set(ctx, "b", []byte("2"), time.Minute))
+
+	// Touching a makes b the least recently used entry.
+	_, ok, _ := l.Get(ctx, "a")
+	assert.True(t, ok)
+
+	require.Nil(t, l.Set(ctx, "c", []byte("3"), time.Minute))
+	assert.Equal(t, 2, l.Len())
+
+	_, ok, _ = l.Get(ctx, "b")
+	assert.False(t, ok)
+
+	v, ok, _ := l.Get(ctx, "a")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("1"), v)
+
+	require.Nil(t, l.Delete(ctx, "a", "c", "d"))
+	assert.Equal(t, 0, l.Len())
+}
+
+func TestLRUExpiry(t *testing.T) {
+	ctx := context.Background()
+	l := NewLRU(2)
+
+	require.Nil(t, l.Set(ctx, "a", []byte("1"), -time.Second))
+	_, ok, _ := l.Get(ctx, "a")
+	assert.False(t, ok)
+	assert.Equal(t, 0, l.Len())
+
+	require.Nil(t, l.Set(ctx, "a", []byte("2"), time.Minute))
+	v, ok, _ := l.Get(ctx, "a")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("2"), v)
+}
@@ -0,0 +1,23 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Store is a key-value store holding the encoded
+// cache entries.
+type Store interface {
+	// Get returns the value stored for key. ok is false
+	// when the key does not exist or has expired.
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+
+	// Set stores value for key, which expires after ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+
+	// Delete removes the given keys from the store.
+	Delete(ctx context.Context, keys ...string) error
+
+	// Close releases all resources held by the store.
+	Close() error
+}
@@ -1,42 +1,204 @@
 package database
 
 import (
+	"context"
+	"time"
+
+	"github.com/zekurio/daemon/internal/services/database/migrations"
 	"github.com/zekurio/daemon/internal/util/autovoice"
 	"github.com/zekurio/daemon/internal/util/vote"
 	"github.com/zekurio/daemon/pkg/perms"
 )
 
 // Database is the interface for our database service
-// which is then implemented by postgres
+// which is then implemented by postgres.
+//
+// All methods but Close take a context which is used
+// to cancel or time out the underlying queries.
 type Database interface {
 	Close() error
 
 	// Guild settings
 
-	GetAutoRoles(guildID string) ([]string, error)
-	SetAutoRoles(guildID string, roleIDs []string) error
+	GetAutoRoles(ctx context.Context, guildID string) ([]string, error)
+	SetAutoRoles(ctx context.Context, guildID string, roleIDs []string) error
 
-	GetAutoVoice(guildID string) ([]string, error)
-	SetAutoVoice(guildID string, channelIDs []string) error
+	GetAutoVoice(ctx context.Context, guildID string) ([]string, error)
+	SetAutoVoice(ctx context.Context, guildID string, channelIDs []string) error
 
 	// Permissions
 
-	GetPermissions(guildID string) (map[string]perms.Array, error)
-	SetPermissions(guildID, roleID string, perms perms.Array) error
+	GetPermissions(ctx context.Context, guildID string) (map[string]perms.Array, error)
+	SetPermissions(ctx context.Context, guildID, roleID string, perms perms.Array) error
+
+	// GetUserPermissions returns the rules bound to single
+	// users of the guild by user ID.
+	GetUserPermissions(ctx context.Context, guildID string) (map[string]perms.Array, error)
+	SetUserPermissions(ctx context.Context, guildID, userID string, perms perms.Array) error
+
+	// GetScopedPermissions returns the rules of the guild which
+	// only apply in a single channel or category.
+	GetScopedPermissions(ctx context.Context, guildID string) (ScopedPermissions, error)
+	// SetScopedPermissions sets the rules of a role or user in the
+	// channel or category scopeID. Empty rules remove the entry.
+	SetScopedPermissions(ctx context.Context, guildID, scopeID, targetID string, perms perms.Array) error
+
+	// GetPermissionGroups returns the rules of the permission
+	// groups of the guild by group name.
+	GetPermissionGroups(ctx context.Context, guildID string) (map[string]perms.Array, error)
+	// SetPermissionGroup sets the rules of the group. Empty
+	// rules remove the group.
+	SetPermissionGroup(ctx context.Context, guildID, name string, perms perms.Array) error
+
+	// GetPermissionGrants returns the pending grants of the guild.
+	GetPermissionGrants(ctx context.Context, guildID string) ([]PermissionGrant, error)
+	GetExpiredPermissionGrants(ctx context.Context, before time.Time) ([]PermissionGrant, error)
+	// AddPermissionGrant adds the grant or updates the expiry
+	// of an existing grant of the same rule.
+	AddPermissionGrant(ctx context.Context, grant PermissionGrant) error
+	DeletePermissionGrant(ctx context.Context, grant PermissionGrant) error
+
+	// GetPermissionHistory returns up to limit changes of the
+	// rules of the guild, newest first, skipping the first offset
+	// ones. When targetID is set, only changes of that role, user
+	// or group are returned.
+	GetPermissionHistory(ctx context.Context, guildID, targetID string, offset, limit int) ([]PermissionChange, error)
+	GetPermissionChange(ctx context.Context, id string) (PermissionChange, error)
+	AddPermissionChange(ctx context.Context, change PermissionChange) error
 
 	// Votes
 
-	GetVotes() (map[string]vote.Vote, error)
-	AddUpdateVote(vote vote.Vote) error
-	DeleteVote(voteID string) error
+	GetVote(ctx context.Context, voteID string) (vote.Vote, error)
+	// GetVotes returns the running, closed and pending votes of
+	// the guild.
+	GetVotes(ctx context.Context, guildID string) (map[string]vote.Vote, error)
+	// GetExpiredVotes returns the running votes which expired
+	// before the given time.
+	GetExpiredVotes(ctx context.Context, before time.Time) (map[string]vote.Vote, error)
+	// GetDueVotes returns the pending votes scheduled to start
+	// before the given time.
+	GetDueVotes(ctx context.Context, before time.Time) (map[string]vote.Vote, error)
+	AddUpdateVote(ctx context.Context, vote vote.Vote) error
+	// SwapVoteMsgID sets the message ID of the vote to newID
+	// if it is oldID. ok is false when the message ID differs
+	// or the vote does not exist.
+	SwapVoteMsgID(ctx context.Context, voteID, oldID, newID string) (ok bool, err error)
+	DeleteVote(ctx context.Context, voteID string) error
 
 	// Auto voice
 
-	GetAVChannels() (map[string]autovoice.AVChannel, error)
-	AddUpdateAVChannel(avc autovoice.AVChannel) error
-	DeleteAVChannel(channelID string) error
+	GetAVChannels(ctx context.Context, guildID string) (map[string]autovoice.AVChannel, error)
+	AddUpdateAVChannel(ctx context.Context, avc autovoice.AVChannel) error
+	DeleteAVChannel(ctx context.Context, channelID string) error
 
 	// Data management
 
-	FlushGuildData(guildID string) error
+	FlushGuildData(ctx context.Context, guildID string) error
+
+	// MarkGuildDeletion schedules the data of the guild to
+	// be flushed after deleteAfter. The mark is removed
+	// by FlushGuildData or UnmarkGuildDeletion.
+	MarkGuildDeletion(ctx context.Context, guildID string, deleteAfter time.Time) error
+	UnmarkGuildDeletion(ctx context.Context, guildID string) error
+	GetDueGuildDeletions(ctx context.Context, before time.Time) ([]string, error)
+
+	// ImportGuildData replaces the auto roles, auto voice
+	// channels, permission rules, groups and grants of the
+	// guild and adds the given votes. Either all data is
+	// applied or none.
+	ImportGuildData(ctx context.Context, guildID string, data GuildData) error
+
+	// GetUserData collects all records linked to the user.
+	GetUserData(ctx context.Context, userID string) (UserData, error)
+
+	// DeleteUserData removes the ticks, permission rules, grants and
+	// history and auto voice channels of the user and anonymizes the
+	// votes created and permission changes made by them.
+	DeleteUserData(ctx context.Context, userID string) error
+}
+
+// Migratable is implemented by databases whose
+// schema is managed by migrations.
+type Migratable interface {
+	Migrator() *migrations.Migrator
+}
+
+// GuildData contains the settings of a guild which
+// can be exported and imported as a whole.
+type GuildData struct {
+	AutoRoles   []string
+	AutoVoice   []string
+	Permissions map[string]perms.Array
+	// UserPermissions are the rules bound to single users.
+	UserPermissions   map[string]perms.Array
+	ScopedPermissions ScopedPermissions
+	PermissionGroups  map[string]perms.Array
+	PermissionGrants  []PermissionGrant
+	Votes             []vote.Vote
+}
+
+// ScopedPermissions maps the ID of a channel or category to
+// the rules bound to roles or users by their ID in it.
+type ScopedPermissions map[string]map[string]perms.Array
+
+const (
+	GrantTargetRole = "role"
+	GrantTargetUser = "user"
+	// ChangeTargetGroup is the target type of changes
+	// of permission groups, which can not be granted.
+	ChangeTargetGroup = "group"
+)
+
+// PermissionGrant is a rule of a role or user which is
+// removed once it expired. Grants are identified by the
+// guild, scope, target and rule.
+type PermissionGrant struct {
+	GuildID string
+	// ScopeID is the channel or category of a scoped rule.
+	ScopeID  string
+	TargetID string
+	// TargetType is either GrantTargetRole or GrantTargetUser.
+	TargetType string
+	Rule       string
+	// ChannelID is the channel the expiry is announced in.
+	ChannelID string
+	Expires   time.Time
+}
+
+// PermissionChange records a change of the rules of a role,
+// user or permission group.
+type PermissionChange struct {
+	ID      string
+	GuildID string
+	// ScopeID is the channel or category of scoped rules.
+	ScopeID string
+	// TargetID is the ID of the role or user or the name
+	// of the group.
+	TargetID string
+	// TargetType is GrantTargetRole, GrantTargetUser or
+	// ChangeTargetGroup.
+	TargetType string
+	// ActorID is the user who made the change, it is empty
+	// once the user deleted their data.
+	ActorID string
+	Old     perms.Array
+	New     perms.Array
+	Created time.Time
+}
+
+// UserData contains all records linked to a single user.
+type UserData struct {
+	// Votes created by the user.
+	Votes []vote.Vote
+	// Picked possibilities of the user by vote ID, in
+	// ranked order for ranked votes.
+	Ticks map[string][]int
+	// Permissions bound to the user by guild ID.
+	Permissions map[string]perms.Array
+	// ScopedPermissions bound to the user by guild ID.
+	ScopedPermissions map[string]ScopedPermissions
+	// PermissionChanges made by the user.
+	PermissionChanges []PermissionChange
+	// AVChannels owned by the user.
+	AVChannels []autovoice.AVChannel
 }
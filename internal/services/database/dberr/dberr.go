@@ -3,5 +3,6 @@ package dberr
 import "errors"
 
 var (
-	ErrNotFound = errors.New("not found")
+	ErrNotFound      = errors.New("not found")
+	ErrUnknownDriver = errors.New("unknown database driver")
 )
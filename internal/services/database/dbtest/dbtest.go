@@ -0,0 +1,1177 @@
+// Package dbtest provides a conformance test suite which
+// every implementation of database.Database must pass.
+package dbtest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zekurio/daemon/internal/services/database"
+	"github.com/zekurio/daemon/internal/services/database/dberr"
+	"github.com/zekurio/daemon/internal/util/autovoice"
+	"github.com/zekurio/daemon/internal/util/vote"
+	"github.com/zekurio/daemon/pkg/perms"
+)
+
+const (
+	guildID      = "1096747334987161600"
+	otherGuildID = "1096747334987161601"
+)
+
+// Factory returns a new, empty database instance for the
+// given test. Instances are closed after the test finished.
+type Factory func(t *testing.T) database.Database
+
+// Run executes the conformance test suite against fresh
+// database instances created by newDB.
+func Run(t *testing.T, newDB Factory) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, db database.Database)
+	}{
+		{"AutoRoles", testAutoRoles},
+		{"AutoVoice", testAutoVoice},
+		{"Permissions", testPermissions},
+		{"UserPermissions", testUserPermissions},
+		{"ScopedPermissions", testScopedPermissions},
+		{"PermissionGroups", testPermissionGroups},
+		{"PermissionGrants", testPermissionGrants},
+		{"PermissionHistory", testPermissionHistory},
+		{"Votes", testVotes},
+		{"AVChannels", testAVChannels},
+		{"FlushGuildData", testFlushGuildData},
+		{"GuildDeletions", testGuildDeletions},
+		{"ImportGuildData", testImportGuildData},
+		{"UserData", testUserData},
+		{"Concurrency", testConcurrency},
+		{"CanceledContext", testCanceledContext},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newDB(t)
+			t.Cleanup(func() {
+				assert.Nil(t, db.Close())
+			})
+			tt.run(t, db)
+		})
+	}
+}
+
+func testAutoRoles(t *testing.T, db database.Database) {
+	ctx := context.Background()
+
+	roles, err := db.GetAutoRoles(ctx, guildID)
+	assert.ErrorIs(t, err, dberr.ErrNotFound)
+	assert.Empty(t, roles)
+
+	require.Nil(t, db.SetAutoRoles(ctx, guildID, []string{"1", "2"}))
+	roles, err = db.GetAutoRoles(ctx, guildID)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"1", "2"}, roles)
+
+	require.Nil(t, db.SetAutoRoles(ctx, guildID, []string{"3"}))
+	roles, err = db.GetAutoRoles(ctx, guildID)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"3"}, roles)
+
+	require.Nil(t, db.SetAutoRoles(ctx, guildID, []string{}))
+	roles, err = db.GetAutoRoles(ctx, guildID)
+	if err != nil {
+		assert.ErrorIs(t, err, dberr.ErrNotFound)
+	}
+	assert.Empty(t, roles)
+
+	roles, err = db.GetAutoRoles(ctx, otherGuildID)
+	assert.ErrorIs(t, err, dberr.ErrNotFound)
+	assert.Empty(t, roles)
+}
+
+func testAutoVoice(t *testing.T, db database.Database) {
+	ctx := context.Background()
+
+	channels, err := db.GetAutoVoice(ctx, guildID)
+	assert.ErrorIs(t, err, dberr.ErrNotFound)
+	assert.Empty(t, channels)
+
+	require.Nil(t, db.SetAutoVoice(ctx, guildID, []string{"1", "2"}))
+	channels, err = db.GetAutoVoice(ctx, guildID)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"1", "2"}, channels)
+
+	require.Nil(t, db.SetAutoVoice(ctx, guildID, []string{"3"}))
+	channels, err = db.GetAutoVoice(ctx, guildID)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"3"}, channels)
+
+	// Auto roles and auto voice share the guild row in some
+	// drivers, so make sure they do not overwrite each other.
+	require.Nil(t, db.SetAutoRoles(ctx, guildID, []string{"4"}))
+	channels, err = db.GetAutoVoice(ctx, guildID)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"3"}, channels)
+
+	channels, err = db.GetAutoVoice(ctx, otherGuildID)
+	assert.ErrorIs(t, err, dberr.ErrNotFound)
+	assert.Empty(t, channels)
+}
+
+func testPermissions(t *testing.T, db database.Database) {
+	ctx := context.Background()
+
+	p, err := db.GetPermissions(ctx, guildID)
+	require.Nil(t, err)
+	assert.Empty(t, p)
+
+	require.Nil(t, db.SetPermissions(ctx, guildID, "10", perms.Array{"+dm.chat.*", "-dm.chat.vote"}))
+	require.Nil(t, db.SetPermissions(ctx, guildID, "11", perms.Array{"+dm.guild.*"}))
+	require.Nil(t, db.SetPermissions(ctx, otherGuildID, "20", perms.Array{"+dm.etc.*"}))
+
+	p, err = db.GetPermissions(ctx, guildID)
+	require.Nil(t, err)
+	assert.Equal(t, map[string]perms.Array{
+		"10": {"+dm.chat.*", "-dm.chat.vote"},
+		"11": {"+dm.guild.*"},
+	}, p)
+
+	require.Nil(t, db.SetPermissions(ctx, guildID, "10", perms.Array{"+dm.chat.vote"}))
+	p, err = db.GetPermissions(ctx, guildID)
+	require.Nil(t, err)
+	assert.Equal(t, perms.Array{"+dm.chat.vote"}, p["10"])
+
+	require.Nil(t, db.SetPermissions(ctx, guildID, "11", perms.Array{}))
+	p, err = db.GetPermissions(ctx, guildID)
+	require.Nil(t, err)
+	assert.Equal(t, map[string]perms.Array{
+		"10": {"+dm.chat.vote"},
+	}, p)
+
+	p, err = db.GetPermissions(ctx, otherGuildID)
+	require.Nil(t, err)
+	assert.Equal(t, map[string]perms.Array{
+		"20": {"+dm.etc.*"},
+	}, p)
+}
+
+func testUserPermissions(t *testing.T, db database.Database) {
+	ctx := context.Background()
+
+	p, err := db.GetUserPermissions(ctx, guildID)
+	require.Nil(t, err)
+	assert.Empty(t, p)
+
+	require.Nil(t, db.SetUserPermissions(ctx, guildID, "40", perms.Array{"+dm.chat.*", "-dm.chat.vote"}))
+	require.Nil(t, db.SetUserPermissions(ctx, guildID, "41", perms.Array{"+dm.guild.*"}))
+	require.Nil(t, db.SetUserPermissions(ctx, otherGuildID, "40", perms.Array{"+dm.etc.*"}))
+
+	// Rules of users and roles are stored separately.
+	roles, err := db.GetPermissions(ctx, guildID)
+	require.Nil(t, err)
+	assert.Empty(t, roles)
+
+	p, err = db.GetUserPermissions(ctx, guildID)
+	require.Nil(t, err)
+	assert.Equal(t, map[string]perms.Array{
+		"40": {"+dm.chat.*", "-dm.chat.vote"},
+		"41": {"+dm.guild.*"},
+	}, p)
+
+	require.Nil(t, db.SetUserPermissions(ctx, guildID, "40", perms.Array{"+dm.chat.vote"}))
+	require.Nil(t, db.SetUserPermissions(ctx, guildID, "41", perms.Array{}))
+	p, err = db.GetUserPermissions(ctx, guildID)
+	require.Nil(t, err)
+	assert.Equal(t, map[string]perms.Array{
+		"40": {"+dm.chat.vote"},
+	}, p)
+
+	p, err = db.GetUserPermissions(ctx, otherGuildID)
+	require.Nil(t, err)
+	assert.Equal(t, map[string]perms.Array{
+		"40": {"+dm.etc.*"},
+	}, p)
+}
+
+func testPermissionGroups(t *testing.T, db database.Database) {
+	ctx := context.Background()
+
+	g, err := db.GetPermissionGroups(ctx, guildID)
+	require.Nil(t, err)
+	assert.Empty(t, g)
+
+	require.Nil(t, db.SetPermissionGroup(ctx, guildID, "moderator", perms.Array{"+dm.guild.*", "-dm.guild.config.*"}))
+	require.Nil(t, db.SetPermissionGroup(ctx, guildID, "event-host", perms.Array{"+dm.chat.vote"}))
+	require.Nil(t, db.SetPermissionGroup(ctx, otherGuildID, "moderator", perms.Array{"+dm.etc.*"}))
+
+	g, err = db.GetPermissionGroups(ctx, guildID)
+	require.Nil(t, err)
+	assert.Equal(t, map[string]perms.Array{
+		"moderator":  {"+dm.guild.*", "-dm.guild.config.*"},
+		"event-host": {"+dm.chat.vote"},
+	}, g)
+
+	require.Nil(t, db.SetPermissionGroup(ctx, guildID, "moderator", perms.Array{"+dm.guild.*"}))
+	require.Nil(t, db.SetPermissionGroup(ctx, guildID, "event-host", nil))
+	g, err = db.GetPermissionGroups(ctx, guildID)
+	require.Nil(t, err)
+	assert.Equal(t, map[string]perms.Array{
+		"moderator": {"+dm.guild.*"},
+	}, g)
+
+	g, err = db.GetPermissionGroups(ctx, otherGuildID)
+	require.Nil(t, err)
+	assert.Equal(t, map[string]perms.Array{
+		"moderator": {"+dm.etc.*"},
+	}, g)
+}
+
+func testScopedPermissions(t *testing.T, db database.Database) {
+	ctx := context.Background()
+
+	p, err := db.GetScopedPermissions(ctx, guildID)
+	require.Nil(t, err)
+	assert.Empty(t, p)
+
+	require.Nil(t, db.SetScopedPermissions(ctx, guildID, "50", "role-a", perms.Array{"+dm.chat.vote"}))
+	require.Nil(t, db.SetScopedPermissions(ctx, guildID, "50", "40", perms.Array{"-dm.chat.vote"}))
+	require.Nil(t, db.SetScopedPermissions(ctx, guildID, "51", "role-a", perms.Array{"+dm.chat.*"}))
+	require.Nil(t, db.SetScopedPermissions(ctx, otherGuildID, "50", "role-a", perms.Array{"+dm.etc.*"}))
+
+	// Scoped rules do not leak into the guild wide rules.
+	roles, err := db.GetPermissions(ctx, guildID)
+	require.Nil(t, err)
+	assert.Empty(t, roles)
+
+	p, err = db.GetScopedPermissions(ctx, guildID)
+	require.Nil(t, err)
+	assert.Equal(t, database.ScopedPermissions{
+		"50": {"role-a": {"+dm.chat.vote"}, "40": {"-dm.chat.vote"}},
+		"51": {"role-a": {"+dm.chat.*"}},
+	}, p)
+
+	require.Nil(t, db.SetScopedPermissions(ctx, guildID, "50", "40", perms.Array{"+dm.chat.vote"}))
+	require.Nil(t, db.SetScopedPermissions(ctx, guildID, "51", "role-a", perms.Array{}))
+	p, err = db.GetScopedPermissions(ctx, guildID)
+	require.Nil(t, err)
+	assert.Equal(t, database.ScopedPermissions{
+		"50": {"role-a": {"+dm.chat.vote"}, "40": {"+dm.chat.vote"}},
+	}, p)
+
+	p, err = db.GetScopedPermissions(ctx, otherGuildID)
+	require.Nil(t, err)
+	assert.Equal(t, database.ScopedPermissions{
+		"50": {"role-a": {"+dm.etc.*"}},
+	}, p)
+}
+
+func testPermissionGrants(t *testing.T, db database.Database) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+
+	grants, err := db.GetPermissionGrants(ctx, guildID)
+	require.Nil(t, err)
+	assert.Empty(t, grants)
+
+	roleGrant := database.PermissionGrant{
+		GuildID:    guildID,
+		TargetID:   "role-a",
+		TargetType: database.GrantTargetRole,
+		Rule:       "+dm.chat.vote",
+		ChannelID:  "60",
+		Expires:    now.Add(-time.Minute),
+	}
+	userGrant := database.PermissionGrant{
+		GuildID:    guildID,
+		ScopeID:    "50",
+		TargetID:   "40",
+		TargetType: database.GrantTargetUser,
+		Rule:       "+dm.chat.*",
+		ChannelID:  "60",
+		Expires:    now.Add(time.Hour),
+	}
+	otherGrant := roleGrant
+	otherGrant.GuildID = otherGuildID
+	otherGrant.Expires = now.Add(-time.Hour)
+
+	require.Nil(t, db.AddPermissionGrant(ctx, roleGrant))
+	require.Nil(t, db.AddPermissionGrant(ctx, userGrant))
+	require.Nil(t, db.AddPermissionGrant(ctx, otherGrant))
+
+	grants, err = db.GetPermissionGrants(ctx, guildID)
+	require.Nil(t, err)
+	require.Len(t, grants, 2)
+	assertGrantEqual(t, roleGrant, grants[0])
+	assertGrantEqual(t, userGrant, grants[1])
+
+	expired, err := db.GetExpiredPermissionGrants(ctx, now)
+	require.Nil(t, err)
+	require.Len(t, expired, 2)
+	assertGrantEqual(t, otherGrant, expired[0])
+	assertGrantEqual(t, roleGrant, expired[1])
+
+	// Adding the same rule again only updates the expiry.
+	roleGrant.Expires = now.Add(2 * time.Hour)
+	require.Nil(t, db.AddPermissionGrant(ctx, roleGrant))
+
+	expired, err = db.GetExpiredPermissionGrants(ctx, now)
+	require.Nil(t, err)
+	require.Len(t, expired, 1)
+	assertGrantEqual(t, otherGrant, expired[0])
+
+	require.Nil(t, db.DeletePermissionGrant(ctx, userGrant))
+	grants, err = db.GetPermissionGrants(ctx, guildID)
+	require.Nil(t, err)
+	require.Len(t, grants, 1)
+	assertGrantEqual(t, roleGrant, grants[0])
+
+	assert.Nil(t, db.DeletePermissionGrant(ctx, userGrant))
+}
+
+func testPermissionHistory(t *testing.T, db database.Database) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+
+	changes, err := db.GetPermissionHistory(ctx, guildID, "", 0, 10)
+	require.Nil(t, err)
+	assert.Empty(t, changes)
+
+	_, err = db.GetPermissionChange(ctx, "1100000000000000301")
+	assert.ErrorIs(t, err, dberr.ErrNotFound)
+
+	created := database.PermissionChange{
+		ID:         "1100000000000000301",
+		GuildID:    guildID,
+		TargetID:   "role-a",
+		TargetType: database.GrantTargetRole,
+		ActorID:    "40",
+		Old:        perms.Array{},
+		New:        perms.Array{"+dm.chat.*"},
+		Created:    now.Add(-time.Hour),
+	}
+	updated := database.PermissionChange{
+		ID:         "1100000000000000302",
+		GuildID:    guildID,
+		TargetID:   "role-a",
+		TargetType: database.GrantTargetRole,
+		ActorID:    "41",
+		Old:        perms.Array{"+dm.chat.*"},
+		New:        perms.Array{"+dm.chat.*", "-dm.chat.vote"},
+		Created:    now.Add(-time.Minute),
+	}
+	scoped := database.PermissionChange{
+		ID:         "1100000000000000303",
+		GuildID:    guildID,
+		ScopeID:    "50",
+		TargetID:   "40",
+		TargetType: database.GrantTargetUser,
+		ActorID:    "41",
+		Old:        perms.Array{"+dm.guild.*"},
+		New:        perms.Array{},
+		Created:    now,
+	}
+	other := created
+	other.ID = "1100000000000000304"
+	other.GuildID = otherGuildID
+
+	for _, c := range []database.PermissionChange{updated, scoped, created, other} {
+		require.Nil(t, db.AddPermissionChange(ctx, c))
+	}
+
+	changes, err = db.GetPermissionHistory(ctx, guildID, "", 0, 10)
+	require.Nil(t, err)
+	require.Len(t, changes, 3)
+	assertChangeEqual(t, scoped, changes[0])
+	assertChangeEqual(t, updated, changes[1])
+	assertChangeEqual(t, created, changes[2])
+
+	changes, err = db.GetPermissionHistory(ctx, guildID, "", 1, 1)
+	require.Nil(t, err)
+	require.Len(t, changes, 1)
+	assertChangeEqual(t, updated, changes[0])
+
+	changes, err = db.GetPermissionHistory(ctx, guildID, "role-a", 0, 10)
+	require.Nil(t, err)
+	require.Len(t, changes, 2)
+	assertChangeEqual(t, updated, changes[0])
+	assertChangeEqual(t, created, changes[1])
+
+	changes, err = db.GetPermissionHistory(ctx, guildID, "role-a", 2, 10)
+	require.Nil(t, err)
+	assert.Empty(t, changes)
+
+	c, err := db.GetPermissionChange(ctx, other.ID)
+	require.Nil(t, err)
+	assertChangeEqual(t, other, c)
+}
+
+func testVotes(t *testing.T, db database.Database) {
+	ctx := context.Background()
+
+	votes, err := db.GetVotes(ctx, guildID)
+	require.Nil(t, err)
+	assert.Empty(t, votes)
+
+	_, err = db.GetVote(ctx, "1100000000000000001")
+	assert.ErrorIs(t, err, dberr.ErrNotFound)
+
+	v := newVote("1100000000000000001", guildID)
+	require.Nil(t, db.AddUpdateVote(ctx, v))
+
+	other := newVote("1100000000000000002", otherGuildID)
+	other.Expires = time.Time{}
+	require.Nil(t, db.AddUpdateVote(ctx, other))
+
+	votes, err = db.GetVotes(ctx, guildID)
+	require.Nil(t, err)
+	require.Len(t, votes, 1)
+	require.Contains(t, votes, v.ID)
+	assertVoteEqual(t, v, votes[v.ID])
+
+	v.Ticks["hash-a"] = &vote.Tick{UserID: "hash-a", Tick: 0}
+	v.Ticks["hash-b"] = &vote.Tick{UserID: "hash-b", Tick: 1}
+	require.Nil(t, db.AddUpdateVote(ctx, v))
+
+	got, err := db.GetVote(ctx, v.ID)
+	require.Nil(t, err)
+	assertVoteEqual(t, v, got)
+
+	// Mutating a returned vote must not alter the stored one.
+	got.Ticks["hash-c"] = &vote.Tick{UserID: "hash-c", Tick: 2}
+	got, err = db.GetVote(ctx, v.ID)
+	require.Nil(t, err)
+	assert.Len(t, got.Ticks, 2)
+
+	delete(v.Ticks, "hash-a")
+	v.Ticks["hash-b"].Tick = 0
+	require.Nil(t, db.AddUpdateVote(ctx, v))
+
+	got, err = db.GetVote(ctx, v.ID)
+	require.Nil(t, err)
+	assertVoteEqual(t, v, got)
+
+	got, err = db.GetVote(ctx, other.ID)
+	require.Nil(t, err)
+	assertVoteEqual(t, other, got)
+
+	// Ranked choices including unset ranks survive the
+	// round trip.
+	ranked := newVote("1100000000000000003", guildID)
+	ranked.Mode = vote.ModeRanked
+	ranked.MaxChoices = 3
+	ranked.Possibilities = []string{"pizza", "pasta", "salad"}
+	ranked.Ticks["hash-a"] = &vote.Tick{UserID: "hash-a", Tick: 2, Choices: []int{2, -1, 0}}
+	require.Nil(t, db.AddUpdateVote(ctx, ranked))
+
+	got, err = db.GetVote(ctx, ranked.ID)
+	require.Nil(t, err)
+	assertVoteEqual(t, ranked, got)
+
+	got.Ticks["hash-a"].Choices[0] = 1
+	got, err = db.GetVote(ctx, ranked.ID)
+	require.Nil(t, err)
+	assert.Equal(t, []int{2, -1, 0}, got.Ticks["hash-a"].Choices)
+	require.Nil(t, db.DeleteVote(ctx, ranked.ID))
+
+	restricted := newVote("1100000000000000004", guildID)
+	restricted.Roles = []string{"1100000000000000500", "1100000000000000501"}
+	restricted.Weights = map[string]int{"1100000000000000500": 3, "1100000000000000502": 2}
+	restricted.Ticks["hash-a"] = &vote.Tick{UserID: "hash-a", Tick: 1, Weight: 3}
+	require.Nil(t, db.AddUpdateVote(ctx, restricted))
+
+	got, err = db.GetVote(ctx, restricted.ID)
+	require.Nil(t, err)
+	assertVoteEqual(t, restricted, got)
+
+	got.Weights["1100000000000000500"] = 1
+	got, err = db.GetVote(ctx, restricted.ID)
+	require.Nil(t, err)
+	assert.Equal(t, 3, got.Weights["1100000000000000500"])
+	require.Nil(t, db.DeleteVote(ctx, restricted.ID))
+
+	// Scheduled votes have no message until they start and
+	// do not expire before.
+	scheduled := newVote("1100000000000000005", guildID)
+	scheduled.MsgID = ""
+	scheduled.Starts = v.Expires.Add(-30 * time.Minute)
+	scheduled.Expires = v.Expires
+	require.Nil(t, db.AddUpdateVote(ctx, scheduled))
+
+	got, err = db.GetVote(ctx, scheduled.ID)
+	require.Nil(t, err)
+	assertVoteEqual(t, scheduled, got)
+
+	due, err := db.GetDueVotes(ctx, scheduled.Starts.Add(-time.Minute))
+	require.Nil(t, err)
+	assert.Empty(t, due)
+
+	due, err = db.GetDueVotes(ctx, scheduled.Starts.Add(time.Minute))
+	require.Nil(t, err)
+	require.Len(t, due, 1)
+	assert.Contains(t, due, scheduled.ID)
+
+	expired, err := db.GetExpiredVotes(ctx, v.Expires.Add(time.Minute))
+	require.Nil(t, err)
+	assert.NotContains(t, expired, scheduled.ID)
+
+	// A scheduled vote is claimed only once before it is
+	// posted and is neither due nor running while claimed.
+	ok, err := db.SwapVoteMsgID(ctx, scheduled.ID, "", vote.MsgIDPosting)
+	require.Nil(t, err)
+	assert.True(t, ok)
+
+	ok, err = db.SwapVoteMsgID(ctx, scheduled.ID, "", vote.MsgIDPosting)
+	require.Nil(t, err)
+	assert.False(t, ok)
+
+	due, err = db.GetDueVotes(ctx, scheduled.Starts.Add(time.Minute))
+	require.Nil(t, err)
+	assert.Empty(t, due)
+
+	expired, err = db.GetExpiredVotes(ctx, v.Expires.Add(time.Minute))
+	require.Nil(t, err)
+	assert.NotContains(t, expired, scheduled.ID)
+
+	ok, err = db.SwapVoteMsgID(ctx, scheduled.ID, vote.MsgIDPosting, "")
+	require.Nil(t, err)
+	assert.True(t, ok)
+
+	due, err = db.GetDueVotes(ctx, scheduled.Starts.Add(time.Minute))
+	require.Nil(t, err)
+	assert.Contains(t, due, scheduled.ID)
+
+	ok, err = db.SwapVoteMsgID(ctx, "1100000000000000009", "", vote.MsgIDPosting)
+	require.Nil(t, err)
+	assert.False(t, ok)
+
+	scheduled.MsgID = "1100000000000000101"
+	require.Nil(t, db.AddUpdateVote(ctx, scheduled))
+
+	due, err = db.GetDueVotes(ctx, scheduled.Starts.Add(time.Minute))
+	require.Nil(t, err)
+	assert.Empty(t, due)
+	require.Nil(t, db.DeleteVote(ctx, scheduled.ID))
+
+	expired, err = db.GetExpiredVotes(ctx, v.Expires.Add(-time.Minute))
+	require.Nil(t, err)
+	assert.Empty(t, expired)
+
+	expired, err = db.GetExpiredVotes(ctx, v.Expires.Add(time.Minute))
+	require.Nil(t, err)
+	require.Len(t, expired, 1)
+	assert.Contains(t, expired, v.ID)
+
+	// Closed votes are kept with their results but do not
+	// expire again.
+	v.Closed = v.Expires.Add(time.Second)
+	v.Ticks["hash-b"].Time = v.Expires.Add(-time.Hour)
+	require.Nil(t, db.AddUpdateVote(ctx, v))
+
+	got, err = db.GetVote(ctx, v.ID)
+	require.Nil(t, err)
+	assertVoteEqual(t, v, got)
+
+	expired, err = db.GetExpiredVotes(ctx, v.Expires.Add(time.Minute))
+	require.Nil(t, err)
+	assert.Empty(t, expired)
+
+	votes, err = db.GetVotes(ctx, guildID)
+	require.Nil(t, err)
+	assert.Contains(t, votes, v.ID)
+
+	require.Nil(t, db.DeleteVote(ctx, v.ID))
+	votes, err = db.GetVotes(ctx, guildID)
+	require.Nil(t, err)
+	assert.Empty(t, votes)
+
+	_, err = db.GetVote(ctx, v.ID)
+	assert.ErrorIs(t, err, dberr.ErrNotFound)
+
+	assert.Nil(t, db.DeleteVote(ctx, "1100000000000000009"))
+}
+
+func testAVChannels(t *testing.T, db database.Database) {
+	ctx := context.Background()
+
+	avs, err := db.GetAVChannels(ctx, guildID)
+	require.Nil(t, err)
+	assert.Empty(t, avs)
+
+	av := autovoice.AVChannel{
+		GuildID:          guildID,
+		OwnerID:          "30",
+		OriginChannelID:  "31",
+		CreatedChannelID: "32",
+	}
+	require.Nil(t, db.AddUpdateAVChannel(ctx, av))
+
+	otherAv := autovoice.AVChannel{
+		GuildID:          otherGuildID,
+		OwnerID:          "40",
+		OriginChannelID:  "41",
+		CreatedChannelID: "42",
+	}
+	require.Nil(t, db.AddUpdateAVChannel(ctx, otherAv))
+
+	avs, err = db.GetAVChannels(ctx, guildID)
+	require.Nil(t, err)
+	assert.Equal(t, map[string]autovoice.AVChannel{"32": av}, avs)
+
+	av.OwnerID = "33"
+	require.Nil(t, db.AddUpdateAVChannel(ctx, av))
+
+	avs, err = db.GetAVChannels(ctx, guildID)
+	require.Nil(t, err)
+	assert.Equal(t, map[string]autovoice.AVChannel{"32": av}, avs)
+
+	require.Nil(t, db.DeleteAVChannel(ctx, av.CreatedChannelID))
+	avs, err = db.GetAVChannels(ctx, guildID)
+	require.Nil(t, err)
+	assert.Empty(t, avs)
+
+	avs, err = db.GetAVChannels(ctx, otherGuildID)
+	require.Nil(t, err)
+	assert.Equal(t, map[string]autovoice.AVChannel{"42": otherAv}, avs)
+
+	assert.Nil(t, db.DeleteAVChannel(ctx, "39"))
+}
+
+func testFlushGuildData(t *testing.T, db database.Database) {
+	ctx := context.Background()
+
+	for i, gid := range []string{guildID, otherGuildID} {
+		require.Nil(t, db.SetAutoRoles(ctx, gid, []string{"1"}))
+		require.Nil(t, db.SetAutoVoice(ctx, gid, []string{"2"}))
+		require.Nil(t, db.SetPermissions(ctx, gid, "role-"+gid, perms.Array{"+dm.*"}))
+		require.Nil(t, db.SetUserPermissions(ctx, gid, "40", perms.Array{"+dm.*"}))
+		require.Nil(t, db.SetScopedPermissions(ctx, gid, "50", "40", perms.Array{"+dm.*"}))
+		require.Nil(t, db.SetPermissionGroup(ctx, gid, "moderator", perms.Array{"+dm.*"}))
+		require.Nil(t, db.AddPermissionChange(ctx, database.PermissionChange{
+			ID:         fmt.Sprintf("110000000000000030%d", i),
+			GuildID:    gid,
+			TargetID:   "role-" + gid,
+			TargetType: database.GrantTargetRole,
+			New:        perms.Array{"+dm.*"},
+			Created:    time.Now(),
+		}))
+		require.Nil(t, db.AddPermissionGrant(ctx, database.PermissionGrant{
+			GuildID:    gid,
+			TargetID:   "40",
+			TargetType: database.GrantTargetUser,
+			Rule:       "+dm.*",
+			Expires:    time.Now(),
+		}))
+		_, err := db.GetUserPermissions(ctx, gid)
+		require.Nil(t, err)
+		_, err = db.GetScopedPermissions(ctx, gid)
+		require.Nil(t, err)
+
+		v := newVote(fmt.Sprintf("110000000000000000%d", i), gid)
+		v.Ticks["hash-a"] = &vote.Tick{UserID: "hash-a", Tick: 1}
+		require.Nil(t, db.AddUpdateVote(ctx, v))
+
+		require.Nil(t, db.AddUpdateAVChannel(ctx, autovoice.AVChannel{
+			GuildID:          gid,
+			OwnerID:          "30",
+			OriginChannelID:  "31",
+			CreatedChannelID: fmt.Sprintf("32%d", i),
+		}))
+
+		require.Nil(t, db.MarkGuildDeletion(ctx, gid, time.Now()))
+	}
+
+	require.Nil(t, db.FlushGuildData(ctx, guildID))
+
+	roles, err := db.GetAutoRoles(ctx, guildID)
+	if err != nil {
+		assert.ErrorIs(t, err, dberr.ErrNotFound)
+	}
+	assert.Empty(t, roles)
+
+	channels, err := db.GetAutoVoice(ctx, guildID)
+	if err != nil {
+		assert.ErrorIs(t, err, dberr.ErrNotFound)
+	}
+	assert.Empty(t, channels)
+
+	p, err := db.GetPermissions(ctx, guildID)
+	require.Nil(t, err)
+	assert.Empty(t, p)
+
+	p, err = db.GetUserPermissions(ctx, guildID)
+	require.Nil(t, err)
+	assert.Empty(t, p)
+
+	sp, err := db.GetScopedPermissions(ctx, guildID)
+	require.Nil(t, err)
+	assert.Empty(t, sp)
+
+	p, err = db.GetPermissionGroups(ctx, guildID)
+	require.Nil(t, err)
+	assert.Empty(t, p)
+
+	grants, err := db.GetPermissionGrants(ctx, guildID)
+	require.Nil(t, err)
+	assert.Empty(t, grants)
+
+	changes, err := db.GetPermissionHistory(ctx, guildID, "", 0, 10)
+	require.Nil(t, err)
+	assert.Empty(t, changes)
+
+	changes, err = db.GetPermissionHistory(ctx, otherGuildID, "", 0, 10)
+	require.Nil(t, err)
+	assert.Len(t, changes, 1)
+
+	votes, err := db.GetVotes(ctx, guildID)
+	require.Nil(t, err)
+	assert.Empty(t, votes)
+
+	avs, err := db.GetAVChannels(ctx, guildID)
+	require.Nil(t, err)
+	assert.Empty(t, avs)
+
+	due, err := db.GetDueGuildDeletions(ctx, time.Now().Add(time.Minute))
+	require.Nil(t, err)
+	assert.Equal(t, []string{otherGuildID}, due)
+
+	roles, err = db.GetAutoRoles(ctx, otherGuildID)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"1"}, roles)
+
+	p, err = db.GetPermissions(ctx, otherGuildID)
+	require.Nil(t, err)
+	assert.Len(t, p, 1)
+
+	p, err = db.GetUserPermissions(ctx, otherGuildID)
+	require.Nil(t, err)
+	assert.Len(t, p, 1)
+
+	sp, err = db.GetScopedPermissions(ctx, otherGuildID)
+	require.Nil(t, err)
+	assert.Len(t, sp, 1)
+
+	grants, err = db.GetPermissionGrants(ctx, otherGuildID)
+	require.Nil(t, err)
+	assert.Len(t, grants, 1)
+
+	votes, err = db.GetVotes(ctx, otherGuildID)
+	require.Nil(t, err)
+	assert.Len(t, votes, 1)
+
+	avs, err = db.GetAVChannels(ctx, otherGuildID)
+	require.Nil(t, err)
+	assert.Len(t, avs, 1)
+
+	assert.Nil(t, db.FlushGuildData(ctx, "1096747334987161699"))
+}
+
+func testGuildDeletions(t *testing.T, db database.Database) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+
+	due, err := db.GetDueGuildDeletions(ctx, now)
+	require.Nil(t, err)
+	assert.Empty(t, due)
+
+	require.Nil(t, db.MarkGuildDeletion(ctx, guildID, now.Add(time.Hour)))
+	require.Nil(t, db.MarkGuildDeletion(ctx, otherGuildID, now.Add(2*time.Hour)))
+
+	due, err = db.GetDueGuildDeletions(ctx, now)
+	require.Nil(t, err)
+	assert.Empty(t, due)
+
+	due, err = db.GetDueGuildDeletions(ctx, now.Add(90*time.Minute))
+	require.Nil(t, err)
+	assert.Equal(t, []string{guildID}, due)
+
+	// Marking again moves the deletion date.
+	require.Nil(t, db.MarkGuildDeletion(ctx, guildID, now.Add(3*time.Hour)))
+	due, err = db.GetDueGuildDeletions(ctx, now.Add(90*time.Minute))
+	require.Nil(t, err)
+	assert.Empty(t, due)
+
+	require.Nil(t, db.UnmarkGuildDeletion(ctx, otherGuildID))
+	due, err = db.GetDueGuildDeletions(ctx, now.Add(4*time.Hour))
+	require.Nil(t, err)
+	assert.Equal(t, []string{guildID}, due)
+
+	assert.Nil(t, db.UnmarkGuildDeletion(ctx, "1096747334987161699"))
+}
+
+func testImportGuildData(t *testing.T, db database.Database) {
+	ctx := context.Background()
+
+	require.Nil(t, db.SetAutoRoles(ctx, guildID, []string{"1"}))
+	require.Nil(t, db.SetPermissions(ctx, guildID, "10", perms.Array{"+dm.chat.*"}))
+	require.Nil(t, db.SetPermissions(ctx, otherGuildID, "20", perms.Array{"+dm.etc.*"}))
+	require.Nil(t, db.SetUserPermissions(ctx, guildID, "30", perms.Array{"+dm.chat.*"}))
+	require.Nil(t, db.SetUserPermissions(ctx, otherGuildID, "30", perms.Array{"+dm.etc.*"}))
+	require.Nil(t, db.SetScopedPermissions(ctx, guildID, "50", "10", perms.Array{"-dm.chat.*"}))
+	require.Nil(t, db.SetPermissionGroup(ctx, guildID, "old", perms.Array{"+dm.chat.*"}))
+	require.Nil(t, db.AddPermissionGrant(ctx, database.PermissionGrant{
+		GuildID:    guildID,
+		TargetID:   "10",
+		TargetType: database.GrantTargetRole,
+		Rule:       "+dm.chat.*",
+		Expires:    time.Now().Add(time.Hour).Truncate(time.Second),
+	}))
+
+	existing := newVote("1100000000000000001", guildID)
+	require.Nil(t, db.AddUpdateVote(ctx, existing))
+
+	imported := newVote("1100000000000000002", guildID)
+	imported.Ticks["hash-a"] = &vote.Tick{UserID: "hash-a", Tick: 1}
+
+	grant := database.PermissionGrant{
+		GuildID:    guildID,
+		ScopeID:    "51",
+		TargetID:   "31",
+		TargetType: database.GrantTargetUser,
+		Rule:       "+dm.guild.*",
+		ChannelID:  "4",
+		Expires:    time.Now().Add(2 * time.Hour).Truncate(time.Second),
+	}
+
+	require.Nil(t, db.ImportGuildData(ctx, guildID, database.GuildData{
+		AutoRoles: []string{"2", "3"},
+		AutoVoice: []string{"4"},
+		Permissions: map[string]perms.Array{
+			"11": {"+dm.guild.*"},
+			"12": {},
+		},
+		UserPermissions: map[string]perms.Array{
+			"31": {"-dm.chat.vote"},
+			"32": {},
+		},
+		ScopedPermissions: database.ScopedPermissions{
+			"51": {"11": {"+dm.chat.vote"}, "31": {"-dm.guild.*"}},
+		},
+		PermissionGroups: map[string]perms.Array{
+			"mods": {"+dm.guild.*", "+dm.chat.*"},
+		},
+		PermissionGrants: []database.PermissionGrant{grant},
+		Votes:            []vote.Vote{imported},
+	}))
+
+	roles, err := db.GetAutoRoles(ctx, guildID)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"2", "3"}, roles)
+
+	channels, err := db.GetAutoVoice(ctx, guildID)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"4"}, channels)
+
+	p, err := db.GetPermissions(ctx, guildID)
+	require.Nil(t, err)
+	assert.Equal(t, map[string]perms.Array{
+		"11": {"+dm.guild.*"},
+	}, p)
+
+	p, err = db.GetPermissions(ctx, otherGuildID)
+	require.Nil(t, err)
+	assert.Len(t, p, 1)
+
+	p, err = db.GetUserPermissions(ctx, guildID)
+	require.Nil(t, err)
+	assert.Equal(t, map[string]perms.Array{
+		"31": {"-dm.chat.vote"},
+	}, p)
+
+	p, err = db.GetUserPermissions(ctx, otherGuildID)
+	require.Nil(t, err)
+	assert.Len(t, p, 1)
+
+	scoped, err := db.GetScopedPermissions(ctx, guildID)
+	require.Nil(t, err)
+	assert.Equal(t, database.ScopedPermissions{
+		"51": {"11": {"+dm.chat.vote"}, "31": {"-dm.guild.*"}},
+	}, scoped)
+
+	groups, err := db.GetPermissionGroups(ctx, guildID)
+	require.Nil(t, err)
+	assert.Equal(t, map[string]perms.Array{
+		"mods": {"+dm.guild.*", "+dm.chat.*"},
+	}, groups)
+
+	grants, err := db.GetPermissionGrants(ctx, guildID)
+	require.Nil(t, err)
+	require.Len(t, grants, 1)
+	assertGrantEqual(t, grant, grants[0])
+
+	votes, err := db.GetVotes(ctx, guildID)
+	require.Nil(t, err)
+	require.Len(t, votes, 2)
+	assertVoteEqual(t, existing, votes[existing.ID])
+	assertVoteEqual(t, imported, votes[imported.ID])
+}
+
+func testUserData(t *testing.T, db database.Database) {
+	ctx := context.Background()
+
+	const userID = "1100000000000000200"
+
+	data, err := db.GetUserData(ctx, userID)
+	require.Nil(t, err)
+	assert.Empty(t, data.Votes)
+	assert.Empty(t, data.Ticks)
+	assert.Empty(t, data.Permissions)
+	assert.Empty(t, data.ScopedPermissions)
+	assert.Empty(t, data.AVChannels)
+	assert.Empty(t, data.PermissionChanges)
+
+	require.Nil(t, db.SetUserPermissions(ctx, guildID, userID, perms.Array{"+dm.chat.*"}))
+	require.Nil(t, db.SetUserPermissions(ctx, guildID, "1100000000000000201", perms.Array{"+dm.guild.*"}))
+	require.Nil(t, db.SetScopedPermissions(ctx, guildID, "50", userID, perms.Array{"-dm.chat.vote"}))
+	require.Nil(t, db.SetScopedPermissions(ctx, guildID, "50", "role-a", perms.Array{"+dm.chat.vote"}))
+	require.Nil(t, db.AddPermissionGrant(ctx, database.PermissionGrant{
+		GuildID:    guildID,
+		TargetID:   userID,
+		TargetType: database.GrantTargetUser,
+		Rule:       "+dm.chat.*",
+		Expires:    time.Now().Add(time.Hour),
+	}))
+	_, err = db.GetUserPermissions(ctx, guildID)
+	require.Nil(t, err)
+	_, err = db.GetScopedPermissions(ctx, guildID)
+	require.Nil(t, err)
+
+	made := database.PermissionChange{
+		ID:         "1100000000000000301",
+		GuildID:    guildID,
+		TargetID:   "role-a",
+		TargetType: database.GrantTargetRole,
+		ActorID:    userID,
+		Old:        perms.Array{},
+		New:        perms.Array{"+dm.chat.vote"},
+		Created:    time.Now().Truncate(time.Second),
+	}
+	received := made
+	received.ID = "1100000000000000302"
+	received.TargetID = userID
+	received.TargetType = database.GrantTargetUser
+	received.ActorID = "1100000000000000201"
+	require.Nil(t, db.AddPermissionChange(ctx, made))
+	require.Nil(t, db.AddPermissionChange(ctx, received))
+
+	created := newVote("1100000000000000001", guildID)
+	created.Ticks["hash-a"] = &vote.Tick{UserID: "hash-a", Tick: 0}
+	require.Nil(t, db.AddUpdateVote(ctx, created))
+
+	ticked := newVote("1100000000000000002", otherGuildID)
+	ticked.CreatorID = "1100000000000000201"
+	hash, err := vote.HashUserID(ticked.ID, userID)
+	require.Nil(t, err)
+	ticked.Ticks[hash] = &vote.Tick{UserID: hash, Tick: 1}
+	ticked.Ticks["hash-b"] = &vote.Tick{UserID: "hash-b", Tick: 0}
+	require.Nil(t, db.AddUpdateVote(ctx, ticked))
+
+	owned := autovoice.AVChannel{
+		GuildID:          guildID,
+		OwnerID:          userID,
+		OriginChannelID:  "1100000000000000400",
+		CreatedChannelID: "1100000000000000401",
+	}
+	other := owned
+	other.OwnerID = "1100000000000000201"
+	other.CreatedChannelID = "1100000000000000402"
+	require.Nil(t, db.AddUpdateAVChannel(ctx, owned))
+	require.Nil(t, db.AddUpdateAVChannel(ctx, other))
+
+	data, err = db.GetUserData(ctx, userID)
+	require.Nil(t, err)
+	require.Len(t, data.Votes, 1)
+	assertVoteEqual(t, created, data.Votes[0])
+	assert.Equal(t, map[string][]int{ticked.ID: {1}}, data.Ticks)
+	assert.Equal(t, map[string]perms.Array{guildID: {"+dm.chat.*"}}, data.Permissions)
+	assert.Equal(t, map[string]database.ScopedPermissions{
+		guildID: {"50": {userID: {"-dm.chat.vote"}}},
+	}, data.ScopedPermissions)
+	assert.Equal(t, []autovoice.AVChannel{owned}, data.AVChannels)
+	require.Len(t, data.PermissionChanges, 1)
+	assertChangeEqual(t, made, data.PermissionChanges[0])
+
+	require.Nil(t, db.DeleteUserData(ctx, userID))
+
+	data, err = db.GetUserData(ctx, userID)
+	require.Nil(t, err)
+	assert.Empty(t, data.Votes)
+	assert.Empty(t, data.Ticks)
+	assert.Empty(t, data.Permissions)
+	assert.Empty(t, data.ScopedPermissions)
+	assert.Empty(t, data.AVChannels)
+	assert.Empty(t, data.PermissionChanges)
+
+	p, err := db.GetUserPermissions(ctx, guildID)
+	require.Nil(t, err)
+	assert.Equal(t, map[string]perms.Array{"1100000000000000201": {"+dm.guild.*"}}, p)
+
+	sp, err := db.GetScopedPermissions(ctx, guildID)
+	require.Nil(t, err)
+	assert.Equal(t, database.ScopedPermissions{"50": {"role-a": {"+dm.chat.vote"}}}, sp)
+
+	grants, err := db.GetPermissionGrants(ctx, guildID)
+	require.Nil(t, err)
+	assert.Empty(t, grants)
+
+	// Only the tick of the user is removed from the vote.
+	v, err := db.GetVote(ctx, ticked.ID)
+	require.Nil(t, err)
+	assert.Len(t, v.Ticks, 1)
+	assert.Contains(t, v.Ticks, "hash-b")
+
+	// Changes made by the user are kept, but anonymized.
+	// Changes of their own rules are removed.
+	changes, err := db.GetPermissionHistory(ctx, guildID, "", 0, 10)
+	require.Nil(t, err)
+	require.Len(t, changes, 1)
+	made.ActorID = ""
+	assertChangeEqual(t, made, changes[0])
+
+	// Votes created by the user are kept, but anonymized.
+	got, err := db.GetVote(ctx, created.ID)
+	require.Nil(t, err)
+	assert.Empty(t, got.CreatorID)
+	assert.Len(t, got.Ticks, 1)
+
+	got, err = db.GetVote(ctx, ticked.ID)
+	require.Nil(t, err)
+	assert.Equal(t, ticked.CreatorID, got.CreatorID)
+	assert.Len(t, got.Ticks, 1)
+	assert.Contains(t, got.Ticks, "hash-b")
+
+	avs, err := db.GetAVChannels(ctx, guildID)
+	require.Nil(t, err)
+	assert.Equal(t, map[string]autovoice.AVChannel{other.CreatedChannelID: other}, avs)
+}
+
+func testConcurrency(t *testing.T, db database.Database) {
+	ctx := context.Background()
+
+	const workers = 8
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*4)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			roleID := fmt.Sprintf("%d", 100+i)
+			errs <- db.SetPermissions(ctx, guildID, roleID, perms.Array{"+dm.chat.*"})
+			_, err := db.GetPermissions(ctx, guildID)
+			errs <- err
+			errs <- db.SetAutoRoles(ctx, guildID, []string{roleID})
+			_, err = db.GetAutoRoles(ctx, guildID)
+			errs <- err
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.Nil(t, err)
+	}
+
+	p, err := db.GetPermissions(ctx, guildID)
+	require.Nil(t, err)
+	assert.Len(t, p, workers)
+}
+
+func testCanceledContext(t *testing.T, db database.Database) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := db.GetPermissions(ctx, guildID)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	err = db.SetAutoRoles(ctx, guildID, []string{"1"})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	err = db.AddUpdateVote(ctx, newVote("1100000000000000001", guildID))
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// Nothing must have been written by the canceled calls.
+	roles, err := db.GetAutoRoles(context.Background(), guildID)
+	assert.ErrorIs(t, err, dberr.ErrNotFound)
+	assert.Empty(t, roles)
+
+	_, err = db.GetVote(context.Background(), "1100000000000000001")
+	assert.ErrorIs(t, err, dberr.ErrNotFound)
+}
+
+//
+// HELPERS
+//
+
+func newVote(id, guildID string) vote.Vote {
+	return vote.Vote{
+		ID:            id,
+		MsgID:         "1100000000000000100",
+		CreatorID:     "1100000000000000200",
+		GuildID:       guildID,
+		ChannelID:     "1100000000000000300",
+		Description:   "Pizza or pasta?",
+		ImageURL:      "https://example.com/image.png",
+		Expires:       time.Now().Add(time.Hour).Truncate(time.Second),
+		Possibilities: []string{"pizza", "pasta"},
+		Mode:          vote.ModeSingle,
+		Ticks:         map[string]*vote.Tick{},
+	}
+}
+
+func assertGrantEqual(t *testing.T, exp, act database.PermissionGrant) {
+	t.Helper()
+
+	assert.True(t, exp.Expires.Equal(act.Expires),
+		"expires differ: %s != %s", exp.Expires, act.Expires)
+	exp.Expires, act.Expires = time.Time{}, time.Time{}
+	assert.Equal(t, exp, act)
+}
+
+func assertChangeEqual(t *testing.T, exp, act database.PermissionChange) {
+	t.Helper()
+
+	assert.True(t, exp.Created.Equal(act.Created),
+		"created differ: %s != %s", exp.Created, act.Created)
+	exp.Created, act.Created = time.Time{}, time.Time{}
+	assert.Equal(t, exp, act)
+}
+
+func assertVoteEqual(t *testing.T, exp, act vote.Vote) {
+	t.Helper()
+
+	assert.Equal(t, exp.ID, act.ID)
+	assert.Equal(t, exp.MsgID, act.MsgID)
+	assert.Equal(t, exp.CreatorID, act.CreatorID)
+	assert.Equal(t, exp.GuildID, act.GuildID)
+	assert.Equal(t, exp.ChannelID, act.ChannelID)
+	assert.Equal(t, exp.Description, act.Description)
+	assert.Equal(t, exp.ImageURL, act.ImageURL)
+	assert.True(t, exp.Expires.Equal(act.Expires),
+		"expires differ: %s != %s", exp.Expires, act.Expires)
+	assert.True(t, exp.Closed.Equal(act.Closed),
+		"closed differ: %s != %s", exp.Closed, act.Closed)
+	assert.True(t, exp.Starts.Equal(act.Starts),
+		"starts differ: %s != %s", exp.Starts, act.Starts)
+	assert.Equal(t, exp.Possibilities, act.Possibilities)
+	assert.Equal(t, exp.Mode, act.Mode)
+	assert.Equal(t, exp.MaxChoices, act.MaxChoices)
+	assert.ElementsMatch(t, exp.Roles, act.Roles)
+	assert.Equal(t, exp.Weights, act.Weights)
+
+	require.Len(t, act.Ticks, len(exp.Ticks))
+	for k, et := range exp.Ticks {
+		at, ok := act.Ticks[k]
+		require.True(t, ok, "missing tick %s", k)
+		assert.True(t, et.Time.Equal(at.Time),
+			"time of tick %s differs: %s != %s", k, et.Time, at.Time)
+		etc, atc := *et, *at
+		etc.Time, atc.Time = time.Time{}, time.Time{}
+		assert.Equal(t, etc, atc)
+	}
+}
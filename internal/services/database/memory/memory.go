@@ -0,0 +1,925 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/zekurio/daemon/internal/services/database"
+	"github.com/zekurio/daemon/internal/services/database/dberr"
+	"github.com/zekurio/daemon/internal/util/autovoice"
+	"github.com/zekurio/daemon/internal/util/vote"
+	"github.com/zekurio/daemon/pkg/perms"
+)
+
+// Memory is a volatile, concurrency-safe implementation of
+// database.Database. It is mainly intended to be used in
+// unit tests where a real database is not available.
+//
+// Operations never block, so the passed contexts are only
+// checked for cancellation before any work is done.
+type Memory struct {
+	mtx sync.RWMutex
+
+	autoRoles   map[string][]string
+	autoVoice   map[string][]string
+	permissions map[string]map[string]perms.Array
+	userPerms   map[string]map[string]perms.Array
+	scopedPerms map[string]database.ScopedPermissions
+	groups      map[string]map[string]perms.Array
+	grants      map[grantKey]database.PermissionGrant
+	history     map[string]database.PermissionChange
+	votes       map[string]vote.Vote
+	avChannels  map[string]autovoice.AVChannel
+	deletions   map[string]time.Time
+}
+
+var _ database.Database = (*Memory)(nil)
+
+// grantKey identifies a permission grant.
+type grantKey struct {
+	guildID, scopeID, targetID, rule string
+}
+
+func keyOfGrant(g database.PermissionGrant) grantKey {
+	return grantKey{g.GuildID, g.ScopeID, g.TargetID, g.Rule}
+}
+
+func New() *Memory {
+	return &Memory{
+		autoRoles:   make(map[string][]string),
+		autoVoice:   make(map[string][]string),
+		permissions: make(map[string]map[string]perms.Array),
+		userPerms:   make(map[string]map[string]perms.Array),
+		scopedPerms: make(map[string]database.ScopedPermissions),
+		groups:      make(map[string]map[string]perms.Array),
+		grants:      make(map[grantKey]database.PermissionGrant),
+		history:     make(map[string]database.PermissionChange),
+		votes:       make(map[string]vote.Vote),
+		avChannels:  make(map[string]autovoice.AVChannel),
+		deletions:   make(map[string]time.Time),
+	}
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
+
+// GUILDS
+
+func (m *Memory) GetAutoRoles(ctx context.Context, guildID string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return []string{}, err
+	}
+
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	roleIDs, ok := m.autoRoles[guildID]
+	if !ok {
+		return []string{}, dberr.ErrNotFound
+	}
+
+	return copySlice(roleIDs), nil
+}
+
+func (m *Memory) SetAutoRoles(ctx context.Context, guildID string, roleIDs []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.autoRoles[guildID] = copySlice(roleIDs)
+	return nil
+}
+
+func (m *Memory) GetAutoVoice(ctx context.Context, guildID string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return []string{}, err
+	}
+
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	channelIDs, ok := m.autoVoice[guildID]
+	if !ok {
+		return []string{}, dberr.ErrNotFound
+	}
+
+	return copySlice(channelIDs), nil
+}
+
+func (m *Memory) SetAutoVoice(ctx context.Context, guildID string, channelIDs []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.autoVoice[guildID] = copySlice(channelIDs)
+	return nil
+}
+
+// PERMISSIONS
+
+func (m *Memory) GetPermissions(ctx context.Context, guildID string) (map[string]perms.Array, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	results := make(map[string]perms.Array)
+	for roleID, p := range m.permissions[guildID] {
+		results[roleID] = copySlice(p)
+	}
+
+	return results, nil
+}
+
+func (m *Memory) SetPermissions(ctx context.Context, guildID, roleID string, p perms.Array) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	guildPerms, ok := m.permissions[guildID]
+
+	if len(p) == 0 {
+		if ok {
+			delete(guildPerms, roleID)
+		}
+		return nil
+	}
+
+	if !ok {
+		guildPerms = make(map[string]perms.Array)
+		m.permissions[guildID] = guildPerms
+	}
+
+	guildPerms[roleID] = copySlice(p)
+	return nil
+}
+
+func (m *Memory) GetUserPermissions(ctx context.Context, guildID string) (map[string]perms.Array, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	results := make(map[string]perms.Array)
+	for userID, p := range m.userPerms[guildID] {
+		results[userID] = copySlice(p)
+	}
+
+	return results, nil
+}
+
+func (m *Memory) SetUserPermissions(ctx context.Context, guildID, userID string, p perms.Array) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	guildPerms, ok := m.userPerms[guildID]
+
+	if len(p) == 0 {
+		if ok {
+			delete(guildPerms, userID)
+		}
+		return nil
+	}
+
+	if !ok {
+		guildPerms = make(map[string]perms.Array)
+		m.userPerms[guildID] = guildPerms
+	}
+
+	guildPerms[userID] = copySlice(p)
+	return nil
+}
+
+func (m *Memory) GetPermissionGroups(ctx context.Context, guildID string) (map[string]perms.Array, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	results := make(map[string]perms.Array)
+	for name, p := range m.groups[guildID] {
+		results[name] = copySlice(p)
+	}
+
+	return results, nil
+}
+
+func (m *Memory) SetPermissionGroup(ctx context.Context, guildID, name string, p perms.Array) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	groups, ok := m.groups[guildID]
+
+	if len(p) == 0 {
+		if ok {
+			delete(groups, name)
+		}
+		return nil
+	}
+
+	if !ok {
+		groups = make(map[string]perms.Array)
+		m.groups[guildID] = groups
+	}
+
+	groups[name] = copySlice(p)
+	return nil
+}
+
+func (m *Memory) GetScopedPermissions(ctx context.Context, guildID string) (database.ScopedPermissions, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	results := make(database.ScopedPermissions)
+	for scopeID, scopePerms := range m.scopedPerms[guildID] {
+		results[scopeID] = make(map[string]perms.Array, len(scopePerms))
+		for targetID, p := range scopePerms {
+			results[scopeID][targetID] = copySlice(p)
+		}
+	}
+
+	return results, nil
+}
+
+func (m *Memory) SetScopedPermissions(ctx context.Context, guildID, scopeID, targetID string, p perms.Array) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	guildPerms, ok := m.scopedPerms[guildID]
+	if !ok {
+		guildPerms = make(database.ScopedPermissions)
+		m.scopedPerms[guildID] = guildPerms
+	}
+
+	if len(p) == 0 {
+		delete(guildPerms[scopeID], targetID)
+		if len(guildPerms[scopeID]) == 0 {
+			delete(guildPerms, scopeID)
+		}
+		return nil
+	}
+
+	if guildPerms[scopeID] == nil {
+		guildPerms[scopeID] = make(map[string]perms.Array)
+	}
+
+	guildPerms[scopeID][targetID] = copySlice(p)
+	return nil
+}
+
+func (m *Memory) GetPermissionGrants(ctx context.Context, guildID string) ([]database.PermissionGrant, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	return m.filterGrants(func(g database.PermissionGrant) bool {
+		return g.GuildID == guildID
+	}), nil
+}
+
+func (m *Memory) GetExpiredPermissionGrants(ctx context.Context, before time.Time) ([]database.PermissionGrant, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	return m.filterGrants(func(g database.PermissionGrant) bool {
+		return g.Expires.Before(before)
+	}), nil
+}
+
+func (m *Memory) AddPermissionGrant(ctx context.Context, g database.PermissionGrant) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.grants[keyOfGrant(g)] = g
+	return nil
+}
+
+func (m *Memory) DeletePermissionGrant(ctx context.Context, g database.PermissionGrant) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	delete(m.grants, keyOfGrant(g))
+	return nil
+}
+
+// PERMISSION HISTORY
+
+func (m *Memory) GetPermissionHistory(ctx context.Context, guildID, targetID string, offset, limit int) ([]database.PermissionChange, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	changes := m.filterChanges(func(c database.PermissionChange) bool {
+		return c.GuildID == guildID && (targetID == "" || c.TargetID == targetID)
+	})
+
+	// Newest first, like the SQL drivers.
+	for i, j := 0, len(changes)-1; i < j; i, j = i+1, j-1 {
+		changes[i], changes[j] = changes[j], changes[i]
+	}
+
+	if offset >= len(changes) {
+		return []database.PermissionChange{}, nil
+	}
+	changes = changes[offset:]
+	if limit < len(changes) {
+		changes = changes[:limit]
+	}
+
+	return changes, nil
+}
+
+func (m *Memory) GetPermissionChange(ctx context.Context, id string) (database.PermissionChange, error) {
+	if err := ctx.Err(); err != nil {
+		return database.PermissionChange{}, err
+	}
+
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	c, ok := m.history[id]
+	if !ok {
+		return database.PermissionChange{}, dberr.ErrNotFound
+	}
+
+	return copyChange(c), nil
+}
+
+func (m *Memory) AddPermissionChange(ctx context.Context, c database.PermissionChange) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.history[c.ID] = copyChange(c)
+	return nil
+}
+
+// VOTES
+
+func (m *Memory) GetVote(ctx context.Context, voteID string) (vote.Vote, error) {
+	if err := ctx.Err(); err != nil {
+		return vote.Vote{}, err
+	}
+
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	v, ok := m.votes[voteID]
+	if !ok {
+		return vote.Vote{}, dberr.ErrNotFound
+	}
+
+	return copyVote(v), nil
+}
+
+func (m *Memory) GetVotes(ctx context.Context, guildID string) (map[string]vote.Vote, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return m.filterVotes(func(v vote.Vote) bool {
+		return v.GuildID == guildID
+	}), nil
+}
+
+func (m *Memory) GetExpiredVotes(ctx context.Context, before time.Time) (map[string]vote.Vote, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return m.filterVotes(func(v vote.Vote) bool {
+		return v.Closed.IsZero() && v.MsgID != "" && !v.Posting() && !v.Expires.IsZero() && v.Expires.Before(before)
+	}), nil
+}
+
+func (m *Memory) GetDueVotes(ctx context.Context, before time.Time) (map[string]vote.Vote, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return m.filterVotes(func(v vote.Vote) bool {
+		return v.Pending() && v.Starts.Before(before)
+	}), nil
+}
+
+func (m *Memory) AddUpdateVote(ctx context.Context, v vote.Vote) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.votes[v.ID] = copyVote(v)
+	return nil
+}
+
+func (m *Memory) SwapVoteMsgID(ctx context.Context, voteID, oldID, newID string) (ok bool, err error) {
+	if err = ctx.Err(); err != nil {
+		return false, err
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	v, ok := m.votes[voteID]
+	if !ok || v.MsgID != oldID {
+		return false, nil
+	}
+
+	v.MsgID = newID
+	m.votes[voteID] = v
+	return true, nil
+}
+
+func (m *Memory) DeleteVote(ctx context.Context, voteID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	delete(m.votes, voteID)
+	return nil
+}
+
+// AUTOVOICE
+
+func (m *Memory) GetAVChannels(ctx context.Context, guildID string) (map[string]autovoice.AVChannel, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	results := make(map[string]autovoice.AVChannel)
+	for id, av := range m.avChannels {
+		if av.GuildID == guildID {
+			results[id] = av
+		}
+	}
+
+	return results, nil
+}
+
+func (m *Memory) AddUpdateAVChannel(ctx context.Context, av autovoice.AVChannel) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.avChannels[av.CreatedChannelID] = av
+	return nil
+}
+
+func (m *Memory) DeleteAVChannel(ctx context.Context, channelID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	delete(m.avChannels, channelID)
+	return nil
+}
+
+// DATA MANAGEMENT
+
+func (m *Memory) FlushGuildData(ctx context.Context, guildID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	delete(m.autoRoles, guildID)
+	delete(m.autoVoice, guildID)
+	delete(m.permissions, guildID)
+	delete(m.userPerms, guildID)
+	delete(m.scopedPerms, guildID)
+	delete(m.groups, guildID)
+
+	for k := range m.grants {
+		if k.guildID == guildID {
+			delete(m.grants, k)
+		}
+	}
+	delete(m.deletions, guildID)
+
+	for id, c := range m.history {
+		if c.GuildID == guildID {
+			delete(m.history, id)
+		}
+	}
+
+	for id, v := range m.votes {
+		if v.GuildID == guildID {
+			delete(m.votes, id)
+		}
+	}
+
+	for id, av := range m.avChannels {
+		if av.GuildID == guildID {
+			delete(m.avChannels, id)
+		}
+	}
+
+	return nil
+}
+
+func (m *Memory) MarkGuildDeletion(ctx context.Context, guildID string, deleteAfter time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.deletions[guildID] = deleteAfter
+	return nil
+}
+
+func (m *Memory) UnmarkGuildDeletion(ctx context.Context, guildID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	delete(m.deletions, guildID)
+	return nil
+}
+
+func (m *Memory) GetDueGuildDeletions(ctx context.Context, before time.Time) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	guildIDs := []string{}
+	for guildID, deleteAfter := range m.deletions {
+		if deleteAfter.Before(before) {
+			guildIDs = append(guildIDs, guildID)
+		}
+	}
+
+	return guildIDs, nil
+}
+
+func (m *Memory) ImportGuildData(ctx context.Context, guildID string, data database.GuildData) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.autoRoles[guildID] = copySlice(data.AutoRoles)
+	m.autoVoice[guildID] = copySlice(data.AutoVoice)
+
+	guildPerms := make(map[string]perms.Array)
+	for roleID, p := range data.Permissions {
+		if len(p) != 0 {
+			guildPerms[roleID] = copySlice(p)
+		}
+	}
+	m.permissions[guildID] = guildPerms
+
+	userPerms := make(map[string]perms.Array)
+	for userID, p := range data.UserPermissions {
+		if len(p) != 0 {
+			userPerms[userID] = copySlice(p)
+		}
+	}
+	m.userPerms[guildID] = userPerms
+
+	scopedPerms := make(database.ScopedPermissions)
+	for scopeID, targets := range data.ScopedPermissions {
+		for targetID, p := range targets {
+			if len(p) == 0 {
+				continue
+			}
+			if scopedPerms[scopeID] == nil {
+				scopedPerms[scopeID] = make(map[string]perms.Array)
+			}
+			scopedPerms[scopeID][targetID] = copySlice(p)
+		}
+	}
+	m.scopedPerms[guildID] = scopedPerms
+
+	groups := make(map[string]perms.Array)
+	for name, p := range data.PermissionGroups {
+		if len(p) != 0 {
+			groups[name] = copySlice(p)
+		}
+	}
+	m.groups[guildID] = groups
+
+	for k := range m.grants {
+		if k.guildID == guildID {
+			delete(m.grants, k)
+		}
+	}
+	for _, g := range data.PermissionGrants {
+		g.GuildID = guildID
+		m.grants[keyOfGrant(g)] = g
+	}
+
+	for _, v := range data.Votes {
+		m.votes[v.ID] = copyVote(v)
+	}
+
+	return nil
+}
+
+func (m *Memory) GetUserData(ctx context.Context, userID string) (database.UserData, error) {
+	if err := ctx.Err(); err != nil {
+		return database.UserData{}, err
+	}
+
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	data := database.UserData{
+		Votes:             []vote.Vote{},
+		Ticks:             make(map[string][]int),
+		Permissions:       make(map[string]perms.Array),
+		ScopedPermissions: make(map[string]database.ScopedPermissions),
+		AVChannels:        []autovoice.AVChannel{},
+	}
+
+	data.PermissionChanges = m.filterChanges(func(c database.PermissionChange) bool {
+		return c.ActorID == userID
+	})
+
+	for guildID, guildPerms := range m.userPerms {
+		if p, ok := guildPerms[userID]; ok {
+			data.Permissions[guildID] = copySlice(p)
+		}
+	}
+
+	for guildID, guildPerms := range m.scopedPerms {
+		for scopeID, scopePerms := range guildPerms {
+			p, ok := scopePerms[userID]
+			if !ok {
+				continue
+			}
+			if data.ScopedPermissions[guildID] == nil {
+				data.ScopedPermissions[guildID] = make(database.ScopedPermissions)
+			}
+			data.ScopedPermissions[guildID][scopeID] = map[string]perms.Array{userID: copySlice(p)}
+		}
+	}
+
+	for id, v := range m.votes {
+		if v.CreatorID == userID {
+			data.Votes = append(data.Votes, copyVote(v))
+		}
+
+		hash, err := vote.HashUserID(id, userID)
+		if err != nil {
+			return database.UserData{}, err
+		}
+		if t, ok := v.Ticks[hash]; ok && t != nil {
+			data.Ticks[id] = t.Picks()
+		}
+	}
+
+	for _, av := range m.avChannels {
+		if av.OwnerID == userID {
+			data.AVChannels = append(data.AVChannels, av)
+		}
+	}
+
+	sort.Slice(data.Votes, func(i, j int) bool {
+		return data.Votes[i].ID < data.Votes[j].ID
+	})
+	sort.Slice(data.AVChannels, func(i, j int) bool {
+		return data.AVChannels[i].CreatedChannelID < data.AVChannels[j].CreatedChannelID
+	})
+
+	return data, nil
+}
+
+func (m *Memory) DeleteUserData(ctx context.Context, userID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	for id, v := range m.votes {
+		hash, err := vote.HashUserID(id, userID)
+		if err != nil {
+			return err
+		}
+		delete(v.Ticks, hash)
+
+		if v.CreatorID == userID {
+			v.CreatorID = ""
+			m.votes[id] = v
+		}
+	}
+
+	for _, guildPerms := range m.userPerms {
+		delete(guildPerms, userID)
+	}
+
+	for _, guildPerms := range m.scopedPerms {
+		for scopeID, scopePerms := range guildPerms {
+			delete(scopePerms, userID)
+			if len(scopePerms) == 0 {
+				delete(guildPerms, scopeID)
+			}
+		}
+	}
+
+	for k, g := range m.grants {
+		if g.TargetID == userID && g.TargetType == database.GrantTargetUser {
+			delete(m.grants, k)
+		}
+	}
+
+	for id, c := range m.history {
+		switch {
+		case c.TargetID == userID && c.TargetType == database.GrantTargetUser:
+			delete(m.history, id)
+		case c.ActorID == userID:
+			c.ActorID = ""
+			m.history[id] = c
+		}
+	}
+
+	for id, av := range m.avChannels {
+		if av.OwnerID == userID {
+			delete(m.avChannels, id)
+		}
+	}
+
+	return nil
+}
+
+//
+// HELPERS
+//
+
+// filterGrants returns the grants matching pred sorted
+// by their expiry.
+func (m *Memory) filterGrants(pred func(g database.PermissionGrant) bool) []database.PermissionGrant {
+	grants := []database.PermissionGrant{}
+	for _, g := range m.grants {
+		if pred(g) {
+			grants = append(grants, g)
+		}
+	}
+
+	sort.Slice(grants, func(i, j int) bool {
+		return grants[i].Expires.Before(grants[j].Expires)
+	})
+
+	return grants
+}
+
+// filterChanges returns copies of the changes matching
+// pred ordered from oldest to newest.
+func (m *Memory) filterChanges(pred func(c database.PermissionChange) bool) []database.PermissionChange {
+	changes := []database.PermissionChange{}
+	for _, c := range m.history {
+		if pred(c) {
+			changes = append(changes, copyChange(c))
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if !changes[i].Created.Equal(changes[j].Created) {
+			return changes[i].Created.Before(changes[j].Created)
+		}
+		return changes[i].ID < changes[j].ID
+	})
+
+	return changes
+}
+
+func (m *Memory) filterVotes(pred func(v vote.Vote) bool) map[string]vote.Vote {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	results := make(map[string]vote.Vote)
+	for id, v := range m.votes {
+		if pred(v) {
+			results[id] = copyVote(v)
+		}
+	}
+
+	return results
+}
+
+func copySlice[T any](s []T) []T {
+	if s == nil {
+		return nil
+	}
+	c := make([]T, len(s))
+	copy(c, s)
+	return c
+}
+
+// copyChange returns a copy of the given change. Missing
+// rules are returned as empty arrays like the SQL drivers do.
+func copyChange(c database.PermissionChange) database.PermissionChange {
+	c.Old = append(perms.Array{}, c.Old...)
+	c.New = append(perms.Array{}, c.New...)
+	return c
+}
+
+// copyVote returns a deep copy of the given vote so that
+// callers can not mutate the stored state.
+func copyVote(v vote.Vote) vote.Vote {
+	v.Possibilities = copySlice(v.Possibilities)
+	v.Roles = copySlice(v.Roles)
+
+	if v.Weights != nil {
+		weights := make(map[string]int, len(v.Weights))
+		for k, w := range v.Weights {
+			weights[k] = w
+		}
+		v.Weights = weights
+	}
+
+	ticks := make(map[string]*vote.Tick, len(v.Ticks))
+	for k, t := range v.Ticks {
+		if t == nil {
+			continue
+		}
+		tc := *t
+		tc.Choices = copySlice(t.Choices)
+		ticks[k] = &tc
+	}
+	v.Ticks = ticks
+
+	return v
+}
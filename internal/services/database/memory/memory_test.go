@@ -0,0 +1,14 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/zekurio/daemon/internal/services/database"
+	"github.com/zekurio/daemon/internal/services/database/dbtest"
+)
+
+func TestConformance(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T) database.Database {
+		return New()
+	})
+}
@@ -0,0 +1,250 @@
+// Package migrations contains goose migrations which can not be
+// expressed in plain SQL. They are shared between all SQL drivers,
+// so the queries must stay portable between Postgres and SQLite.
+package migrations
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/pressly/goose/v3"
+
+	"github.com/zekurio/daemon/internal/util/autovoice"
+	"github.com/zekurio/daemon/internal/util/vote"
+)
+
+func init() {
+	goose.AddNamedMigration("00005_convert_legacy_blobs.go", upConvertLegacyBlobs, downConvertLegacyBlobs)
+}
+
+// upConvertLegacyBlobs moves the gob encoded votes and autovoice
+// channels from the legacy tables into the relational ones. Rows
+// which can not be decoded are kept in the legacy tables, which
+// are only dropped once they are empty.
+func upConvertLegacyBlobs(tx *sql.Tx) error {
+	votes, err := readLegacyBlobs(tx, "votes_legacy")
+	if err != nil {
+		return err
+	}
+
+	for id, rawData := range votes {
+		v, err := vote.Unmarshal(rawData)
+		if err != nil {
+			log.With(err).Warn("Failed decoding legacy vote, keeping it", "ID", id)
+			continue
+		}
+		if err = insertVote(tx, v); err != nil {
+			return err
+		}
+		if _, err = tx.Exec(`DELETE FROM votes_legacy WHERE id = $1`, id); err != nil {
+			return err
+		}
+	}
+
+	avs, err := readLegacyBlobs(tx, "autovoice_legacy")
+	if err != nil {
+		return err
+	}
+
+	for id, rawData := range avs {
+		av, err := autovoice.Unmarshal(rawData)
+		if err != nil {
+			log.With(err).Warn("Failed decoding legacy autovoice channel, keeping it", "ID", id)
+			continue
+		}
+		_, err = tx.Exec(`INSERT INTO autovoice (channel_id, guild_id, owner_id, origin_channel_id)
+			VALUES ($1, $2, $3, $4)`, av.CreatedChannelID, av.GuildID, av.OwnerID, av.OriginChannelID)
+		if err != nil {
+			return err
+		}
+		if _, err = tx.Exec(`DELETE FROM autovoice_legacy WHERE id = $1`, id); err != nil {
+			return err
+		}
+	}
+
+	for _, table := range []string{"votes_legacy", "autovoice_legacy"} {
+		if err = dropIfEmpty(tx, table); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// downConvertLegacyBlobs encodes the relational votes and
+// autovoice channels back into the legacy tables.
+func downConvertLegacyBlobs(tx *sql.Tx) error {
+	for _, table := range []string{"votes_legacy", "autovoice_legacy"} {
+		_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS ` + table + ` (
+			id VARCHAR(25) NOT NULL DEFAULT '',
+			json_data TEXT NOT NULL,
+			PRIMARY KEY (id)
+		)`)
+		if err != nil {
+			return err
+		}
+	}
+
+	votes, err := readVotes(tx)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range votes {
+		rawData, err := vote.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`INSERT INTO votes_legacy (id, json_data) VALUES ($1, $2)`, v.ID, rawData)
+		if err != nil {
+			return err
+		}
+	}
+
+	avs, err := readAVChannels(tx)
+	if err != nil {
+		return err
+	}
+
+	for _, av := range avs {
+		rawData, err := autovoice.Marshal(av)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`INSERT INTO autovoice_legacy (id, json_data) VALUES ($1, $2)`, av.CreatedChannelID, rawData)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//
+// HELPERS
+//
+
+func readLegacyBlobs(tx *sql.Tx, table string) (map[string]string, error) {
+	rows, err := tx.Query(`SELECT id, json_data FROM ` + table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make(map[string]string)
+	for rows.Next() {
+		var id, rawData string
+		if err = rows.Scan(&id, &rawData); err != nil {
+			return nil, err
+		}
+		results[id] = rawData
+	}
+
+	return results, rows.Err()
+}
+
+func dropIfEmpty(tx *sql.Tx, table string) error {
+	var count int
+	err := tx.QueryRow(`SELECT COUNT(*) FROM ` + table).Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		log.Warn("Some legacy entries could not be converted and were kept", "Table", table, "Count", count)
+		return nil
+	}
+
+	_, err = tx.Exec(`DROP TABLE ` + table)
+	return err
+}
+
+func insertVote(tx *sql.Tx, v vote.Vote) error {
+	_, err := tx.Exec(`INSERT INTO votes
+		(id, guild_id, channel_id, msg_id, creator_id, description, image_url, expires, choices)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		v.ID, v.GuildID, v.ChannelID, v.MsgID, v.CreatorID, v.Description, v.ImageURL,
+		sql.NullTime{Time: v.Expires, Valid: !v.Expires.IsZero()}, strings.Join(v.Possibilities, ","))
+	if err != nil {
+		return err
+	}
+
+	for _, t := range v.Ticks {
+		_, err = tx.Exec(`INSERT INTO vote_ticks (vote_id, user_hash, tick) VALUES ($1, $2, $3)`,
+			v.ID, t.UserID, t.Tick)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func readVotes(tx *sql.Tx) ([]vote.Vote, error) {
+	rows, err := tx.Query(`SELECT id, guild_id, channel_id, msg_id, creator_id,
+		description, image_url, expires, choices FROM votes`)
+	if err != nil {
+		return nil, err
+	}
+
+	var votes []vote.Vote
+	for rows.Next() {
+		var (
+			v       vote.Vote
+			expires sql.NullTime
+			choices string
+		)
+		err = rows.Scan(&v.ID, &v.GuildID, &v.ChannelID, &v.MsgID, &v.CreatorID,
+			&v.Description, &v.ImageURL, &expires, &choices)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if expires.Valid {
+			v.Expires = expires.Time
+		}
+		v.Possibilities = strings.Split(choices, ",")
+		v.Ticks = make(map[string]*vote.Tick)
+		votes = append(votes, v)
+	}
+	rows.Close()
+
+	for _, v := range votes {
+		rows, err := tx.Query(`SELECT user_hash, tick FROM vote_ticks WHERE vote_id = $1`, v.ID)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var t vote.Tick
+			if err = rows.Scan(&t.UserID, &t.Tick); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			v.Ticks[t.UserID] = &t
+		}
+		rows.Close()
+	}
+
+	return votes, nil
+}
+
+func readAVChannels(tx *sql.Tx) ([]autovoice.AVChannel, error) {
+	rows, err := tx.Query(`SELECT channel_id, guild_id, owner_id, origin_channel_id FROM autovoice`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var avs []autovoice.AVChannel
+	for rows.Next() {
+		var av autovoice.AVChannel
+		err = rows.Scan(&av.CreatedChannelID, &av.GuildID, &av.OwnerID, &av.OriginChannelID)
+		if err != nil {
+			return nil, err
+		}
+		avs = append(avs, av)
+	}
+
+	return avs, rows.Err()
+}
@@ -0,0 +1,92 @@
+package migrations
+
+import (
+	"database/sql"
+	"sync"
+
+	"github.com/charmbracelet/log"
+	"github.com/pressly/goose/v3"
+
+	"github.com/zekurio/daemon/internal/util/embedded"
+)
+
+// gooseMtx guards the global goose configuration,
+// which is set up again for every operation.
+var gooseMtx sync.Mutex
+
+// Migrator applies the embedded migrations of a
+// dialect to a database.
+type Migrator struct {
+	db      *sql.DB
+	dialect string
+	dir     string
+}
+
+// NewMigrator returns a Migrator for db. dialect is the goose
+// dialect and dir the name of the directory in the embedded
+// migrations containing the SQL migrations for it.
+func NewMigrator(db *sql.DB, dialect, dir string) *Migrator {
+	return &Migrator{
+		db:      db,
+		dialect: dialect,
+		dir:     "migrations/" + dir,
+	}
+}
+
+// Up applies all pending migrations.
+func (m *Migrator) Up() error {
+	return m.run(func() error {
+		return goose.Up(m.db, m.dir)
+	})
+}
+
+// Down rolls back the latest migration.
+func (m *Migrator) Down() error {
+	return m.run(func() error {
+		return goose.Down(m.db, m.dir)
+	})
+}
+
+// To migrates up or down to the given version.
+func (m *Migrator) To(version int64) error {
+	return m.run(func() error {
+		current, err := goose.GetDBVersion(m.db)
+		if err != nil {
+			return err
+		}
+
+		if version < current {
+			return goose.DownTo(m.db, m.dir, version)
+		}
+		return goose.UpTo(m.db, m.dir, version)
+	})
+}
+
+// Status logs the state of all migrations.
+func (m *Migrator) Status() error {
+	return m.run(func() error {
+		return goose.Status(m.db, m.dir)
+	})
+}
+
+// Version returns the current schema version.
+func (m *Migrator) Version() (version int64, err error) {
+	err = m.run(func() error {
+		version, err = goose.GetDBVersion(m.db)
+		return err
+	})
+	return
+}
+
+func (m *Migrator) run(f func() error) error {
+	gooseMtx.Lock()
+	defer gooseMtx.Unlock()
+
+	goose.SetBaseFS(embedded.Migrations)
+	goose.SetLogger(log.StandardLog())
+	if err := goose.SetDialect(m.dialect); err != nil {
+		return err
+	}
+
+	return f()
+}
@@ -0,0 +1,109 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/zekurio/daemon/internal/models"
+)
+
+// maxConnectBackoff caps the delay between two connection
+// attempts on startup.
+const maxConnectBackoff = 30 * time.Second
+
+// buildDSN returns the connection string for the given config.
+// When a DSN or URL is configured, it is used as is and all
+// other connection fields are ignored.
+func buildDSN(c models.PostgresConfig) string {
+	if c.DSN != "" {
+		return c.DSN
+	}
+
+	sslMode := c.SSLMode
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+
+	params := []struct{ key, value string }{
+		{"host", c.Host},
+		{"port", fmt.Sprint(c.Port)},
+		{"user", c.Username},
+		{"password", c.Password},
+		{"dbname", c.Database},
+		{"sslmode", sslMode},
+		{"sslrootcert", c.SSLRootCert},
+		{"sslcert", c.SSLCert},
+		{"sslkey", c.SSLKey},
+	}
+
+	var parts []string
+	for _, p := range params {
+		if p.value == "" || p.value == "0" {
+			continue
+		}
+		parts = append(parts, p.key+"="+quoteValue(p.value))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// quoteValue quotes a key/value connection string value if it
+// is empty or contains whitespace, quotes or backslashes.
+func quoteValue(v string) string {
+	if v != "" && !strings.ContainsAny(v, " \t\n\r'\\") {
+		return v
+	}
+
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+	return "'" + v + "'"
+}
+
+// pingWithRetry calls ping until it succeeds, retrying up to
+// retries times with an exponentially growing delay starting
+// at backoff. Each attempt is bounded by timeout, if set.
+func pingWithRetry(
+	ctx context.Context,
+	ping func(ctx context.Context) error,
+	retries int,
+	backoff time.Duration,
+	timeout time.Duration,
+) (err error) {
+	for attempt := 0; ; attempt++ {
+		if err = pingOnce(ctx, ping, timeout); err == nil {
+			return nil
+		}
+
+		if attempt >= retries {
+			return err
+		}
+
+		log.Warn("Failed connecting to database, retrying",
+			"err", err, "Attempt", attempt+1, "Retries", retries, "Delay", backoff)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > maxConnectBackoff {
+			backoff = maxConnectBackoff
+		}
+	}
+}
+
+func pingOnce(ctx context.Context, ping func(ctx context.Context) error, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	return ping(ctx)
+}
@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zekurio/daemon/internal/models"
+)
+
+func TestBuildDSN(t *testing.T) {
+	dsn := buildDSN(models.PostgresConfig{
+		DSN:  "postgres://daemon:secret@db:5432/daemon?sslmode=require",
+		Host: "ignored",
+	})
+	assert.Equal(t, "postgres://daemon:secret@db:5432/daemon?sslmode=require", dsn)
+
+	dsn = buildDSN(models.PostgresConfig{
+		Host:     "localhost",
+		Port:     5432,
+		Database: "daemon",
+		Username: "daemon",
+		Password: `it's a \secret`,
+	})
+	assert.Equal(t,
+		`host=localhost port=5432 user=daemon password='it\'s a \\secret' dbname=daemon sslmode=disable`, dsn)
+
+	dsn = buildDSN(models.PostgresConfig{
+		Host:        "db.example.com",
+		SSLMode:     "verify-full",
+		SSLRootCert: "/etc/ssl/ca.pem",
+	})
+	assert.Equal(t, "host=db.example.com sslmode=verify-full sslrootcert=/etc/ssl/ca.pem", dsn)
+}
+
+func TestPingWithRetry(t *testing.T) {
+	errUnavailable := errors.New("unavailable")
+
+	t.Run("Succeeds", func(t *testing.T) {
+		calls := 0
+		err := pingWithRetry(context.Background(), func(ctx context.Context) error {
+			calls++
+			if calls < 3 {
+				return errUnavailable
+			}
+			return nil
+		}, 5, time.Millisecond, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("ExhaustsRetries", func(t *testing.T) {
+		calls := 0
+		err := pingWithRetry(context.Background(), func(ctx context.Context) error {
+			calls++
+			return errUnavailable
+		}, 2, time.Millisecond, 0)
+		assert.ErrorIs(t, err, errUnavailable)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		calls := 0
+		err := pingWithRetry(ctx, func(ctx context.Context) error {
+			calls++
+			cancel()
+			return errUnavailable
+		}, 5, time.Hour, 0)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("AttemptTimeout", func(t *testing.T) {
+		err := pingWithRetry(context.Background(), func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}, 0, time.Millisecond, 10*time.Millisecond)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
@@ -1,70 +1,66 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
-	"github.com/charmbracelet/log"
-	_ "github.com/lib/pq"
-	"github.com/pressly/goose/v3"
+	"github.com/lib/pq"
 
 	"github.com/zekurio/daemon/internal/models"
 	"github.com/zekurio/daemon/internal/services/database"
 	"github.com/zekurio/daemon/internal/services/database/dberr"
+	"github.com/zekurio/daemon/internal/services/database/migrations"
 	"github.com/zekurio/daemon/internal/util/autovoice"
-	"github.com/zekurio/daemon/internal/util/embedded"
 	"github.com/zekurio/daemon/internal/util/vote"
 	"github.com/zekurio/daemon/pkg/perms"
 )
 
 type Postgres struct {
-	db *sql.DB
+	db           *sql.DB
+	queryTimeout time.Duration
 }
 
 var (
-	_           database.Database = (*Postgres)(nil)
-	guildTables                   = []string{"guilds", "permissions"}
+	_           database.Database   = (*Postgres)(nil)
+	_           database.Migratable = (*Postgres)(nil)
+	guildTables                     = []string{"guilds", "permissions", "votes", "autovoice", "guild_deletions", "user_permissions", "scoped_permissions", "permission_grants", "permission_groups", "permission_history"}
 )
 
-func InitPostgres(c models.PostgresConfig) (*Postgres, error) {
+// InitPostgres opens the connection pool described by c and
+// waits until the database is reachable, retrying with an
+// exponential backoff as configured.
+func InitPostgres(ctx context.Context, c models.PostgresConfig, queryTimeout time.Duration) (*Postgres, error) {
 	var (
-		p   Postgres
+		p   = Postgres{queryTimeout: queryTimeout}
 		err error
 	)
 
-	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable", c.Host, c.Port, c.Username, c.Password, c.Database)
-	p.db, err = sql.Open("postgres", dsn)
+	p.db, err = sql.Open("postgres", buildDSN(c))
 	if err != nil {
 		return nil, err
 	}
 
-	err = p.db.Ping()
-	if err != nil {
-		return nil, err
-	}
-
-	err = p.handleMigrations()
-	if err != nil {
-		return nil, err
-	}
+	p.db.SetMaxOpenConns(c.MaxOpenConns)
+	p.db.SetMaxIdleConns(c.MaxIdleConns)
+	p.db.SetConnMaxLifetime(c.ConnMaxLifetime)
+	p.db.SetConnMaxIdleTime(c.ConnMaxIdleTime)
 
-	goose.SetBaseFS(embedded.Migrations)
-	goose.SetDialect("postgres")
-	goose.SetLogger(log.StandardLog())
-	err = goose.Up(p.db, "migrations")
+	err = pingWithRetry(ctx, p.db.PingContext, c.ConnectRetries, c.ConnectBackoff, queryTimeout)
 	if err != nil {
+		p.db.Close()
 		return nil, err
 	}
 
 	return &p, nil
-
 }
 
-func (p *Postgres) handleMigrations() error {
-
-	return nil
-
+func (p *Postgres) Migrator() *migrations.Migrator {
+	return migrations.NewMigrator(p.db, "postgres", "postgres")
 }
 
 func (p *Postgres) Close() error {
@@ -74,8 +70,11 @@ func (p *Postgres) Close() error {
 
 // GUILDS
 
-func (p *Postgres) GetAutoRoles(guildID string) ([]string, error) {
-	roleStr, err := GetValue[string](p, "guilds", "autorole_ids", "guild_id", guildID)
+func (p *Postgres) GetAutoRoles(ctx context.Context, guildID string) ([]string, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	roleStr, err := GetValue[string](ctx, p, "guilds", "autorole_ids", "guild_id", guildID)
 	if roleStr == "" {
 		return []string{}, err
 	}
@@ -83,12 +82,18 @@ func (p *Postgres) GetAutoRoles(guildID string) ([]string, error) {
 	return strings.Split(roleStr, ","), nil
 }
 
-func (p *Postgres) SetAutoRoles(guildID string, roleIDs []string) error {
-	return SetValue(p, "guilds", "autorole_ids", strings.Join(roleIDs, ","), "guild_id", guildID)
+func (p *Postgres) SetAutoRoles(ctx context.Context, guildID string, roleIDs []string) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	return SetValue(ctx, p, "guilds", "autorole_ids", strings.Join(roleIDs, ","), "guild_id", guildID)
 }
 
-func (p *Postgres) GetAutoVoice(guildID string) ([]string, error) {
-	chStr, err := GetValue[string](p, "guilds", "autovoice_ids", "guild_id", guildID)
+func (p *Postgres) GetAutoVoice(ctx context.Context, guildID string) ([]string, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	chStr, err := GetValue[string](ctx, p, "guilds", "autovoice_ids", "guild_id", guildID)
 	if chStr == "" {
 		return []string{}, err
 	}
@@ -96,18 +101,25 @@ func (p *Postgres) GetAutoVoice(guildID string) ([]string, error) {
 	return strings.Split(chStr, ","), nil
 }
 
-func (p *Postgres) SetAutoVoice(guildID string, channelIDs []string) error {
-	return SetValue(p, "guilds", "autovoice_ids", strings.Join(channelIDs, ","), "guild_id", guildID)
+func (p *Postgres) SetAutoVoice(ctx context.Context, guildID string, channelIDs []string) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	return SetValue(ctx, p, "guilds", "autovoice_ids", strings.Join(channelIDs, ","), "guild_id", guildID)
 }
 
 // PERMISSIONS
 
-func (p *Postgres) GetPermissions(guildID string) (map[string]perms.Array, error) {
+func (p *Postgres) GetPermissions(ctx context.Context, guildID string) (map[string]perms.Array, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
 	results := make(map[string]perms.Array)
-	rows, err := p.db.Query(`SELECT role_id, perms FROM permissions WHERE guild_id = $1`, guildID)
+	rows, err := p.db.QueryContext(ctx, `SELECT role_id, perms FROM permissions WHERE guild_id = $1`, guildID)
 	if err != nil {
 		return nil, p.wrapErr(err)
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var roleID string
@@ -124,153 +136,697 @@ func (p *Postgres) GetPermissions(guildID string) (map[string]perms.Array, error
 	return results, nil
 }
 
-func (p *Postgres) SetPermissions(guildID, roleID string, perms perms.Array) error {
+func (p *Postgres) SetPermissions(ctx context.Context, guildID, roleID string, perms perms.Array) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
 	if len(perms) == 0 {
-		_, err := p.db.Exec(`DELETE FROM permissions WHERE guild_id = $1 AND role_id = $2`, guildID, roleID)
+		_, err := p.db.ExecContext(ctx, `DELETE FROM permissions WHERE guild_id = $1 AND role_id = $2`, guildID, roleID)
 		return err
 	}
 
 	pStr := strings.Join(perms, ",")
-	res, err := p.db.Exec(`UPDATE permissions SET perms = $1 WHERE guild_id = $2 AND role_id = $3`, pStr, guildID, roleID)
+	_, err := p.db.ExecContext(ctx, `INSERT INTO permissions (guild_id, role_id, perms) VALUES ($1, $2, $3)
+		ON CONFLICT (role_id) DO UPDATE SET perms = $3`, guildID, roleID, pStr)
+	return err
+
+}
+
+func (p *Postgres) GetUserPermissions(ctx context.Context, guildID string) (map[string]perms.Array, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	results := make(map[string]perms.Array)
+	rows, err := p.db.QueryContext(ctx, `SELECT user_id, perms FROM user_permissions WHERE guild_id = $1`, guildID)
 	if err != nil {
-		return err
+		return nil, p.wrapErr(err)
 	}
-	ar, err := res.RowsAffected()
-	if err != nil {
-		return err
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID, permStr string
+		if err = rows.Scan(&userID, &permStr); err != nil {
+			return nil, p.wrapErr(err)
+		}
+		results[userID] = strings.Split(permStr, ",")
 	}
-	if ar == 0 {
-		_, err := p.db.Exec(`INSERT INTO permissions (guild_id, role_id, perms) VALUES ($1, $2, $3)`, guildID, roleID, pStr)
+
+	return results, rows.Err()
+}
+
+func (p *Postgres) SetUserPermissions(ctx context.Context, guildID, userID string, perms perms.Array) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	if len(perms) == 0 {
+		_, err := p.db.ExecContext(ctx, `DELETE FROM user_permissions WHERE guild_id = $1 AND user_id = $2`,
+			guildID, userID)
 		return err
 	}
 
-	return nil
+	_, err := p.db.ExecContext(ctx, `INSERT INTO user_permissions (guild_id, user_id, perms) VALUES ($1, $2, $3)
+		ON CONFLICT (guild_id, user_id) DO UPDATE SET perms = excluded.perms`, guildID, userID, strings.Join(perms, ","))
+	return err
+}
+
+func (p *Postgres) GetPermissionGroups(ctx context.Context, guildID string) (map[string]perms.Array, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
+	results := make(map[string]perms.Array)
+	rows, err := p.db.QueryContext(ctx, `SELECT name, perms FROM permission_groups WHERE guild_id = $1`, guildID)
+	if err != nil {
+		return nil, p.wrapErr(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, permStr string
+		if err = rows.Scan(&name, &permStr); err != nil {
+			return nil, p.wrapErr(err)
+		}
+		results[name] = strings.Split(permStr, ",")
+	}
+
+	return results, rows.Err()
 }
 
-// VOTES
+func (p *Postgres) SetPermissionGroup(ctx context.Context, guildID, name string, perms perms.Array) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	if len(perms) == 0 {
+		_, err := p.db.ExecContext(ctx, `DELETE FROM permission_groups WHERE guild_id = $1 AND name = $2`,
+			guildID, name)
+		return err
+	}
 
-func (p *Postgres) GetVotes() (map[string]vote.Vote, error) {
+	_, err := p.db.ExecContext(ctx, `INSERT INTO permission_groups (guild_id, name, perms) VALUES ($1, $2, $3)
+		ON CONFLICT (guild_id, name) DO UPDATE SET perms = excluded.perms`, guildID, name, strings.Join(perms, ","))
+	return err
+}
 
-	rows, err := p.db.Query(`SELECT id, json_data FROM votes`)
+func (p *Postgres) GetScopedPermissions(ctx context.Context, guildID string) (database.ScopedPermissions, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	results := make(database.ScopedPermissions)
+	rows, err := p.db.QueryContext(ctx, `SELECT scope_id, target_id, perms FROM scoped_permissions WHERE guild_id = $1`,
+		guildID)
 	if err != nil {
 		return nil, p.wrapErr(err)
 	}
+	defer rows.Close()
 
-	var results = make(map[string]vote.Vote)
 	for rows.Next() {
-		var voteID, rawData string
-		err := rows.Scan(&voteID, &rawData)
-		if err != nil {
-			continue
+		var scopeID, targetID, permStr string
+		if err = rows.Scan(&scopeID, &targetID, &permStr); err != nil {
+			return nil, p.wrapErr(err)
 		}
-		vote, err := vote.Unmarshal(rawData)
-		if err != nil {
-			p.DeleteVote(rawData)
-		} else {
-			results[vote.ID] = vote
+		if results[scopeID] == nil {
+			results[scopeID] = make(map[string]perms.Array)
 		}
+		results[scopeID][targetID] = strings.Split(permStr, ",")
+	}
+
+	return results, rows.Err()
+}
+
+func (p *Postgres) SetScopedPermissions(ctx context.Context, guildID, scopeID, targetID string, perms perms.Array) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
+	if len(perms) == 0 {
+		_, err := p.db.ExecContext(ctx, `DELETE FROM scoped_permissions
+			WHERE guild_id = $1 AND scope_id = $2 AND target_id = $3`, guildID, scopeID, targetID)
+		return err
 	}
 
-	return results, nil
+	_, err := p.db.ExecContext(ctx, `INSERT INTO scoped_permissions (guild_id, scope_id, target_id, perms)
+		VALUES ($1, $2, $3, $4) ON CONFLICT (guild_id, scope_id, target_id) DO UPDATE SET perms = excluded.perms`,
+		guildID, scopeID, targetID, strings.Join(perms, ","))
+	return err
+}
+
+const grantColumns = `guild_id, scope_id, target_id, target_type, rule, channel_id, expires`
+
+func (p *Postgres) GetPermissionGrants(ctx context.Context, guildID string) ([]database.PermissionGrant, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	return p.queryGrants(ctx, `SELECT `+grantColumns+` FROM permission_grants WHERE guild_id = $1
+		ORDER BY expires`, guildID)
+}
+
+func (p *Postgres) GetExpiredPermissionGrants(ctx context.Context, before time.Time) ([]database.PermissionGrant, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	return p.queryGrants(ctx, `SELECT `+grantColumns+` FROM permission_grants WHERE expires < $1
+		ORDER BY expires`, before.UTC())
+}
+
+func (p *Postgres) AddPermissionGrant(ctx context.Context, g database.PermissionGrant) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	_, err := p.db.ExecContext(ctx, `INSERT INTO permission_grants (`+grantColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (guild_id, scope_id, target_id, rule) DO UPDATE SET
+			target_type = excluded.target_type, channel_id = excluded.channel_id, expires = excluded.expires`,
+		g.GuildID, g.ScopeID, g.TargetID, g.TargetType, g.Rule, g.ChannelID, g.Expires.UTC())
+	return err
+}
 
+func (p *Postgres) DeletePermissionGrant(ctx context.Context, g database.PermissionGrant) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	_, err := p.db.ExecContext(ctx, `DELETE FROM permission_grants
+		WHERE guild_id = $1 AND scope_id = $2 AND target_id = $3 AND rule = $4`,
+		g.GuildID, g.ScopeID, g.TargetID, g.Rule)
+	return err
 }
 
-func (p *Postgres) AddUpdateVote(v vote.Vote) error {
-	rawData, err := vote.Marshal(v)
+// PERMISSION HISTORY
+
+const changeColumns = `id, guild_id, scope_id, target_id, target_type, actor_id, old_perms, new_perms, created`
+
+func (p *Postgres) GetPermissionHistory(ctx context.Context, guildID, targetID string, offset, limit int) ([]database.PermissionChange, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	if targetID == "" {
+		return p.queryChanges(ctx, `SELECT `+changeColumns+` FROM permission_history WHERE guild_id = $1
+			ORDER BY created DESC, id DESC LIMIT $2 OFFSET $3`, guildID, limit, offset)
+	}
+
+	return p.queryChanges(ctx, `SELECT `+changeColumns+` FROM permission_history
+		WHERE guild_id = $1 AND target_id = $2 ORDER BY created DESC, id DESC LIMIT $3 OFFSET $4`,
+		guildID, targetID, limit, offset)
+}
+
+func (p *Postgres) GetPermissionChange(ctx context.Context, id string) (database.PermissionChange, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	changes, err := p.queryChanges(ctx, `SELECT `+changeColumns+` FROM permission_history WHERE id = $1`, id)
 	if err != nil {
-		return err
+		return database.PermissionChange{}, err
 	}
-	_, err = p.db.Exec(`INSERT INTO votes (id, json_data) VALUES ($1, $2) ON CONFLICT (id) DO UPDATE SET json_data = $2`, v.ID, rawData)
+
+	if len(changes) == 0 {
+		return database.PermissionChange{}, dberr.ErrNotFound
+	}
+
+	return changes[0], nil
+}
+
+func (p *Postgres) AddPermissionChange(ctx context.Context, c database.PermissionChange) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	_, err := p.db.ExecContext(ctx, `INSERT INTO permission_history (`+changeColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		c.ID, c.GuildID, c.ScopeID, c.TargetID, c.TargetType, c.ActorID,
+		strings.Join(c.Old, ","), strings.Join(c.New, ","), c.Created.UTC())
 	return err
 }
 
-func (p *Postgres) DeleteVote(voteID string) error {
-	_, err := p.db.Exec(`DELETE FROM votes WHERE id = $1`, voteID)
+// VOTES
+
+const voteColumns = `id, guild_id, channel_id, msg_id, creator_id, description, image_url, expires, choices,
+	mode, max_choices, roles, weights, closed, starts`
+
+func (p *Postgres) GetVote(ctx context.Context, voteID string) (vote.Vote, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	votes, err := p.queryVotes(ctx, `SELECT `+voteColumns+` FROM votes WHERE id = $1`, voteID)
+	if err != nil {
+		return vote.Vote{}, err
+	}
+
+	v, ok := votes[voteID]
+	if !ok {
+		return vote.Vote{}, dberr.ErrNotFound
+	}
+
+	return v, nil
+}
+
+func (p *Postgres) GetVotes(ctx context.Context, guildID string) (map[string]vote.Vote, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	return p.queryVotes(ctx, `SELECT `+voteColumns+` FROM votes WHERE guild_id = $1`, guildID)
+}
+
+func (p *Postgres) GetExpiredVotes(ctx context.Context, before time.Time) (map[string]vote.Vote, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	return p.queryVotes(ctx, `SELECT `+voteColumns+` FROM votes
+		WHERE closed IS NULL AND msg_id NOT IN ('', $2) AND expires IS NOT NULL AND expires < $1`,
+		before, vote.MsgIDPosting)
+}
+
+func (p *Postgres) GetDueVotes(ctx context.Context, before time.Time) (map[string]vote.Vote, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	return p.queryVotes(ctx, `SELECT `+voteColumns+` FROM votes
+		WHERE msg_id = '' AND starts IS NOT NULL AND starts < $1`, before)
+}
+
+func (p *Postgres) AddUpdateVote(ctx context.Context, v vote.Vote) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	return p.tx(ctx, func(tx *sql.Tx) error {
+		return upsertVote(ctx, tx, v)
+	})
+}
+
+func (p *Postgres) SwapVoteMsgID(ctx context.Context, voteID, oldID, newID string) (ok bool, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.db.ExecContext(ctx, `UPDATE votes SET msg_id = $3 WHERE id = $1 AND msg_id = $2`,
+		voteID, oldID, newID)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n != 0, err
+}
+
+func (p *Postgres) DeleteVote(ctx context.Context, voteID string) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	_, err := p.db.ExecContext(ctx, `DELETE FROM votes WHERE id = $1`, voteID)
 	return err
 }
 
 // AUTOVOICE
 
-func (p *Postgres) GetAVChannels() (map[string]autovoice.AVChannel, error) {
+func (p *Postgres) GetAVChannels(ctx context.Context, guildID string) (map[string]autovoice.AVChannel, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
-	rows, err := p.db.Query(`SELECT id, json_data FROM autovoice`)
+	rows, err := p.db.QueryContext(ctx, `SELECT channel_id, guild_id, owner_id, origin_channel_id
+		FROM autovoice WHERE guild_id = $1`, guildID)
 	if err != nil {
 		return nil, p.wrapErr(err)
 	}
+	defer rows.Close()
 
 	var results = make(map[string]autovoice.AVChannel)
 	for rows.Next() {
-		var avID, rawData string
-		err := rows.Scan(&avID, &rawData)
-		if err != nil {
-			continue
-		}
-		av, err := autovoice.Unmarshal(rawData)
+		var av autovoice.AVChannel
+		err := rows.Scan(&av.CreatedChannelID, &av.GuildID, &av.OwnerID, &av.OriginChannelID)
 		if err != nil {
-			p.DeleteAVChannel(rawData)
-		} else {
-			results[av.CreatedChannelID] = av
+			return nil, p.wrapErr(err)
 		}
-
+		results[av.CreatedChannelID] = av
 	}
 
 	return results, nil
 
 }
 
-func (p *Postgres) AddUpdateAVChannel(av autovoice.AVChannel) error {
-	rawData, err := autovoice.Marshal(av)
-	if err != nil {
-		return err
-	}
-	_, err = p.db.Exec(`INSERT INTO autovoice (id, json_data) VALUES ($1, $2) ON CONFLICT (id) DO UPDATE SET json_data = $2`, av.CreatedChannelID, rawData)
+func (p *Postgres) AddUpdateAVChannel(ctx context.Context, av autovoice.AVChannel) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	_, err := p.db.ExecContext(ctx, `INSERT INTO autovoice (channel_id, guild_id, owner_id, origin_channel_id)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (channel_id) DO UPDATE SET
+			guild_id = EXCLUDED.guild_id, owner_id = EXCLUDED.owner_id,
+			origin_channel_id = EXCLUDED.origin_channel_id`,
+		av.CreatedChannelID, av.GuildID, av.OwnerID, av.OriginChannelID)
 	return err
 }
 
-func (p *Postgres) DeleteAVChannel(channelID string) error {
-	_, err := p.db.Exec(`DELETE FROM autovoice WHERE id = $1`, channelID)
+func (p *Postgres) DeleteAVChannel(ctx context.Context, channelID string) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	_, err := p.db.ExecContext(ctx, `DELETE FROM autovoice WHERE channel_id = $1`, channelID)
 	return err
 }
 
 // DATA MANAGEMENT
 
-func (p *Postgres) FlushGuildData(guildID string) error {
+func (p *Postgres) FlushGuildData(ctx context.Context, guildID string) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
-	return p.tx(func(tx *sql.Tx) error {
-
-		var (
-			err          error
-			failedGuilds = []string{}
-		)
+	// vote_ticks are removed by the foreign key cascade
+	return p.tx(ctx, func(tx *sql.Tx) error {
 
 		for _, table := range guildTables {
+			_, err := tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE guild_id = $1`, table), guildID)
+			if err != nil {
+				return fmt.Errorf("failed to flush table %s for guild %s: %v", table, guildID, err)
+			}
+		}
 
-			_, err := tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE guild_id = $1`, table), guildID)
+		return nil
+
+	})
+
+}
+
+func (p *Postgres) MarkGuildDeletion(ctx context.Context, guildID string, deleteAfter time.Time) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	_, err := p.db.ExecContext(ctx, `INSERT INTO guild_deletions (guild_id, delete_after) VALUES ($1, $2)
+		ON CONFLICT (guild_id) DO UPDATE SET delete_after = EXCLUDED.delete_after`, guildID, deleteAfter)
+	return err
+}
+
+func (p *Postgres) UnmarkGuildDeletion(ctx context.Context, guildID string) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	_, err := p.db.ExecContext(ctx, `DELETE FROM guild_deletions WHERE guild_id = $1`, guildID)
+	return err
+}
+
+func (p *Postgres) GetDueGuildDeletions(ctx context.Context, before time.Time) ([]string, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, `SELECT guild_id FROM guild_deletions WHERE delete_after < $1`, before)
+	if err != nil {
+		return nil, p.wrapErr(err)
+	}
+	defer rows.Close()
+
+	guildIDs := []string{}
+	for rows.Next() {
+		var guildID string
+		if err = rows.Scan(&guildID); err != nil {
+			return nil, p.wrapErr(err)
+		}
+		guildIDs = append(guildIDs, guildID)
+	}
+
+	return guildIDs, rows.Err()
+}
+
+func (p *Postgres) ImportGuildData(ctx context.Context, guildID string, data database.GuildData) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	return p.tx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `INSERT INTO guilds (guild_id, autorole_ids, autovoice_ids) VALUES ($1, $2, $3)
+			ON CONFLICT (guild_id) DO UPDATE SET
+				autorole_ids = EXCLUDED.autorole_ids, autovoice_ids = EXCLUDED.autovoice_ids`,
+			guildID, strings.Join(data.AutoRoles, ","), strings.Join(data.AutoVoice, ","))
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM permissions WHERE guild_id = $1`, guildID)
+		if err != nil {
+			return err
+		}
+
+		for roleID, p := range data.Permissions {
+			if len(p) == 0 {
+				continue
+			}
+			_, err = tx.ExecContext(ctx, `INSERT INTO permissions (guild_id, role_id, perms) VALUES ($1, $2, $3)
+				ON CONFLICT (role_id) DO UPDATE SET guild_id = EXCLUDED.guild_id, perms = EXCLUDED.perms`,
+				guildID, roleID, strings.Join(p, ","))
 			if err != nil {
-				failedGuilds = append(failedGuilds, guildID)
+				return err
 			}
+		}
 
+		for _, table := range []string{"user_permissions", "scoped_permissions", "permission_groups", "permission_grants"} {
+			if _, err = tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE guild_id = $1`, guildID); err != nil {
+				return err
+			}
 		}
 
-		if len(failedGuilds) > 0 || err != nil {
-			return fmt.Errorf("failed to flush guild data for guilds: %v", failedGuilds)
+		for userID, p := range data.UserPermissions {
+			if len(p) == 0 {
+				continue
+			}
+			_, err = tx.ExecContext(ctx, `INSERT INTO user_permissions (guild_id, user_id, perms) VALUES ($1, $2, $3)`,
+				guildID, userID, strings.Join(p, ","))
+			if err != nil {
+				return err
+			}
+		}
+
+		for scopeID, targets := range data.ScopedPermissions {
+			for targetID, p := range targets {
+				if len(p) == 0 {
+					continue
+				}
+				_, err = tx.ExecContext(ctx, `INSERT INTO scoped_permissions (guild_id, scope_id, target_id, perms)
+					VALUES ($1, $2, $3, $4)`, guildID, scopeID, targetID, strings.Join(p, ","))
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		for name, p := range data.PermissionGroups {
+			if len(p) == 0 {
+				continue
+			}
+			_, err = tx.ExecContext(ctx, `INSERT INTO permission_groups (guild_id, name, perms) VALUES ($1, $2, $3)`,
+				guildID, name, strings.Join(p, ","))
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, g := range data.PermissionGrants {
+			_, err = tx.ExecContext(ctx, `INSERT INTO permission_grants (`+grantColumns+`)
+				VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (guild_id, scope_id, target_id, rule) DO UPDATE SET
+					target_type = EXCLUDED.target_type, channel_id = EXCLUDED.channel_id, expires = EXCLUDED.expires`,
+				guildID, g.ScopeID, g.TargetID, g.TargetType, g.Rule, g.ChannelID, g.Expires.UTC())
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, v := range data.Votes {
+			if err = upsertVote(ctx, tx, v); err != nil {
+				return err
+			}
 		}
 
 		return nil
+	})
+}
 
+func (p *Postgres) GetUserData(ctx context.Context, userID string) (database.UserData, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	data := database.UserData{
+		Votes:             []vote.Vote{},
+		Ticks:             make(map[string][]int),
+		Permissions:       make(map[string]perms.Array),
+		ScopedPermissions: make(map[string]database.ScopedPermissions),
+		AVChannels:        []autovoice.AVChannel{},
+	}
+
+	votes, err := p.queryVotes(ctx, `SELECT `+voteColumns+` FROM votes WHERE creator_id = $1`, userID)
+	if err != nil {
+		return database.UserData{}, err
+	}
+	for _, v := range votes {
+		data.Votes = append(data.Votes, v)
+	}
+	sort.Slice(data.Votes, func(i, j int) bool {
+		return data.Votes[i].ID < data.Votes[j].ID
 	})
 
+	data.PermissionChanges, err = p.queryChanges(ctx, `SELECT `+changeColumns+` FROM permission_history
+		WHERE actor_id = $1 ORDER BY created, id`, userID)
+	if err != nil {
+		return database.UserData{}, err
+	}
+
+	err = p.tx(ctx, func(tx *sql.Tx) error {
+		voteIDs, hashes, err := tickHashes(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+
+		tickRows, err := tx.QueryContext(ctx, `SELECT t.vote_id, t.tick, t.choices FROM vote_ticks t
+			JOIN unnest($1::text[], $2::text[]) AS h (vote_id, user_hash)
+			ON t.vote_id = h.vote_id AND t.user_hash = h.user_hash`,
+			pq.Array(voteIDs), pq.Array(hashes))
+		if err != nil {
+			return err
+		}
+		defer tickRows.Close()
+
+		for tickRows.Next() {
+			var (
+				voteID  string
+				t       vote.Tick
+				choices string
+			)
+			if err = tickRows.Scan(&voteID, &t.Tick, &choices); err != nil {
+				return err
+			}
+			if t.Choices, err = splitInts(choices); err != nil {
+				return err
+			}
+			data.Ticks[voteID] = t.Picks()
+		}
+		if err = tickRows.Err(); err != nil {
+			return err
+		}
+
+		rows, err := tx.QueryContext(ctx, `SELECT guild_id, perms FROM user_permissions WHERE user_id = $1`, userID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var guildID, permStr string
+			if err = rows.Scan(&guildID, &permStr); err != nil {
+				return err
+			}
+			data.Permissions[guildID] = strings.Split(permStr, ",")
+		}
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		rows.Close()
+
+		rows, err = tx.QueryContext(ctx, `SELECT guild_id, scope_id, perms FROM scoped_permissions
+			WHERE target_id = $1`, userID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var guildID, scopeID, permStr string
+			if err = rows.Scan(&guildID, &scopeID, &permStr); err != nil {
+				return err
+			}
+			if data.ScopedPermissions[guildID] == nil {
+				data.ScopedPermissions[guildID] = make(database.ScopedPermissions)
+			}
+			data.ScopedPermissions[guildID][scopeID] = map[string]perms.Array{
+				userID: strings.Split(permStr, ","),
+			}
+		}
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		rows.Close()
+
+		rows, err = tx.QueryContext(ctx, `SELECT channel_id, guild_id, owner_id, origin_channel_id
+			FROM autovoice WHERE owner_id = $1 ORDER BY channel_id`, userID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var av autovoice.AVChannel
+			if err = rows.Scan(&av.CreatedChannelID, &av.GuildID, &av.OwnerID, &av.OriginChannelID); err != nil {
+				return err
+			}
+			data.AVChannels = append(data.AVChannels, av)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return database.UserData{}, err
+	}
+
+	return data, nil
+}
+
+func (p *Postgres) DeleteUserData(ctx context.Context, userID string) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	return p.tx(ctx, func(tx *sql.Tx) error {
+		voteIDs, hashes, err := tickHashes(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM vote_ticks t
+			USING unnest($1::text[], $2::text[]) AS h (vote_id, user_hash)
+			WHERE t.vote_id = h.vote_id AND t.user_hash = h.user_hash`,
+			pq.Array(voteIDs), pq.Array(hashes))
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE votes SET creator_id = '' WHERE creator_id = $1`, userID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM user_permissions WHERE user_id = $1`, userID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM scoped_permissions WHERE target_id = $1`, userID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM permission_grants WHERE target_id = $1 AND target_type = $2`,
+			userID, database.GrantTargetUser)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM permission_history WHERE target_id = $1 AND target_type = $2`,
+			userID, database.GrantTargetUser)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE permission_history SET actor_id = '' WHERE actor_id = $1`, userID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM autovoice WHERE owner_id = $1`, userID)
+		return err
+	})
 }
 
 //
 // HELPERS
 //
 
-func (p *Postgres) tx(f func(*sql.Tx) error) error {
-	tx, err := p.db.Begin()
+// withTimeout derives a context from ctx which is canceled after
+// the configured query timeout. A timeout of 0 disables it.
+func (p *Postgres) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, p.queryTimeout)
+}
+
+func (p *Postgres) tx(ctx context.Context, f func(*sql.Tx) error) error {
+	tx, err := p.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -283,6 +839,196 @@ func (p *Postgres) tx(f func(*sql.Tx) error) error {
 	return tx.Commit()
 }
 
+// upsertVote inserts or updates the vote and replaces
+// all of its ticks.
+func upsertVote(ctx context.Context, tx *sql.Tx, v vote.Vote) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO votes (`+voteColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		ON CONFLICT (id) DO UPDATE SET
+			guild_id = EXCLUDED.guild_id, channel_id = EXCLUDED.channel_id, msg_id = EXCLUDED.msg_id,
+			creator_id = EXCLUDED.creator_id, description = EXCLUDED.description,
+			image_url = EXCLUDED.image_url, expires = EXCLUDED.expires, choices = EXCLUDED.choices,
+			mode = EXCLUDED.mode, max_choices = EXCLUDED.max_choices, roles = EXCLUDED.roles, weights = EXCLUDED.weights,
+			closed = EXCLUDED.closed, starts = EXCLUDED.starts`,
+		v.ID, v.GuildID, v.ChannelID, v.MsgID, v.CreatorID, v.Description, v.ImageURL,
+		nullTime(v.Expires), strings.Join(v.Possibilities, ","), voteMode(v.Mode), v.MaxChoices,
+		strings.Join(v.Roles, ","), joinWeights(v.Weights), nullTime(v.Closed), nullTime(v.Starts))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM vote_ticks WHERE vote_id = $1`, v.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range v.Ticks {
+		_, err = tx.ExecContext(ctx, `INSERT INTO vote_ticks (vote_id, user_hash, tick, choices, weight, created)
+			VALUES ($1, $2, $3, $4, $5, $6)`,
+			v.ID, t.UserID, t.Tick, joinInts(t.Choices), t.Weight, nullTime(t.Time))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// tickHashes returns the IDs of all votes and the hashes
+// under which the ticks of the user would be stored in them,
+// both in the same order.
+func tickHashes(ctx context.Context, tx *sql.Tx, userID string) (voteIDs, hashes []string, err error) {
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM votes`)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var voteID, hash string
+		if err = rows.Scan(&voteID); err != nil {
+			return nil, nil, err
+		}
+		if hash, err = vote.HashUserID(voteID, userID); err != nil {
+			return nil, nil, err
+		}
+		voteIDs = append(voteIDs, voteID)
+		hashes = append(hashes, hash)
+	}
+
+	return voteIDs, hashes, rows.Err()
+}
+
+// queryVotes runs the given query, which must select the
+// voteColumns, and collects the matching votes including
+// their ticks, which are loaded with a single query.
+func (p *Postgres) queryVotes(ctx context.Context, query string, args ...any) (map[string]vote.Vote, error) {
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, p.wrapErr(err)
+	}
+	defer rows.Close()
+
+	results := make(map[string]vote.Vote)
+	for rows.Next() {
+		var (
+			v       vote.Vote
+			expires sql.NullTime
+			choices string
+			mode    string
+			roles   string
+			weights string
+			closed  sql.NullTime
+			starts  sql.NullTime
+		)
+		err = rows.Scan(&v.ID, &v.GuildID, &v.ChannelID, &v.MsgID, &v.CreatorID,
+			&v.Description, &v.ImageURL, &expires, &choices, &mode, &v.MaxChoices, &roles, &weights, &closed, &starts)
+		if err != nil {
+			return nil, p.wrapErr(err)
+		}
+		v.Expires = expires.Time
+		v.Closed = closed.Time
+		v.Starts = starts.Time
+		v.Possibilities = splitList(choices)
+		v.Mode = vote.Mode(mode)
+		v.Roles = splitList(roles)
+		if v.Weights, err = splitWeights(weights); err != nil {
+			return nil, err
+		}
+		v.Ticks = make(map[string]*vote.Tick)
+		results[v.ID] = v
+	}
+	if err = rows.Err(); err != nil {
+		return nil, p.wrapErr(err)
+	}
+	rows.Close()
+
+	if len(results) == 0 {
+		return results, nil
+	}
+
+	ids := make([]string, 0, len(results))
+	for id := range results {
+		ids = append(ids, id)
+	}
+
+	tickRows, err := p.db.QueryContext(ctx, `SELECT vote_id, user_hash, tick, choices, weight, created
+		FROM vote_ticks WHERE vote_id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return nil, p.wrapErr(err)
+	}
+	defer tickRows.Close()
+
+	for tickRows.Next() {
+		var (
+			voteID  string
+			t       vote.Tick
+			choices string
+			created sql.NullTime
+		)
+		if err = tickRows.Scan(&voteID, &t.UserID, &t.Tick, &choices, &t.Weight, &created); err != nil {
+			return nil, p.wrapErr(err)
+		}
+		if t.Choices, err = splitInts(choices); err != nil {
+			return nil, err
+		}
+		t.Time = created.Time
+		if v, ok := results[voteID]; ok {
+			v.Ticks[t.UserID] = &t
+		}
+	}
+
+	return results, p.wrapErr(tickRows.Err())
+}
+
+// queryGrants runs the given query, which must select
+// the grantColumns, and collects the matching grants.
+func (p *Postgres) queryGrants(ctx context.Context, query string, args ...any) ([]database.PermissionGrant, error) {
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, p.wrapErr(err)
+	}
+	defer rows.Close()
+
+	grants := []database.PermissionGrant{}
+	for rows.Next() {
+		var g database.PermissionGrant
+		err = rows.Scan(&g.GuildID, &g.ScopeID, &g.TargetID, &g.TargetType, &g.Rule, &g.ChannelID, &g.Expires)
+		if err != nil {
+			return nil, p.wrapErr(err)
+		}
+		grants = append(grants, g)
+	}
+
+	return grants, rows.Err()
+}
+
+// queryChanges runs the given query, which must select
+// the changeColumns, and collects the matching changes.
+func (p *Postgres) queryChanges(ctx context.Context, query string, args ...any) ([]database.PermissionChange, error) {
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, p.wrapErr(err)
+	}
+	defer rows.Close()
+
+	changes := []database.PermissionChange{}
+	for rows.Next() {
+		var (
+			c              database.PermissionChange
+			oldStr, newStr string
+		)
+		err = rows.Scan(&c.ID, &c.GuildID, &c.ScopeID, &c.TargetID, &c.TargetType, &c.ActorID,
+			&oldStr, &newStr, &c.Created)
+		if err != nil {
+			return nil, p.wrapErr(err)
+		}
+		c.Old, c.New = splitList(oldStr), splitList(newStr)
+		changes = append(changes, c)
+	}
+
+	return changes, rows.Err()
+}
+
 func (p *Postgres) wrapErr(err error) error {
 	if err != nil && err == sql.ErrNoRows {
 		return dberr.ErrNotFound
@@ -290,34 +1036,93 @@ func (p *Postgres) wrapErr(err error) error {
 	return err
 }
 
-// GetValue retrieves a specific value from a PostgresSQL table.
-func GetValue[TVal, TWv any](t *Postgres, table, valueKey, whereKey string, whereValue TWv) (TVal, error) {
-	var value TVal
-	query := fmt.Sprintf(`SELECT "%s" FROM %s WHERE "%s" = $1`, valueKey, table, whereKey)
-	err := t.db.QueryRow(query, whereValue).Scan(&value)
-	return value, t.wrapErr(err)
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
 }
 
-// SetValue updates a specific value in a PostgresSQL table, or inserts a new row if none is found.
-func SetValue[TVal, TWv any](t *Postgres, table, valueKey string, value TVal, whereKey string, whereValue TWv) error {
-	updateQuery := fmt.Sprintf(`UPDATE %s SET "%s" = $1 WHERE "%s" = $2`, table, valueKey, whereKey)
-	result, err := t.db.Exec(updateQuery, value, whereValue)
-	if err != nil {
-		return err
+func splitList(s string) []string {
+	if s == "" {
+		return []string{}
 	}
+	return strings.Split(s, ",")
+}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return err
+// joinInts joins the numbers to a comma separated list.
+func joinInts(ns []int) string {
+	strs := make([]string, len(ns))
+	for i, n := range ns {
+		strs[i] = strconv.Itoa(n)
 	}
+	return strings.Join(strs, ",")
+}
 
-	if rowsAffected == 0 {
-		insertQuery := fmt.Sprintf(`INSERT INTO %s ("%s", "%s") VALUES ($1, $2)`, table, whereKey, valueKey)
-		_, err = t.db.Exec(insertQuery, whereValue, value)
-		if err == nil {
-			return err
+// splitInts parses a list joined by joinInts. An empty
+// list results in nil.
+func splitInts(s string) ([]int, error) {
+	if s == "" {
+		return nil, nil
+	}
+	strs := strings.Split(s, ",")
+	ns := make([]int, len(strs))
+	for i, str := range strs {
+		n, err := strconv.Atoi(str)
+		if err != nil {
+			return nil, err
 		}
+		ns[i] = n
 	}
+	return ns, nil
+}
 
+// joinWeights joins the weights to a comma separated
+// list of key:weight pairs ordered by key.
+func joinWeights(weights map[string]int) string {
+	pairs := make([]string, 0, len(weights))
+	for k, w := range weights {
+		pairs = append(pairs, k+":"+strconv.Itoa(w))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// splitWeights parses a list joined by joinWeights. An
+// empty list results in nil.
+func splitWeights(s string) (map[string]int, error) {
+	if s == "" {
+		return nil, nil
+	}
+	weights := make(map[string]int)
+	for _, pair := range strings.Split(s, ",") {
+		k, wStr, _ := strings.Cut(pair, ":")
+		w, err := strconv.Atoi(wStr)
+		if err != nil {
+			return nil, err
+		}
+		weights[k] = w
+	}
+	return weights, nil
+}
+
+// voteMode returns the mode stored for votes of mode m.
+func voteMode(m vote.Mode) vote.Mode {
+	if m == "" {
+		return vote.ModeSingle
+	}
+	return m
+}
+
+// GetValue retrieves a specific value from a PostgresSQL table.
+func GetValue[TVal, TWv any](ctx context.Context, t *Postgres, table, valueKey, whereKey string, whereValue TWv) (TVal, error) {
+	var value TVal
+	query := fmt.Sprintf(`SELECT "%s" FROM %s WHERE "%s" = $1`, valueKey, table, whereKey)
+	err := t.db.QueryRowContext(ctx, query, whereValue).Scan(&value)
+	return value, t.wrapErr(err)
+}
+
+// SetValue updates a specific value in a PostgresSQL table, or inserts a new row if none is found.
+func SetValue[TVal, TWv any](ctx context.Context, t *Postgres, table, valueKey string, value TVal, whereKey string, whereValue TWv) error {
+	query := fmt.Sprintf(`INSERT INTO %s ("%s", "%s") VALUES ($1, $2) ON CONFLICT ("%s") DO UPDATE SET "%s" = $2`,
+		table, whereKey, valueKey, whereKey, valueKey)
+	_, err := t.db.ExecContext(ctx, query, whereValue, value)
 	return err
 }
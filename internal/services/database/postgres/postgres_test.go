@@ -0,0 +1,47 @@
+package postgres
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/zekurio/daemon/internal/models"
+	"github.com/zekurio/daemon/internal/services/database"
+	"github.com/zekurio/daemon/internal/services/database/dbtest"
+)
+
+// The conformance tests require a running Postgres instance and
+// are skipped unless DAEMON_TEST_POSTGRES_HOST is set. The database
+// given is truncated before each test.
+func TestConformance(t *testing.T) {
+	host := os.Getenv("DAEMON_TEST_POSTGRES_HOST")
+	if host == "" {
+		t.Skip("DAEMON_TEST_POSTGRES_HOST is not set")
+	}
+
+	port, _ := strconv.Atoi(os.Getenv("DAEMON_TEST_POSTGRES_PORT"))
+	if port == 0 {
+		port = 5432
+	}
+
+	cfg := models.PostgresConfig{
+		Host:     host,
+		Port:     port,
+		Database: os.Getenv("DAEMON_TEST_POSTGRES_DATABASE"),
+		Username: os.Getenv("DAEMON_TEST_POSTGRES_USERNAME"),
+		Password: os.Getenv("DAEMON_TEST_POSTGRES_PASSWORD"),
+	}
+
+	dbtest.Run(t, func(t *testing.T) database.Database {
+		p, err := InitPostgres(context.Background(), cfg, 5*time.Second)
+		require.Nil(t, err)
+		require.Nil(t, p.Migrator().Up())
+		_, err = p.db.Exec(`TRUNCATE guilds, permissions, user_permissions, scoped_permissions, permission_grants, permission_groups, permission_history, votes, vote_ticks, autovoice, guild_deletions`)
+		require.Nil(t, err)
+		return p
+	})
+}
@@ -0,0 +1,1180 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/zekurio/daemon/internal/models"
+	"github.com/zekurio/daemon/internal/services/database"
+	"github.com/zekurio/daemon/internal/services/database/dberr"
+	"github.com/zekurio/daemon/internal/services/database/migrations"
+	"github.com/zekurio/daemon/internal/util/autovoice"
+	"github.com/zekurio/daemon/internal/util/vote"
+	"github.com/zekurio/daemon/pkg/perms"
+)
+
+type SQLite struct {
+	db           *sql.DB
+	queryTimeout time.Duration
+}
+
+var (
+	_           database.Database   = (*SQLite)(nil)
+	_           database.Migratable = (*SQLite)(nil)
+	guildTables                     = []string{"guilds", "permissions", "votes", "autovoice", "guild_deletions", "user_permissions", "scoped_permissions", "permission_grants", "permission_groups", "permission_history"}
+)
+
+func InitSQLite(c models.SQLiteConfig, queryTimeout time.Duration) (*SQLite, error) {
+	var (
+		s   = SQLite{queryTimeout: queryTimeout}
+		err error
+	)
+
+	dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)", c.Path)
+	s.db, err = sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite only allows one writer at a time, so we serialize
+	// all access through a single connection to avoid running
+	// into "database is locked" errors.
+	s.db.SetMaxOpenConns(1)
+
+	err = s.db.Ping()
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+func (s *SQLite) Migrator() *migrations.Migrator {
+	return migrations.NewMigrator(s.db, "sqlite3", "sqlite")
+}
+
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
+
+// GUILDS
+
+func (s *SQLite) GetAutoRoles(ctx context.Context, guildID string) ([]string, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	roleStr, err := GetValue[string](ctx, s, "guilds", "autorole_ids", "guild_id", guildID)
+	if roleStr == "" {
+		return []string{}, err
+	}
+
+	return strings.Split(roleStr, ","), nil
+}
+
+func (s *SQLite) SetAutoRoles(ctx context.Context, guildID string, roleIDs []string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return SetValue(ctx, s, "guilds", "autorole_ids", strings.Join(roleIDs, ","), "guild_id", guildID)
+}
+
+func (s *SQLite) GetAutoVoice(ctx context.Context, guildID string) ([]string, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	chStr, err := GetValue[string](ctx, s, "guilds", "autovoice_ids", "guild_id", guildID)
+	if chStr == "" {
+		return []string{}, err
+	}
+
+	return strings.Split(chStr, ","), nil
+}
+
+func (s *SQLite) SetAutoVoice(ctx context.Context, guildID string, channelIDs []string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return SetValue(ctx, s, "guilds", "autovoice_ids", strings.Join(channelIDs, ","), "guild_id", guildID)
+}
+
+// PERMISSIONS
+
+func (s *SQLite) GetPermissions(ctx context.Context, guildID string) (map[string]perms.Array, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	results := make(map[string]perms.Array)
+	rows, err := s.db.QueryContext(ctx, `SELECT role_id, perms FROM permissions WHERE guild_id = $1`, guildID)
+	if err != nil {
+		return nil, s.wrapErr(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var roleID string
+		var permStr string
+
+		err := rows.Scan(&roleID, &permStr)
+		if err != nil {
+			return nil, s.wrapErr(err)
+		}
+
+		results[roleID] = strings.Split(permStr, ",")
+	}
+
+	return results, nil
+}
+
+func (s *SQLite) SetPermissions(ctx context.Context, guildID, roleID string, perms perms.Array) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if len(perms) == 0 {
+		_, err := s.db.ExecContext(ctx, `DELETE FROM permissions WHERE guild_id = $1 AND role_id = $2`, guildID, roleID)
+		return err
+	}
+
+	pStr := strings.Join(perms, ",")
+	_, err := s.db.ExecContext(ctx, `INSERT INTO permissions (guild_id, role_id, perms) VALUES ($1, $2, $3)
+		ON CONFLICT (role_id) DO UPDATE SET perms = excluded.perms`, guildID, roleID, pStr)
+	return err
+
+}
+
+func (s *SQLite) GetUserPermissions(ctx context.Context, guildID string) (map[string]perms.Array, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	results := make(map[string]perms.Array)
+	rows, err := s.db.QueryContext(ctx, `SELECT user_id, perms FROM user_permissions WHERE guild_id = $1`, guildID)
+	if err != nil {
+		return nil, s.wrapErr(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID, permStr string
+		if err = rows.Scan(&userID, &permStr); err != nil {
+			return nil, s.wrapErr(err)
+		}
+		results[userID] = strings.Split(permStr, ",")
+	}
+
+	return results, rows.Err()
+}
+
+func (s *SQLite) SetUserPermissions(ctx context.Context, guildID, userID string, perms perms.Array) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if len(perms) == 0 {
+		_, err := s.db.ExecContext(ctx, `DELETE FROM user_permissions WHERE guild_id = $1 AND user_id = $2`,
+			guildID, userID)
+		return err
+	}
+
+	_, err := s.db.ExecContext(ctx, `INSERT INTO user_permissions (guild_id, user_id, perms) VALUES ($1, $2, $3)
+		ON CONFLICT (guild_id, user_id) DO UPDATE SET perms = excluded.perms`, guildID, userID, strings.Join(perms, ","))
+	return err
+}
+
+func (s *SQLite) GetPermissionGroups(ctx context.Context, guildID string) (map[string]perms.Array, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	results := make(map[string]perms.Array)
+	rows, err := s.db.QueryContext(ctx, `SELECT name, perms FROM permission_groups WHERE guild_id = $1`, guildID)
+	if err != nil {
+		return nil, s.wrapErr(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, permStr string
+		if err = rows.Scan(&name, &permStr); err != nil {
+			return nil, s.wrapErr(err)
+		}
+		results[name] = strings.Split(permStr, ",")
+	}
+
+	return results, rows.Err()
+}
+
+func (s *SQLite) SetPermissionGroup(ctx context.Context, guildID, name string, perms perms.Array) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if len(perms) == 0 {
+		_, err := s.db.ExecContext(ctx, `DELETE FROM permission_groups WHERE guild_id = $1 AND name = $2`,
+			guildID, name)
+		return err
+	}
+
+	_, err := s.db.ExecContext(ctx, `INSERT INTO permission_groups (guild_id, name, perms) VALUES ($1, $2, $3)
+		ON CONFLICT (guild_id, name) DO UPDATE SET perms = excluded.perms`, guildID, name, strings.Join(perms, ","))
+	return err
+}
+
+func (s *SQLite) GetScopedPermissions(ctx context.Context, guildID string) (database.ScopedPermissions, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	results := make(database.ScopedPermissions)
+	rows, err := s.db.QueryContext(ctx, `SELECT scope_id, target_id, perms FROM scoped_permissions WHERE guild_id = $1`,
+		guildID)
+	if err != nil {
+		return nil, s.wrapErr(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var scopeID, targetID, permStr string
+		if err = rows.Scan(&scopeID, &targetID, &permStr); err != nil {
+			return nil, s.wrapErr(err)
+		}
+		if results[scopeID] == nil {
+			results[scopeID] = make(map[string]perms.Array)
+		}
+		results[scopeID][targetID] = strings.Split(permStr, ",")
+	}
+
+	return results, rows.Err()
+}
+
+func (s *SQLite) SetScopedPermissions(ctx context.Context, guildID, scopeID, targetID string, perms perms.Array) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if len(perms) == 0 {
+		_, err := s.db.ExecContext(ctx, `DELETE FROM scoped_permissions
+			WHERE guild_id = $1 AND scope_id = $2 AND target_id = $3`, guildID, scopeID, targetID)
+		return err
+	}
+
+	_, err := s.db.ExecContext(ctx, `INSERT INTO scoped_permissions (guild_id, scope_id, target_id, perms)
+		VALUES ($1, $2, $3, $4) ON CONFLICT (guild_id, scope_id, target_id) DO UPDATE SET perms = excluded.perms`,
+		guildID, scopeID, targetID, strings.Join(perms, ","))
+	return err
+}
+
+const grantColumns = `guild_id, scope_id, target_id, target_type, rule, channel_id, expires`
+
+func (s *SQLite) GetPermissionGrants(ctx context.Context, guildID string) ([]database.PermissionGrant, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.queryGrants(ctx, `SELECT `+grantColumns+` FROM permission_grants WHERE guild_id = $1
+		ORDER BY expires`, guildID)
+}
+
+func (s *SQLite) GetExpiredPermissionGrants(ctx context.Context, before time.Time) ([]database.PermissionGrant, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.queryGrants(ctx, `SELECT `+grantColumns+` FROM permission_grants WHERE expires < $1
+		ORDER BY expires`, before.UTC())
+}
+
+func (s *SQLite) AddPermissionGrant(ctx context.Context, g database.PermissionGrant) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `INSERT INTO permission_grants (`+grantColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (guild_id, scope_id, target_id, rule) DO UPDATE SET
+			target_type = excluded.target_type, channel_id = excluded.channel_id, expires = excluded.expires`,
+		g.GuildID, g.ScopeID, g.TargetID, g.TargetType, g.Rule, g.ChannelID, g.Expires.UTC())
+	return err
+}
+
+func (s *SQLite) DeletePermissionGrant(ctx context.Context, g database.PermissionGrant) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `DELETE FROM permission_grants
+		WHERE guild_id = $1 AND scope_id = $2 AND target_id = $3 AND rule = $4`,
+		g.GuildID, g.ScopeID, g.TargetID, g.Rule)
+	return err
+}
+
+// PERMISSION HISTORY
+
+const changeColumns = `id, guild_id, scope_id, target_id, target_type, actor_id, old_perms, new_perms, created`
+
+func (s *SQLite) GetPermissionHistory(ctx context.Context, guildID, targetID string, offset, limit int) ([]database.PermissionChange, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if targetID == "" {
+		return s.queryChanges(ctx, `SELECT `+changeColumns+` FROM permission_history WHERE guild_id = $1
+			ORDER BY created DESC, id DESC LIMIT $2 OFFSET $3`, guildID, limit, offset)
+	}
+
+	return s.queryChanges(ctx, `SELECT `+changeColumns+` FROM permission_history
+		WHERE guild_id = $1 AND target_id = $2 ORDER BY created DESC, id DESC LIMIT $3 OFFSET $4`,
+		guildID, targetID, limit, offset)
+}
+
+func (s *SQLite) GetPermissionChange(ctx context.Context, id string) (database.PermissionChange, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	changes, err := s.queryChanges(ctx, `SELECT `+changeColumns+` FROM permission_history WHERE id = $1`, id)
+	if err != nil {
+		return database.PermissionChange{}, err
+	}
+
+	if len(changes) == 0 {
+		return database.PermissionChange{}, dberr.ErrNotFound
+	}
+
+	return changes[0], nil
+}
+
+func (s *SQLite) AddPermissionChange(ctx context.Context, c database.PermissionChange) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `INSERT INTO permission_history (`+changeColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		c.ID, c.GuildID, c.ScopeID, c.TargetID, c.TargetType, c.ActorID,
+		strings.Join(c.Old, ","), strings.Join(c.New, ","), c.Created.UTC())
+	return err
+}
+
+// VOTES
+
+const voteColumns = `id, guild_id, channel_id, msg_id, creator_id, description, image_url, expires, choices,
+	mode, max_choices, roles, weights, closed, starts`
+
+// tickBatchSize is the maximum number of vote IDs bound to
+// a single tick query, which stays well below the limit of
+// bound parameters per statement.
+const tickBatchSize = 500
+
+func (s *SQLite) GetVote(ctx context.Context, voteID string) (vote.Vote, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	votes, err := s.queryVotes(ctx, `SELECT `+voteColumns+` FROM votes WHERE id = $1`, voteID)
+	if err != nil {
+		return vote.Vote{}, err
+	}
+
+	v, ok := votes[voteID]
+	if !ok {
+		return vote.Vote{}, dberr.ErrNotFound
+	}
+
+	return v, nil
+}
+
+func (s *SQLite) GetVotes(ctx context.Context, guildID string) (map[string]vote.Vote, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.queryVotes(ctx, `SELECT `+voteColumns+` FROM votes WHERE guild_id = $1`, guildID)
+}
+
+func (s *SQLite) GetExpiredVotes(ctx context.Context, before time.Time) (map[string]vote.Vote, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.queryVotes(ctx, `SELECT `+voteColumns+` FROM votes
+		WHERE closed IS NULL AND msg_id NOT IN ('', $2) AND expires IS NOT NULL AND expires < $1`,
+		before.UTC(), vote.MsgIDPosting)
+}
+
+func (s *SQLite) GetDueVotes(ctx context.Context, before time.Time) (map[string]vote.Vote, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.queryVotes(ctx, `SELECT `+voteColumns+` FROM votes
+		WHERE msg_id = '' AND starts IS NOT NULL AND starts < $1`, before.UTC())
+}
+
+func (s *SQLite) AddUpdateVote(ctx context.Context, v vote.Vote) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.tx(ctx, func(tx *sql.Tx) error {
+		return upsertVote(ctx, tx, v)
+	})
+}
+
+func (s *SQLite) SwapVoteMsgID(ctx context.Context, voteID, oldID, newID string) (ok bool, err error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, `UPDATE votes SET msg_id = $3 WHERE id = $1 AND msg_id = $2`,
+		voteID, oldID, newID)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n != 0, err
+}
+
+func (s *SQLite) DeleteVote(ctx context.Context, voteID string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `DELETE FROM votes WHERE id = $1`, voteID)
+	return err
+}
+
+// AUTOVOICE
+
+func (s *SQLite) GetAVChannels(ctx context.Context, guildID string) (map[string]autovoice.AVChannel, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT channel_id, guild_id, owner_id, origin_channel_id
+		FROM autovoice WHERE guild_id = $1`, guildID)
+	if err != nil {
+		return nil, s.wrapErr(err)
+	}
+	defer rows.Close()
+
+	var results = make(map[string]autovoice.AVChannel)
+	for rows.Next() {
+		var av autovoice.AVChannel
+		err := rows.Scan(&av.CreatedChannelID, &av.GuildID, &av.OwnerID, &av.OriginChannelID)
+		if err != nil {
+			return nil, s.wrapErr(err)
+		}
+		results[av.CreatedChannelID] = av
+	}
+
+	return results, nil
+
+}
+
+func (s *SQLite) AddUpdateAVChannel(ctx context.Context, av autovoice.AVChannel) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `INSERT INTO autovoice (channel_id, guild_id, owner_id, origin_channel_id)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (channel_id) DO UPDATE SET
+			guild_id = excluded.guild_id, owner_id = excluded.owner_id,
+			origin_channel_id = excluded.origin_channel_id`,
+		av.CreatedChannelID, av.GuildID, av.OwnerID, av.OriginChannelID)
+	return err
+}
+
+func (s *SQLite) DeleteAVChannel(ctx context.Context, channelID string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `DELETE FROM autovoice WHERE channel_id = $1`, channelID)
+	return err
+}
+
+// DATA MANAGEMENT
+
+func (s *SQLite) FlushGuildData(ctx context.Context, guildID string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	// vote_ticks are removed by the foreign key cascade
+	return s.tx(ctx, func(tx *sql.Tx) error {
+
+		for _, table := range guildTables {
+			_, err := tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE guild_id = $1`, table), guildID)
+			if err != nil {
+				return fmt.Errorf("failed to flush table %s for guild %s: %v", table, guildID, err)
+			}
+		}
+
+		return nil
+
+	})
+
+}
+
+func (s *SQLite) MarkGuildDeletion(ctx context.Context, guildID string, deleteAfter time.Time) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `INSERT INTO guild_deletions (guild_id, delete_after) VALUES ($1, $2)
+		ON CONFLICT (guild_id) DO UPDATE SET delete_after = excluded.delete_after`, guildID, deleteAfter.UTC())
+	return err
+}
+
+func (s *SQLite) UnmarkGuildDeletion(ctx context.Context, guildID string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `DELETE FROM guild_deletions WHERE guild_id = $1`, guildID)
+	return err
+}
+
+func (s *SQLite) GetDueGuildDeletions(ctx context.Context, before time.Time) ([]string, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT guild_id FROM guild_deletions WHERE delete_after < $1`, before.UTC())
+	if err != nil {
+		return nil, s.wrapErr(err)
+	}
+	defer rows.Close()
+
+	guildIDs := []string{}
+	for rows.Next() {
+		var guildID string
+		if err = rows.Scan(&guildID); err != nil {
+			return nil, s.wrapErr(err)
+		}
+		guildIDs = append(guildIDs, guildID)
+	}
+
+	return guildIDs, rows.Err()
+}
+
+func (s *SQLite) ImportGuildData(ctx context.Context, guildID string, data database.GuildData) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.tx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `INSERT INTO guilds (guild_id, autorole_ids, autovoice_ids) VALUES ($1, $2, $3)
+			ON CONFLICT (guild_id) DO UPDATE SET
+				autorole_ids = excluded.autorole_ids, autovoice_ids = excluded.autovoice_ids`,
+			guildID, strings.Join(data.AutoRoles, ","), strings.Join(data.AutoVoice, ","))
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM permissions WHERE guild_id = $1`, guildID)
+		if err != nil {
+			return err
+		}
+
+		for roleID, p := range data.Permissions {
+			if len(p) == 0 {
+				continue
+			}
+			_, err = tx.ExecContext(ctx, `INSERT INTO permissions (guild_id, role_id, perms) VALUES ($1, $2, $3)
+				ON CONFLICT (role_id) DO UPDATE SET guild_id = excluded.guild_id, perms = excluded.perms`,
+				guildID, roleID, strings.Join(p, ","))
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, table := range []string{"user_permissions", "scoped_permissions", "permission_groups", "permission_grants"} {
+			if _, err = tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE guild_id = $1`, guildID); err != nil {
+				return err
+			}
+		}
+
+		for userID, p := range data.UserPermissions {
+			if len(p) == 0 {
+				continue
+			}
+			_, err = tx.ExecContext(ctx, `INSERT INTO user_permissions (guild_id, user_id, perms) VALUES ($1, $2, $3)`,
+				guildID, userID, strings.Join(p, ","))
+			if err != nil {
+				return err
+			}
+		}
+
+		for scopeID, targets := range data.ScopedPermissions {
+			for targetID, p := range targets {
+				if len(p) == 0 {
+					continue
+				}
+				_, err = tx.ExecContext(ctx, `INSERT INTO scoped_permissions (guild_id, scope_id, target_id, perms)
+					VALUES ($1, $2, $3, $4)`, guildID, scopeID, targetID, strings.Join(p, ","))
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		for name, p := range data.PermissionGroups {
+			if len(p) == 0 {
+				continue
+			}
+			_, err = tx.ExecContext(ctx, `INSERT INTO permission_groups (guild_id, name, perms) VALUES ($1, $2, $3)`,
+				guildID, name, strings.Join(p, ","))
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, g := range data.PermissionGrants {
+			_, err = tx.ExecContext(ctx, `INSERT INTO permission_grants (`+grantColumns+`)
+				VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (guild_id, scope_id, target_id, rule) DO UPDATE SET
+					target_type = excluded.target_type, channel_id = excluded.channel_id, expires = excluded.expires`,
+				guildID, g.ScopeID, g.TargetID, g.TargetType, g.Rule, g.ChannelID, g.Expires.UTC())
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, v := range data.Votes {
+			if err = upsertVote(ctx, tx, v); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *SQLite) GetUserData(ctx context.Context, userID string) (database.UserData, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	data := database.UserData{
+		Votes:             []vote.Vote{},
+		Ticks:             make(map[string][]int),
+		Permissions:       make(map[string]perms.Array),
+		ScopedPermissions: make(map[string]database.ScopedPermissions),
+		AVChannels:        []autovoice.AVChannel{},
+	}
+
+	votes, err := s.queryVotes(ctx, `SELECT `+voteColumns+` FROM votes WHERE creator_id = $1`, userID)
+	if err != nil {
+		return database.UserData{}, err
+	}
+	for _, v := range votes {
+		data.Votes = append(data.Votes, v)
+	}
+	sort.Slice(data.Votes, func(i, j int) bool {
+		return data.Votes[i].ID < data.Votes[j].ID
+	})
+
+	data.PermissionChanges, err = s.queryChanges(ctx, `SELECT `+changeColumns+` FROM permission_history
+		WHERE actor_id = $1 ORDER BY created, id`, userID)
+	if err != nil {
+		return database.UserData{}, err
+	}
+
+	err = s.tx(ctx, func(tx *sql.Tx) error {
+		hashes, err := tickHashes(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+
+		err = forTickBatches(hashes, func(cond string, args []any) error {
+			rows, err := tx.QueryContext(ctx, `SELECT vote_id, tick, choices FROM vote_ticks WHERE `+cond, args...)
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+
+			for rows.Next() {
+				var (
+					voteID  string
+					t       vote.Tick
+					choices string
+				)
+				if err = rows.Scan(&voteID, &t.Tick, &choices); err != nil {
+					return err
+				}
+				if t.Choices, err = splitInts(choices); err != nil {
+					return err
+				}
+				data.Ticks[voteID] = t.Picks()
+			}
+
+			return rows.Err()
+		})
+		if err != nil {
+			return err
+		}
+
+		rows, err := tx.QueryContext(ctx, `SELECT guild_id, perms FROM user_permissions WHERE user_id = $1`, userID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var guildID, permStr string
+			if err = rows.Scan(&guildID, &permStr); err != nil {
+				return err
+			}
+			data.Permissions[guildID] = strings.Split(permStr, ",")
+		}
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		rows.Close()
+
+		rows, err = tx.QueryContext(ctx, `SELECT guild_id, scope_id, perms FROM scoped_permissions
+			WHERE target_id = $1`, userID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var guildID, scopeID, permStr string
+			if err = rows.Scan(&guildID, &scopeID, &permStr); err != nil {
+				return err
+			}
+			if data.ScopedPermissions[guildID] == nil {
+				data.ScopedPermissions[guildID] = make(database.ScopedPermissions)
+			}
+			data.ScopedPermissions[guildID][scopeID] = map[string]perms.Array{
+				userID: strings.Split(permStr, ","),
+			}
+		}
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		rows.Close()
+
+		rows, err = tx.QueryContext(ctx, `SELECT channel_id, guild_id, owner_id, origin_channel_id
+			FROM autovoice WHERE owner_id = $1 ORDER BY channel_id`, userID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var av autovoice.AVChannel
+			if err = rows.Scan(&av.CreatedChannelID, &av.GuildID, &av.OwnerID, &av.OriginChannelID); err != nil {
+				return err
+			}
+			data.AVChannels = append(data.AVChannels, av)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return database.UserData{}, err
+	}
+
+	return data, nil
+}
+
+func (s *SQLite) DeleteUserData(ctx context.Context, userID string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.tx(ctx, func(tx *sql.Tx) error {
+		hashes, err := tickHashes(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+
+		err = forTickBatches(hashes, func(cond string, args []any) error {
+			_, err := tx.ExecContext(ctx, `DELETE FROM vote_ticks WHERE `+cond, args...)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE votes SET creator_id = '' WHERE creator_id = $1`, userID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM user_permissions WHERE user_id = $1`, userID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM scoped_permissions WHERE target_id = $1`, userID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM permission_grants WHERE target_id = $1 AND target_type = $2`,
+			userID, database.GrantTargetUser)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM permission_history WHERE target_id = $1 AND target_type = $2`,
+			userID, database.GrantTargetUser)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE permission_history SET actor_id = '' WHERE actor_id = $1`, userID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM autovoice WHERE owner_id = $1`, userID)
+		return err
+	})
+}
+
+//
+// HELPERS
+//
+
+// withTimeout derives a context from ctx which is canceled after
+// the configured query timeout. A timeout of 0 disables it.
+func (s *SQLite) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.queryTimeout)
+}
+
+func (s *SQLite) tx(ctx context.Context, f func(*sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err = f(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// upsertVote inserts or updates the vote and replaces
+// all of its ticks.
+func upsertVote(ctx context.Context, tx *sql.Tx, v vote.Vote) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO votes (`+voteColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		ON CONFLICT (id) DO UPDATE SET
+			guild_id = excluded.guild_id, channel_id = excluded.channel_id, msg_id = excluded.msg_id,
+			creator_id = excluded.creator_id, description = excluded.description,
+			image_url = excluded.image_url, expires = excluded.expires, choices = excluded.choices,
+			mode = excluded.mode, max_choices = excluded.max_choices, roles = excluded.roles, weights = excluded.weights,
+			closed = excluded.closed, starts = excluded.starts`,
+		v.ID, v.GuildID, v.ChannelID, v.MsgID, v.CreatorID, v.Description, v.ImageURL,
+		nullTime(v.Expires), strings.Join(v.Possibilities, ","), voteMode(v.Mode), v.MaxChoices,
+		strings.Join(v.Roles, ","), joinWeights(v.Weights), nullTime(v.Closed), nullTime(v.Starts))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM vote_ticks WHERE vote_id = $1`, v.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range v.Ticks {
+		_, err = tx.ExecContext(ctx, `INSERT INTO vote_ticks (vote_id, user_hash, tick, choices, weight, created)
+			VALUES ($1, $2, $3, $4, $5, $6)`,
+			v.ID, t.UserID, t.Tick, joinInts(t.Choices), t.Weight, nullTime(t.Time))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// tickHashes returns the hashes under which the ticks of
+// the user would be stored by the ID of every vote.
+func tickHashes(ctx context.Context, tx *sql.Tx, userID string) (map[string]string, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM votes`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hashes := make(map[string]string)
+	for rows.Next() {
+		var voteID string
+		if err = rows.Scan(&voteID); err != nil {
+			return nil, err
+		}
+		if hashes[voteID], err = vote.HashUserID(voteID, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	return hashes, rows.Err()
+}
+
+// forTickBatches calls f with a condition matching the ticks
+// stored under the given hashes by vote ID and its arguments,
+// for up to tickBatchSize votes at a time.
+func forTickBatches(hashes map[string]string, f func(cond string, args []any) error) error {
+	var (
+		rowValues = make([]string, 0, tickBatchSize)
+		args      = make([]any, 0, 2*tickBatchSize)
+	)
+
+	flush := func() error {
+		if len(rowValues) == 0 {
+			return nil
+		}
+		err := f(`(vote_id, user_hash) IN (VALUES `+strings.Join(rowValues, ", ")+`)`, args)
+		rowValues, args = rowValues[:0], args[:0]
+		return err
+	}
+
+	for voteID, hash := range hashes {
+		rowValues = append(rowValues, fmt.Sprintf("($%d, $%d)", len(args)+1, len(args)+2))
+		args = append(args, voteID, hash)
+		if len(rowValues) == tickBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return flush()
+}
+
+// queryVotes runs the given query, which must select the
+// voteColumns, and collects the matching votes including
+// their ticks, which are loaded in batches of
+// tickBatchSize votes per query.
+func (s *SQLite) queryVotes(ctx context.Context, query string, args ...any) (map[string]vote.Vote, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, s.wrapErr(err)
+	}
+	defer rows.Close()
+
+	results := make(map[string]vote.Vote)
+	for rows.Next() {
+		var (
+			v       vote.Vote
+			expires sql.NullTime
+			choices string
+			mode    string
+			roles   string
+			weights string
+			closed  sql.NullTime
+			starts  sql.NullTime
+		)
+		err = rows.Scan(&v.ID, &v.GuildID, &v.ChannelID, &v.MsgID, &v.CreatorID,
+			&v.Description, &v.ImageURL, &expires, &choices, &mode, &v.MaxChoices, &roles, &weights, &closed, &starts)
+		if err != nil {
+			return nil, s.wrapErr(err)
+		}
+		v.Expires = expires.Time
+		v.Closed = closed.Time
+		v.Starts = starts.Time
+		v.Possibilities = splitList(choices)
+		v.Mode = vote.Mode(mode)
+		v.Roles = splitList(roles)
+		if v.Weights, err = splitWeights(weights); err != nil {
+			return nil, err
+		}
+		v.Ticks = make(map[string]*vote.Tick)
+		results[v.ID] = v
+	}
+	if err = rows.Err(); err != nil {
+		return nil, s.wrapErr(err)
+	}
+	rows.Close()
+
+	ids := make([]any, 0, len(results))
+	for id := range results {
+		ids = append(ids, id)
+	}
+
+	for len(ids) > 0 {
+		n := len(ids)
+		if n > tickBatchSize {
+			n = tickBatchSize
+		}
+		if err = s.queryTicks(ctx, results, ids[:n]); err != nil {
+			return nil, err
+		}
+		ids = ids[n:]
+	}
+
+	return results, nil
+}
+
+// queryTicks loads the ticks of the votes with the given
+// IDs into their entries of results.
+func (s *SQLite) queryTicks(ctx context.Context, results map[string]vote.Vote, ids []any) error {
+	placeholders := make([]string, len(ids))
+	for i := range ids {
+		placeholders[i] = "$" + strconv.Itoa(i+1)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT vote_id, user_hash, tick, choices, weight, created
+		FROM vote_ticks WHERE vote_id IN (`+strings.Join(placeholders, ", ")+`)`, ids...)
+	if err != nil {
+		return s.wrapErr(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			voteID  string
+			t       vote.Tick
+			choices string
+			created sql.NullTime
+		)
+		if err = rows.Scan(&voteID, &t.UserID, &t.Tick, &choices, &t.Weight, &created); err != nil {
+			return s.wrapErr(err)
+		}
+		if t.Choices, err = splitInts(choices); err != nil {
+			return err
+		}
+		t.Time = created.Time
+		if v, ok := results[voteID]; ok {
+			v.Ticks[t.UserID] = &t
+		}
+	}
+
+	return s.wrapErr(rows.Err())
+}
+
+// queryGrants runs the given query, which must select
+// the grantColumns, and collects the matching grants.
+func (s *SQLite) queryGrants(ctx context.Context, query string, args ...any) ([]database.PermissionGrant, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, s.wrapErr(err)
+	}
+	defer rows.Close()
+
+	grants := []database.PermissionGrant{}
+	for rows.Next() {
+		var g database.PermissionGrant
+		err = rows.Scan(&g.GuildID, &g.ScopeID, &g.TargetID, &g.TargetType, &g.Rule, &g.ChannelID, &g.Expires)
+		if err != nil {
+			return nil, s.wrapErr(err)
+		}
+		grants = append(grants, g)
+	}
+
+	return grants, rows.Err()
+}
+
+// queryChanges runs the given query, which must select
+// the changeColumns, and collects the matching changes.
+func (s *SQLite) queryChanges(ctx context.Context, query string, args ...any) ([]database.PermissionChange, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, s.wrapErr(err)
+	}
+	defer rows.Close()
+
+	changes := []database.PermissionChange{}
+	for rows.Next() {
+		var (
+			c              database.PermissionChange
+			oldStr, newStr string
+		)
+		err = rows.Scan(&c.ID, &c.GuildID, &c.ScopeID, &c.TargetID, &c.TargetType, &c.ActorID,
+			&oldStr, &newStr, &c.Created)
+		if err != nil {
+			return nil, s.wrapErr(err)
+		}
+		c.Old, c.New = splitList(oldStr), splitList(newStr)
+		changes = append(changes, c)
+	}
+
+	return changes, rows.Err()
+}
+
+func (s *SQLite) wrapErr(err error) error {
+	if err != nil && err == sql.ErrNoRows {
+		return dberr.ErrNotFound
+	}
+	return err
+}
+
+// nullTime converts t to UTC before storing it because SQLite
+// compares DATETIME values as plain strings.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t.UTC(), Valid: !t.IsZero()}
+}
+
+func splitList(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, ",")
+}
+
+// joinInts joins the numbers to a comma separated list.
+func joinInts(ns []int) string {
+	strs := make([]string, len(ns))
+	for i, n := range ns {
+		strs[i] = strconv.Itoa(n)
+	}
+	return strings.Join(strs, ",")
+}
+
+// splitInts parses a list joined by joinInts. An empty
+// list results in nil.
+func splitInts(s string) ([]int, error) {
+	if s == "" {
+		return nil, nil
+	}
+	strs := strings.Split(s, ",")
+	ns := make([]int, len(strs))
+	for i, str := range strs {
+		n, err := strconv.Atoi(str)
+		if err != nil {
+			return nil, err
+		}
+		ns[i] = n
+	}
+	return ns, nil
+}
+
+// joinWeights joins the weights to a comma separated
+// list of key:weight pairs ordered by key.
+func joinWeights(weights map[string]int) string {
+	pairs := make([]string, 0, len(weights))
+	for k, w := range weights {
+		pairs = append(pairs, k+":"+strconv.Itoa(w))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// splitWeights parses a list joined by joinWeights. An
+// empty list results in nil.
+func splitWeights(s string) (map[string]int, error) {
+	if s == "" {
+		return nil, nil
+	}
+	weights := make(map[string]int)
+	for _, pair := range strings.Split(s, ",") {
+		k, wStr, _ := strings.Cut(pair, ":")
+		w, err := strconv.Atoi(wStr)
+		if err != nil {
+			return nil, err
+		}
+		weights[k] = w
+	}
+	return weights, nil
+}
+
+// voteMode returns the mode stored for votes of mode m.
+func voteMode(m vote.Mode) vote.Mode {
+	if m == "" {
+		return vote.ModeSingle
+	}
+	return m
+}
+
+// GetValue retrieves a specific value from a SQLite table.
+func GetValue[TVal, TWv any](ctx context.Context, t *SQLite, table, valueKey, whereKey string, whereValue TWv) (TVal, error) {
+	var value TVal
+	query := fmt.Sprintf(`SELECT "%s" FROM %s WHERE "%s" = $1`, valueKey, table, whereKey)
+	err := t.db.QueryRowContext(ctx, query, whereValue).Scan(&value)
+	return value, t.wrapErr(err)
+}
+
+// SetValue updates a specific value in a SQLite table, or inserts a new row if none is found.
+func SetValue[TVal, TWv any](ctx context.Context, t *SQLite, table, valueKey string, value TVal, whereKey string, whereValue TWv) error {
+	query := fmt.Sprintf(`INSERT INTO %s ("%s", "%s") VALUES ($1, $2) ON CONFLICT ("%s") DO UPDATE SET "%s" = excluded."%s"`,
+		table, whereKey, valueKey, whereKey, valueKey, valueKey)
+	_, err := t.db.ExecContext(ctx, query, whereValue, value)
+	return err
+}
@@ -0,0 +1,169 @@
+package sqlite
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zekurio/daemon/internal/models"
+	"github.com/zekurio/daemon/internal/services/database"
+	"github.com/zekurio/daemon/internal/services/database/dbtest"
+	"github.com/zekurio/daemon/internal/util/autovoice"
+	"github.com/zekurio/daemon/internal/util/vote"
+)
+
+func newSQLite(t *testing.T) *SQLite {
+	s, err := InitSQLite(models.SQLiteConfig{
+		Path: filepath.Join(t.TempDir(), "daemon.db"),
+	}, 5*time.Second)
+	require.Nil(t, err)
+	require.Nil(t, s.Migrator().Up())
+	return s
+}
+
+func TestConformance(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T) database.Database {
+		return newSQLite(t)
+	})
+}
+
+func TestMigrator(t *testing.T) {
+	s := newSQLite(t)
+	defer s.Close()
+
+	m := s.Migrator()
+
+	latest, err := m.Version()
+	require.Nil(t, err)
+	assert.Equal(t, int64(15), latest)
+
+	require.Nil(t, m.Down())
+	version, err := m.Version()
+	require.Nil(t, err)
+	assert.Equal(t, latest-1, version)
+
+	require.Nil(t, m.To(3))
+	version, err = m.Version()
+	require.Nil(t, err)
+	assert.Equal(t, int64(3), version)
+
+	require.Nil(t, m.Status())
+
+	require.Nil(t, m.To(latest))
+	version, err = m.Version()
+	require.Nil(t, err)
+	assert.Equal(t, latest, version)
+}
+
+func TestUserDataTickBatches(t *testing.T) {
+	s := newSQLite(t)
+	defer s.Close()
+
+	ctx := context.Background()
+
+	const userID = "1100000000000000200"
+
+	// The ticks of the user are spread over more votes than
+	// are looked up in a single query.
+	want := make(map[string][]int)
+	for i := 0; i < tickBatchSize+2; i++ {
+		v := vote.Vote{
+			ID:            strconv.Itoa(1100000000000000001 + i),
+			GuildID:       "1",
+			Possibilities: []string{"a", "b"},
+			Ticks:         map[string]*vote.Tick{},
+		}
+		if i%2 == 0 {
+			hash, err := vote.HashUserID(v.ID, userID)
+			require.Nil(t, err)
+			v.Ticks[hash] = &vote.Tick{UserID: hash, Tick: 1}
+			want[v.ID] = []int{1}
+		}
+		v.Ticks["hash-b"] = &vote.Tick{UserID: "hash-b", Tick: 0}
+		require.Nil(t, s.AddUpdateVote(ctx, v))
+	}
+
+	data, err := s.GetUserData(ctx, userID)
+	require.Nil(t, err)
+	assert.Equal(t, want, data.Ticks)
+
+	require.Nil(t, s.DeleteUserData(ctx, userID))
+
+	data, err = s.GetUserData(ctx, userID)
+	require.Nil(t, err)
+	assert.Empty(t, data.Ticks)
+
+	votes, err := s.GetVotes(ctx, "1")
+	require.Nil(t, err)
+	require.Len(t, votes, tickBatchSize+2)
+	for _, v := range votes {
+		assert.Len(t, v.Ticks, 1)
+	}
+}
+
+func TestLegacyBlobMigration(t *testing.T) {
+	s := newSQLite(t)
+	defer s.Close()
+
+	m := s.Migrator()
+	require.Nil(t, m.To(3))
+
+	v := vote.Vote{
+		ID:            "1100000000000000001",
+		GuildID:       "1096747334987161600",
+		CreatorID:     "1100000000000000200",
+		Description:   "Pizza or pasta?",
+		Possibilities: []string{"pizza", "pasta"},
+		Ticks: map[string]*vote.Tick{
+			"hash-a": {UserID: "hash-a", Tick: 1},
+		},
+	}
+	rawVote, err := vote.Marshal(v)
+	require.Nil(t, err)
+
+	av := autovoice.AVChannel{
+		GuildID:          "1096747334987161600",
+		OwnerID:          "30",
+		OriginChannelID:  "31",
+		CreatedChannelID: "32",
+	}
+	rawAv, err := autovoice.Marshal(av)
+	require.Nil(t, err)
+
+	_, err = s.db.Exec(`INSERT INTO votes (id, json_data) VALUES ($1, $2), ('broken', 'not-gob')`, v.ID, rawVote)
+	require.Nil(t, err)
+	_, err = s.db.Exec(`INSERT INTO autovoice (id, json_data) VALUES ($1, $2)`, av.CreatedChannelID, rawAv)
+	require.Nil(t, err)
+
+	require.Nil(t, m.Up())
+
+	got, err := s.GetVote(context.Background(), v.ID)
+	require.Nil(t, err)
+	assert.Equal(t, v.Possibilities, got.Possibilities)
+	// Legacy ticks count once.
+	assert.Equal(t, map[string]*vote.Tick{
+		"hash-a": {UserID: "hash-a", Tick: 1, Weight: 1},
+	}, got.Ticks)
+
+	avs, err := s.GetAVChannels(context.Background(), av.GuildID)
+	require.Nil(t, err)
+	assert.Equal(t, map[string]autovoice.AVChannel{"32": av}, avs)
+
+	// The undecodable vote must be kept instead of being dropped.
+	var legacyCount int
+	require.Nil(t, s.db.QueryRow(`SELECT COUNT(*) FROM votes_legacy`).Scan(&legacyCount))
+	assert.Equal(t, 1, legacyCount)
+
+	require.Nil(t, m.To(3))
+
+	var rawData string
+	require.Nil(t, s.db.QueryRow(`SELECT json_data FROM votes WHERE id = $1`, v.ID).Scan(&rawData))
+	restored, err := vote.Unmarshal(rawData)
+	require.Nil(t, err)
+	assert.Equal(t, v.Ticks, restored.Ticks)
+}
@@ -0,0 +1,160 @@
+package permissions
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/zekurio/daemon/internal/services/database"
+	"github.com/zekurio/daemon/pkg/perms"
+)
+
+// maxCachedGuildEntries limits the number of resolved rule
+// sets cached per guild. Once reached, the cached entries of
+// the guild are dropped.
+const maxCachedGuildEntries = 10000
+
+// memberKey identifies the resolved rules of a member in a
+// channel, or guild wide when channelID is empty.
+type memberKey struct {
+	memberID, channelID string
+}
+
+// MemberCache caches the resolved rules of guild members. It
+// is invalidated by gateway events and by every write to the
+// rules through the database returned by WatchDatabase.
+type MemberCache struct {
+	mtx    sync.RWMutex
+	guilds map[string]map[memberKey]perms.Tiers
+
+	// generation is increased on every invalidation so that
+	// rules resolved before are not cached afterwards.
+	generation atomic.Uint64
+}
+
+func NewMemberCache() *MemberCache {
+	return &MemberCache{
+		guilds: make(map[string]map[memberKey]perms.Tiers),
+	}
+}
+
+// InvalidateGuild drops the cached rules of all members
+// of the guild.
+func (c *MemberCache) InvalidateGuild(guildID string) {
+	c.generation.Add(1)
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	delete(c.guilds, guildID)
+}
+
+// InvalidateMember drops the cached rules of the member
+// in all channels of the guild.
+func (c *MemberCache) InvalidateMember(guildID, memberID string) {
+	c.generation.Add(1)
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for k := range c.guilds[guildID] {
+		if k.memberID == memberID {
+			delete(c.guilds[guildID], k)
+		}
+	}
+}
+
+// InvalidateUser drops the cached rules of the user in
+// all guilds.
+func (c *MemberCache) InvalidateUser(userID string) {
+	c.generation.Add(1)
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for _, entries := range c.guilds {
+		for k := range entries {
+			if k.memberID == userID {
+				delete(entries, k)
+			}
+		}
+	}
+}
+
+// get returns the cached rules, which must not be modified,
+// and the current generation to pass to set.
+func (c *MemberCache) get(guildID, channelID, memberID string) (p perms.Tiers, ok bool, gen uint64) {
+	gen = c.generation.Load()
+
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	p, ok = c.guilds[guildID][memberKey{memberID, channelID}]
+	return p, ok, gen
+}
+
+// set caches the rules unless the cache has been invalidated
+// since gen has been obtained.
+func (c *MemberCache) set(gen uint64, guildID, channelID, memberID string, p perms.Tiers) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.generation.Load() != gen {
+		return
+	}
+
+	entries, ok := c.guilds[guildID]
+	if !ok || len(entries) >= maxCachedGuildEntries {
+		entries = make(map[memberKey]perms.Tiers)
+		c.guilds[guildID] = entries
+	}
+
+	entries[memberKey{memberID, channelID}] = p
+}
+
+// WatchDatabase returns a database which invalidates the
+// cache after every write to permission rules.
+func (c *MemberCache) WatchDatabase(db database.Database) database.Database {
+	return &watchedDatabase{Database: db, cache: c}
+}
+
+type watchedDatabase struct {
+	database.Database
+
+	cache *MemberCache
+}
+
+func (w *watchedDatabase) SetPermissions(ctx context.Context, guildID, roleID string, p perms.Array) error {
+	defer w.cache.InvalidateGuild(guildID)
+	return w.Database.SetPermissions(ctx, guildID, roleID, p)
+}
+
+func (w *watchedDatabase) SetUserPermissions(ctx context.Context, guildID, userID string, p perms.Array) error {
+	defer w.cache.InvalidateMember(guildID, userID)
+	return w.Database.SetUserPermissions(ctx, guildID, userID, p)
+}
+
+func (w *watchedDatabase) SetScopedPermissions(ctx context.Context, guildID, scopeID, targetID string, p perms.Array) error {
+	defer w.cache.InvalidateGuild(guildID)
+	return w.Database.SetScopedPermissions(ctx, guildID, scopeID, targetID, p)
+}
+
+func (w *watchedDatabase) SetPermissionGroup(ctx context.Context, guildID, name string, p perms.Array) error {
+	defer w.cache.InvalidateGuild(guildID)
+	return w.Database.SetPermissionGroup(ctx, guildID, name, p)
+}
+
+func (w *watchedDatabase) FlushGuildData(ctx context.Context, guildID string) error {
+	defer w.cache.InvalidateGuild(guildID)
+	return w.Database.FlushGuildData(ctx, guildID)
+}
+
+func (w *watchedDatabase) ImportGuildData(ctx context.Context, guildID string, data database.GuildData) error {
+	defer w.cache.InvalidateGuild(guildID)
+	return w.Database.ImportGuildData(ctx, guildID, data)
+}
+
+func (w *watchedDatabase) DeleteUserData(ctx context.Context, userID string) error {
+	defer w.cache.InvalidateUser(userID)
+	return w.Database.DeleteUserData(ctx, userID)
+}
@@ -0,0 +1,114 @@
+package permissions
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zekurio/daemon/internal/services/database/memory"
+	"github.com/zekurio/daemon/pkg/perms"
+)
+
+func newStateSession(t *testing.T) *discordgo.Session {
+	t.Helper()
+
+	s := &discordgo.Session{State: discordgo.NewState()}
+	require.Nil(t, s.State.GuildAdd(&discordgo.Guild{
+		ID: "1",
+		Roles: []*discordgo.Role{
+			{ID: "1", Position: 0},
+			{ID: "2", Position: 1},
+			{ID: "3", Position: 2},
+		},
+	}))
+	require.Nil(t, s.State.MemberAdd(&discordgo.Member{
+		GuildID: "1",
+		User:    &discordgo.User{ID: "40"},
+		Roles:   []string{"3", "2"},
+	}))
+	require.Nil(t, s.State.ChannelAdd(&discordgo.Channel{ID: "50", GuildID: "1"}))
+
+	return s
+}
+
+func TestResolveMemberPermsCached(t *testing.T) {
+	ctx := context.Background()
+	raw := memory.New()
+	cache := NewMemberCache()
+	db := cache.WatchDatabase(raw)
+	p := &Permissions{db: db, cache: cache}
+	s := newStateSession(t)
+
+	require.Nil(t, db.SetPermissions(ctx, "1", "2", perms.Array{"+dm.chat.*", "-dm.chat.vote"}))
+	require.Nil(t, db.SetPermissions(ctx, "1", "3", perms.Array{"+dm.chat.vote"}))
+
+	// The higher role overrides the rules of lower ones.
+	res, err := p.resolveMemberPerms(ctx, s, "1", "50", "40", nil)
+	require.Nil(t, err)
+	assert.Equal(t, perms.Tiers{nil, {"+dm.chat.*", "+dm.chat.vote"}}, res)
+
+	// Writes bypassing the watched database are not seen
+	// until the cache is invalidated.
+	require.Nil(t, raw.SetUserPermissions(ctx, "1", "40", perms.Array{"-dm.chat.*"}))
+	res, err = p.resolveMemberPerms(ctx, s, "1", "50", "40", nil)
+	require.Nil(t, err)
+	assert.Equal(t, perms.Tiers{nil, {"+dm.chat.*", "+dm.chat.vote"}}, res)
+
+	cache.InvalidateMember("1", "40")
+	res, err = p.resolveMemberPerms(ctx, s, "1", "50", "40", nil)
+	require.Nil(t, err)
+	assert.Equal(t, perms.Tiers{{"-dm.chat.*"}, {"+dm.chat.*", "+dm.chat.vote"}}, res)
+
+	require.Nil(t, db.SetScopedPermissions(ctx, "1", "50", "2", perms.Array{"-dm.chat.vote"}))
+	res, err = p.resolveMemberPerms(ctx, s, "1", "50", "40", nil)
+	require.Nil(t, err)
+	assert.Equal(t, perms.Tiers{nil, {"-dm.chat.vote"}, {"-dm.chat.*"}, {"+dm.chat.*", "+dm.chat.vote"}}, res)
+
+	// Guild wide rules are cached separately.
+	res, err = p.resolveMemberPerms(ctx, s, "1", "", "40", nil)
+	require.Nil(t, err)
+	assert.Equal(t, perms.Tiers{{"-dm.chat.*"}, {"+dm.chat.*", "+dm.chat.vote"}}, res)
+
+	require.Nil(t, db.DeleteUserData(ctx, "40"))
+	res, err = p.resolveMemberPerms(ctx, s, "1", "", "40", nil)
+	require.Nil(t, err)
+	assert.Equal(t, perms.Tiers{nil, {"+dm.chat.*", "+dm.chat.vote"}}, res)
+}
+
+func TestMemberCacheGeneration(t *testing.T) {
+	c := NewMemberCache()
+
+	_, ok, gen := c.get("1", "", "40")
+	assert.False(t, ok)
+
+	// Rules resolved before an invalidation are stale.
+	c.InvalidateGuild("2")
+	c.set(gen, "1", "", "40", perms.Tiers{{"+dm.*"}})
+	_, ok, gen = c.get("1", "", "40")
+	assert.False(t, ok)
+
+	c.set(gen, "1", "", "40", perms.Tiers{{"+dm.*"}})
+	c.set(gen, "1", "50", "40", perms.Tiers{{"+dm.*"}})
+	c.set(gen, "1", "", "41", perms.Tiers{{"+dm.*"}})
+	p, ok, _ := c.get("1", "", "40")
+	assert.True(t, ok)
+	assert.Equal(t, perms.Tiers{{"+dm.*"}}, p)
+
+	c.InvalidateMember("1", "40")
+	_, ok, _ = c.get("1", "", "40")
+	assert.False(t, ok)
+	_, ok, _ = c.get("1", "50", "40")
+	assert.False(t, ok)
+	_, ok, gen = c.get("1", "", "41")
+	assert.True(t, ok)
+
+	c.set(gen, "2", "", "41", perms.Tiers{{"+dm.*"}})
+	c.InvalidateUser("41")
+	_, ok, _ = c.get("1", "", "41")
+	assert.False(t, ok)
+	_, ok, _ = c.get("2", "", "41")
+	assert.False(t, ok)
+}
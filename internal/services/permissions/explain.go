@@ -0,0 +1,167 @@
+package permissions
+
+import (
+	"context"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/zekurio/daemon/pkg/perms"
+)
+
+// SourceKind specifies where a rule of the resolved
+// permissions of a user originates from.
+type SourceKind int
+
+const (
+	SourceRole SourceKind = iota
+	SourceAdminRules
+	SourceUserRules
+	SourceOwner
+	SourceUser
+)
+
+// Source describes the origin of a single rule.
+type Source struct {
+	Kind SourceKind
+	// RoleID is set for rules of SourceRole.
+	RoleID string
+	// UserID is set for rules of SourceUser.
+	UserID string
+	// ScopeID is the channel or category the rule is
+	// limited to, empty for guild wide rules.
+	ScopeID string
+	// Group is the name of the permission group the rule
+	// is taken from, empty for rules set directly.
+	Group string
+}
+
+func (s Source) String() string {
+	if s.ScopeID != "" {
+		scoped := s
+		scoped.ScopeID = ""
+		return scoped.String() + " in <#" + s.ScopeID + ">"
+	}
+
+	if s.Group != "" {
+		direct := s
+		direct.Group = ""
+		return direct.String() + " via `" + perms.GroupPrefix + s.Group + "`"
+	}
+
+	switch s.Kind {
+	case SourceRole:
+		return "<@&" + s.RoleID + ">"
+	case SourceUser:
+		return "<@" + s.UserID + ">"
+	case SourceAdminRules:
+		return "default admin rules"
+	case SourceUserRules:
+		return "default user rules"
+	case SourceOwner:
+		return "bot owner"
+	default:
+		return "unknown"
+	}
+}
+
+// Candidate is a rule of the resolved permissions of a
+// user together with its match against the checked
+// permission.
+type Candidate struct {
+	perms.Match
+	Source Source
+}
+
+// Explanation describes how the permission check of a
+// single permission was decided.
+type Explanation struct {
+	Perm string
+	// Candidates contains every resolved rule of the user
+	// tier by tier in the order of their precedence.
+	Candidates []Candidate
+	// Winner is the index of the deciding candidate or -1
+	// if no rule matched.
+	Winner  int
+	Allowed bool
+	// Override is set for the bot owner, the guild owner
+	// and administrators, who bypass explicit permissions.
+	Override bool
+}
+
+// Explain resolves the permissions of the user the same way
+// as HasPerms does and reports how every rule matched dn.
+func (p *Permissions) Explain(ctx context.Context, session *discordgo.Session, guildID, channelID, userID, dn string) (Explanation, error) {
+	trace := make(ruleTrace)
+
+	tiers, override, err := p.resolvePerms(ctx, session, guildID, channelID, userID, trace)
+	if err != nil {
+		return Explanation{}, err
+	}
+
+	e := Explanation{
+		Perm:     dn,
+		Winner:   -1,
+		Override: override,
+	}
+
+	decider, _, winner := tiers.Check(dn)
+	for i, tier := range tiers {
+		matches, _ := tier.Check(dn)
+		if i == decider {
+			e.Winner = len(e.Candidates) + winner
+			e.Allowed = matches[winner].Allow
+		}
+		for _, m := range matches {
+			e.Candidates = append(e.Candidates, Candidate{Match: m, Source: trace[traceKey{i, m.Rule}]})
+		}
+	}
+
+	return e, nil
+}
+
+// ruleTrace records the source of every rule of every tier
+// while the permissions of a user are merged together. A nil
+// trace merges without recording anything.
+type ruleTrace map[traceKey]Source
+
+// traceKey identifies a rule in a tier, as the same rule
+// may be set in multiple tiers.
+type traceKey struct {
+	tier int
+	rule string
+}
+
+// merge merges add into base like perms.Array.Merge and
+// attributes the rules taken from add to src in the given
+// tier. A nil base is replaced by add as is.
+func (t ruleTrace) merge(tier int, base, add perms.Array, src Source, override bool) perms.Array {
+	var res perms.Array
+	if base == nil {
+		res = add
+	} else {
+		res = base.Merge(add, override)
+	}
+
+	if t == nil {
+		return res
+	}
+
+	prev := make(map[string]struct{}, len(base))
+	for _, rule := range base {
+		prev[rule] = struct{}{}
+	}
+	added := make(map[string]struct{}, len(add))
+	for _, rule := range add {
+		added[rule] = struct{}{}
+	}
+
+	for _, rule := range res {
+		_, fromAdd := added[rule]
+		_, fromBase := prev[rule]
+		if fromAdd && (override || !fromBase) {
+			t[traceKey{tier, rule}] = src
+		}
+	}
+
+	return res
+}
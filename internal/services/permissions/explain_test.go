@@ -0,0 +1,79 @@
+package permissions
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zekurio/daemon/internal/services/database/memory"
+	"github.com/zekurio/daemon/pkg/perms"
+)
+
+func TestRuleTraceMerge(t *testing.T) {
+	var (
+		trace = make(ruleTrace)
+		lower = Source{Kind: SourceRole, RoleID: "1"}
+		upper = Source{Kind: SourceRole, RoleID: "2"}
+	)
+
+	res := trace.merge(1, nil, perms.Array{"+a.*", "+b"}, lower, true)
+	res = trace.merge(1, res, perms.Array{"-a.*", "+b", "+c"}, upper, true)
+	res = trace.merge(1, res, perms.Array{"+b", "+d"}, Source{Kind: SourceUserRules}, false)
+
+	// The same rule is traced separately in another tier.
+	user := Source{Kind: SourceUser, UserID: "40"}
+	trace.merge(0, nil, perms.Array{"+b"}, user, true)
+
+	assert.Equal(t, perms.Array{"-a.*", "+b", "+c", "+d"}, res)
+	assert.Equal(t, ruleTrace{
+		{1, "+a.*"}: lower,
+		{1, "-a.*"}: upper,
+		{1, "+b"}:   upper,
+		{1, "+c"}:   upper,
+		{1, "+d"}:   {Kind: SourceUserRules},
+		{0, "+b"}:   user,
+	}, trace)
+
+	// A nil trace only merges.
+	var nilTrace ruleTrace
+	assert.Equal(t, res, nilTrace.merge(1, perms.Array{"-a.*", "+b", "+c"}, perms.Array{"+d"}, upper, false))
+}
+
+func TestSourceString(t *testing.T) {
+	assert.Equal(t, "<@&1>", Source{Kind: SourceRole, RoleID: "1"}.String())
+	assert.Equal(t, "<@2>", Source{Kind: SourceUser, UserID: "2"}.String())
+	assert.Equal(t, "default user rules", Source{Kind: SourceUserRules}.String())
+	assert.Equal(t, "<@&1> via `@group:moderator` in <#3>",
+		Source{Kind: SourceRole, RoleID: "1", ScopeID: "3", Group: "moderator"}.String())
+}
+
+func TestExplainScopes(t *testing.T) {
+	ctx := context.Background()
+	cache := NewMemberCache()
+	db := cache.WatchDatabase(memory.New())
+	p := &Permissions{db: db, cache: cache}
+	s := newStateSession(t)
+
+	// The less specific rule of the channel decides over the
+	// exact rule of the guild.
+	require.Nil(t, db.SetPermissions(ctx, "1", "2", perms.Array{"-dm.chat.vote"}))
+	require.Nil(t, db.SetScopedPermissions(ctx, "1", "50", "2", perms.Array{"+dm.chat.*"}))
+
+	e, err := p.Explain(ctx, s, "1", "50", "40", "dm.chat.vote")
+	require.Nil(t, err)
+	assert.True(t, e.Allowed)
+	require.GreaterOrEqual(t, e.Winner, 0)
+	assert.Equal(t, Source{Kind: SourceRole, RoleID: "2", ScopeID: "50"}, e.Candidates[e.Winner].Source)
+
+	ok, _, err := p.HasPerms(ctx, s, "1", "50", "40", "dm.chat.vote")
+	require.Nil(t, err)
+	assert.True(t, ok)
+
+	e, err = p.Explain(ctx, s, "1", "", "40", "dm.chat.vote")
+	require.Nil(t, err)
+	assert.False(t, e.Allowed)
+	require.GreaterOrEqual(t, e.Winner, 0)
+	assert.Equal(t, "-dm.chat.vote", e.Candidates[e.Winner].Rule)
+}
@@ -0,0 +1,68 @@
+package permissions
+
+import (
+	"context"
+
+	"github.com/zekurio/daemon/internal/services/database"
+	"github.com/zekurio/daemon/pkg/perms"
+)
+
+// GrantSource returns the source of the rule of the grant
+// as shown by Explain.
+func GrantSource(g database.PermissionGrant) Source {
+	if g.TargetType == database.GrantTargetUser {
+		return Source{Kind: SourceUser, UserID: g.TargetID, ScopeID: g.ScopeID}
+	}
+	return Source{Kind: SourceRole, RoleID: g.TargetID, ScopeID: g.ScopeID}
+}
+
+// RevokeGrant removes the rule of the grant from its target and
+// deletes the grant. removed is false when the rule has already
+// been removed or changed in the meantime.
+func RevokeGrant(ctx context.Context, db database.Database, g database.PermissionGrant) (removed bool, err error) {
+	var (
+		current map[string]perms.Array
+		save    func(rules perms.Array) error
+	)
+
+	switch {
+	case g.ScopeID != "":
+		var scoped database.ScopedPermissions
+		scoped, err = db.GetScopedPermissions(ctx, g.GuildID)
+		current = scoped[g.ScopeID]
+		save = func(rules perms.Array) error {
+			return db.SetScopedPermissions(ctx, g.GuildID, g.ScopeID, g.TargetID, rules)
+		}
+	case g.TargetType == database.GrantTargetUser:
+		current, err = db.GetUserPermissions(ctx, g.GuildID)
+		save = func(rules perms.Array) error {
+			return db.SetUserPermissions(ctx, g.GuildID, g.TargetID, rules)
+		}
+	default:
+		current, err = db.GetPermissions(ctx, g.GuildID)
+		save = func(rules perms.Array) error {
+			return db.SetPermissions(ctx, g.GuildID, g.TargetID, rules)
+		}
+	}
+	if err != nil {
+		return false, err
+	}
+
+	rules := current[g.TargetID]
+	remaining := make(perms.Array, 0, len(rules))
+	for _, rule := range rules {
+		if rule == g.Rule {
+			removed = true
+			continue
+		}
+		remaining = append(remaining, rule)
+	}
+
+	if removed {
+		if err = save(remaining); err != nil {
+			return false, err
+		}
+	}
+
+	return removed, db.DeletePermissionGrant(ctx, g)
+}
@@ -0,0 +1,56 @@
+package permissions
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zekurio/daemon/internal/services/database"
+	"github.com/zekurio/daemon/internal/services/database/memory"
+	"github.com/zekurio/daemon/pkg/perms"
+)
+
+func TestRevokeGrant(t *testing.T) {
+	ctx := context.Background()
+	db := memory.New()
+
+	require.Nil(t, db.SetPermissions(ctx, "1", "2", perms.Array{"+dm.chat.*", "+dm.chat.vote"}))
+	require.Nil(t, db.SetUserPermissions(ctx, "1", "3", perms.Array{"+dm.chat.vote"}))
+	require.Nil(t, db.SetScopedPermissions(ctx, "1", "4", "2", perms.Array{"+dm.chat.vote"}))
+
+	grants := []database.PermissionGrant{
+		{GuildID: "1", TargetID: "2", TargetType: database.GrantTargetRole, Rule: "+dm.chat.vote"},
+		{GuildID: "1", TargetID: "3", TargetType: database.GrantTargetUser, Rule: "+dm.chat.vote"},
+		{GuildID: "1", ScopeID: "4", TargetID: "2", TargetType: database.GrantTargetRole, Rule: "+dm.chat.vote"},
+		{GuildID: "1", TargetID: "2", TargetType: database.GrantTargetRole, Rule: "-dm.chat.vote"},
+	}
+	for _, g := range grants {
+		g.Expires = time.Now()
+		require.Nil(t, db.AddPermissionGrant(ctx, g))
+	}
+
+	for i, expected := range []bool{true, true, true, false} {
+		removed, err := RevokeGrant(ctx, db, grants[i])
+		require.Nil(t, err)
+		assert.Equal(t, expected, removed, "grant %d", i)
+	}
+
+	p, err := db.GetPermissions(ctx, "1")
+	require.Nil(t, err)
+	assert.Equal(t, map[string]perms.Array{"2": {"+dm.chat.*"}}, p)
+
+	p, err = db.GetUserPermissions(ctx, "1")
+	require.Nil(t, err)
+	assert.Empty(t, p)
+
+	sp, err := db.GetScopedPermissions(ctx, "1")
+	require.Nil(t, err)
+	assert.Empty(t, sp)
+
+	left, err := db.GetPermissionGrants(ctx, "1")
+	require.Nil(t, err)
+	assert.Empty(t, left)
+}
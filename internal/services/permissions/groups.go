@@ -0,0 +1,34 @@
+package permissions
+
+import (
+	"context"
+
+	"github.com/zekurio/daemon/pkg/perms"
+)
+
+// Groups returns the permission groups available in the guild
+// by name. Groups of the guild replace the instance wide groups
+// of the config with the same name.
+func (p *Permissions) Groups(ctx context.Context, guildID string) (map[string]perms.Array, error) {
+	guildGroups, err := p.db.GetPermissionGroups(ctx, guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string]perms.Array, len(p.cfg.Permissions.Groups)+len(guildGroups))
+	for name, rules := range p.cfg.Permissions.Groups {
+		groups[name] = rules
+	}
+	for name, rules := range guildGroups {
+		groups[name] = rules
+	}
+
+	return groups, nil
+}
+
+// InstanceGroup returns the rules of the instance wide group
+// with the given name.
+func (p *Permissions) InstanceGroup(name string) (perms.Array, bool) {
+	rules, ok := p.cfg.Permissions.Groups[name]
+	return rules, ok
+}
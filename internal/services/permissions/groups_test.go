@@ -0,0 +1,84 @@
+package permissions
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zekurio/daemon/internal/models"
+	"github.com/zekurio/daemon/internal/services/database/memory"
+	"github.com/zekurio/daemon/pkg/perms"
+)
+
+func TestGroups(t *testing.T) {
+	ctx := context.Background()
+	db := memory.New()
+	p := &Permissions{db: db}
+	p.cfg.Permissions.Groups = map[string][]string{
+		"moderator":  {"+dm.guild.*"},
+		"event-host": {"+dm.chat.vote"},
+	}
+
+	require.Nil(t, db.SetPermissionGroup(ctx, "1", "moderator", perms.Array{"+dm.guild.config.autorole"}))
+	require.Nil(t, db.SetPermissionGroup(ctx, "1", "helper", perms.Array{"+dm.etc.*"}))
+
+	groups, err := p.Groups(ctx, "1")
+	require.Nil(t, err)
+	assert.Equal(t, map[string]perms.Array{
+		"moderator":  {"+dm.guild.config.autorole"},
+		"event-host": {"+dm.chat.vote"},
+		"helper":     {"+dm.etc.*"},
+	}, groups)
+
+	groups, err = p.Groups(ctx, "2")
+	require.Nil(t, err)
+	assert.Equal(t, map[string]perms.Array{
+		"moderator":  {"+dm.guild.*"},
+		"event-host": {"+dm.chat.vote"},
+	}, groups)
+}
+
+func TestMergeRulesGroups(t *testing.T) {
+	var (
+		trace  = make(ruleTrace)
+		src    = Source{Kind: SourceRole, RoleID: "2"}
+		groups = map[string]perms.Array{
+			"moderator": {"+dm.guild.*", "+dm.chat.vote"},
+		}
+	)
+
+	res := mergeRules(1, nil, perms.Array{"@group:moderator", "@group:unknown", "-dm.chat.vote"}, groups, src, trace)
+
+	// Rules set directly override the rules of the group.
+	assert.Equal(t, perms.Array{"+dm.guild.*", "-dm.chat.vote"}, res)
+	assert.Equal(t, Source{Kind: SourceRole, RoleID: "2", Group: "moderator"}, trace[traceKey{1, "+dm.guild.*"}])
+	assert.Equal(t, src, trace[traceKey{1, "-dm.chat.vote"}])
+}
+
+func TestResolveMemberPermsGroups(t *testing.T) {
+	ctx := context.Background()
+	cache := NewMemberCache()
+	db := cache.WatchDatabase(memory.New())
+	p := &Permissions{db: db, cache: cache, cfg: models.Config{
+		Permissions: models.PermissionRules{
+			Groups: map[string][]string{"moderator": {"+dm.guild.*"}},
+		},
+	}}
+	s := newStateSession(t)
+
+	require.Nil(t, db.SetPermissions(ctx, "1", "2", perms.Array{"@group:moderator"}))
+	require.Nil(t, db.SetPermissions(ctx, "1", "3", perms.Array{"@group:moderator", "+dm.chat.*"}))
+
+	res, err := p.resolveMemberPerms(ctx, s, "1", "", "40", nil)
+	require.Nil(t, err)
+	assert.True(t, res.Has("dm.guild.config.perms"))
+
+	// Editing the group applies to every role using it.
+	require.Nil(t, db.SetPermissionGroup(ctx, "1", "moderator", perms.Array{"-dm.guild.config.*"}))
+	res, err = p.resolveMemberPerms(ctx, s, "1", "", "40", nil)
+	require.Nil(t, err)
+	assert.False(t, res.Has("dm.guild.config.perms"))
+	assert.True(t, res.Has("dm.chat.vote"))
+}
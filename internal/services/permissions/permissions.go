@@ -1,6 +1,7 @@
 package permissions
 
 import (
+	"context"
 	"strings"
 
 	"github.com/bwmarrin/discordgo"
@@ -17,21 +18,35 @@ import (
 )
 
 type Permissions struct {
-	db  database.Database
-	cfg models.Config
-	s   *discordgo.Session
+	db       database.Database
+	cfg      models.Config
+	s        *discordgo.Session
+	registry *Registry
+	cache    *MemberCache
 }
 
+// maxScopeDepth is the maximum number of nested scopes,
+// which is a thread in a channel of a category.
+const maxScopeDepth = 3
+
 var _ PermsProvider = (*Permissions)(nil)
 
 func InitPermissions(ctn di.Container) *Permissions {
 	return &Permissions{
-		db:  ctn.Get(static.DiDatabase).(database.Database),
-		cfg: ctn.Get(static.DiConfig).(models.Config),
-		s:   ctn.Get(static.DiDiscord).(*discordgo.Session),
+		db:       ctn.Get(static.DiDatabase).(database.Database),
+		cfg:      ctn.Get(static.DiConfig).(models.Config),
+		s:        ctn.Get(static.DiDiscord).(*discordgo.Session),
+		registry: NewRegistry(),
+		cache:    ctn.Get(static.DiPermsCache).(*MemberCache),
 	}
 }
 
+// Registry returns the registry of all permissions
+// known from the registered commands.
+func (p *Permissions) Registry() *Registry {
+	return p.registry
+}
+
 func (p *Permissions) Before(ctx *ken.Ctx) (next bool, err error) {
 	cmd, ok := ctx.Command.(CommandPerms)
 	if !ok {
@@ -43,7 +58,9 @@ func (p *Permissions) Before(ctx *ken.Ctx) (next bool, err error) {
 		return
 	}
 
-	ok, _, err = p.HasPerms(ctx.GetSession(), ctx.GetEvent().GuildID, ctx.User().ID, cmd.Perm())
+	reqCtx := ctx.Get(static.DiContext).(context.Context)
+	ok, _, err = p.HasPerms(reqCtx, ctx.GetSession(), ctx.GetEvent().GuildID, ctx.GetEvent().ChannelID,
+		ctx.User().ID, cmd.Perm())
 
 	if err != nil {
 		return false, err
@@ -57,32 +74,49 @@ func (p *Permissions) Before(ctx *ken.Ctx) (next bool, err error) {
 	return true, err
 }
 
-func (p *Permissions) GetPerms(session *discordgo.Session, guildID, userID string) (perm perms.Array, override bool, err error) {
+func (p *Permissions) GetPerms(ctx context.Context, session *discordgo.Session, guildID, channelID, userID string) (perm perms.Tiers, override bool, err error) {
+	return p.resolvePerms(ctx, session, guildID, channelID, userID, nil)
+}
+
+func (p *Permissions) GetMemberPerms(ctx context.Context, session *discordgo.Session, guildID, channelID, memberID string) (perms.Tiers, error) {
+	return p.resolveMemberPerms(ctx, session, guildID, channelID, memberID, nil)
+}
+
+// resolvePerms merges the role permissions of the user with the
+// default rules, which are merged into the last tier like the
+// rules of the lowest role. When trace is not nil, the source of
+// every rule in the result is recorded in it.
+func (p *Permissions) resolvePerms(ctx context.Context, session *discordgo.Session, guildID, channelID, userID string, trace ruleTrace) (perm perms.Tiers, override bool, err error) {
 
 	if guildID != "" {
-		perm, err = p.GetMemberPerms(session, guildID, userID)
+		perm, err = p.resolveMemberPerms(ctx, session, guildID, channelID, userID, trace)
 		if err != nil && err != dberr.ErrNotFound {
 			return
 		}
-	} else {
-		perm = make(perms.Array, 0)
 	}
 
 	if p.cfg.Discord.OwnerID == userID {
-		perm = perms.Array{"+dm.*"}
+		perm = perms.Tiers{trace.merge(0, nil, perms.Array{"+dm.*"}, Source{Kind: SourceOwner}, false)}
 		override = true
 		return
 	}
 
+	// The tiers may be cached and must not be modified.
+	perm = append(perms.Tiers{}, perm...)
+	if len(perm) == 0 {
+		perm = append(perm, perms.Array{})
+	}
+	last := len(perm) - 1
+
 	if guildID != "" {
 		guild, err := discordutils.GetGuild(session, guildID)
 		if err != nil {
-			return perms.Array{}, false, err
+			return perms.Tiers{}, false, err
 		}
 
 		member, err := discordutils.GetMember(session, guildID, userID)
 		if err != nil {
-			return perms.Array{}, false, err
+			return perms.Tiers{}, false, err
 		}
 
 		if userID == guild.OwnerID || (member != nil && discordutils.IsAdmin(guild, member)) {
@@ -92,7 +126,7 @@ func (p *Permissions) GetPerms(session *discordgo.Session, guildID, userID strin
 				defaultAdminPerms = static.DefaultAdminRules
 			}
 
-			perm = perm.Merge(defaultAdminPerms, false)
+			perm[last] = trace.merge(last, perm[last], defaultAdminPerms, Source{Kind: SourceAdminRules}, false)
 
 			override = true
 
@@ -105,38 +139,165 @@ func (p *Permissions) GetPerms(session *discordgo.Session, guildID, userID strin
 		defaultUserPerms = static.DefaultUserRules
 	}
 
-	perm = perm.Merge(defaultUserPerms, false)
+	perm[last] = trace.merge(last, perm[last], defaultUserPerms, Source{Kind: SourceUserRules}, false)
 
 	return perm, override, nil
 
 }
 
-func (p *Permissions) GetMemberPerms(session *discordgo.Session, guildID string, memberID string) (perms.Array, error) {
-	guildPerms, err := p.db.GetPermissions(guildID)
+// resolveMemberPerms returns the rules of the member from the
+// cache or collects them. Traced resolutions bypass the cache.
+func (p *Permissions) resolveMemberPerms(ctx context.Context, session *discordgo.Session, guildID, channelID, memberID string, trace ruleTrace) (perms.Tiers, error) {
+	if trace != nil {
+		return p.collectMemberPerms(ctx, session, guildID, channelID, memberID, trace)
+	}
+
+	cached, ok, gen := p.cache.get(guildID, channelID, memberID)
+	if ok {
+		return cached, nil
+	}
+
+	res, err := p.collectMemberPerms(ctx, session, guildID, channelID, memberID, nil)
 	if err != nil {
 		return nil, err
 	}
+
+	p.cache.set(gen, guildID, channelID, memberID, res)
+	return res, nil
+}
+
+// collectMemberPerms collects the rules of the member in tiers.
+// When channelID is set, the rules scoped to the channel and its
+// parents are collected first, starting with the most specific
+// scope, followed by the guild wide rules. In each scope, the
+// rules bound to the member take precedence over the ones of
+// their roles.
+func (p *Permissions) collectMemberPerms(ctx context.Context, session *discordgo.Session, guildID, channelID, memberID string, trace ruleTrace) (perms.Tiers, error) {
+	guildPerms, err := p.db.GetPermissions(ctx, guildID)
+	if err != nil {
+		return nil, err
+	}
+	// The rules of higher roles must be merged last.
 	membRoles, err := roleutils.GetSortedMemberRoles(session, guildID, memberID, false, true)
 	if err != nil {
 		return nil, err
 	}
 
-	var res perms.Array
-	for _, r := range membRoles {
-		if p, ok := guildPerms[r.ID]; ok {
-			if res == nil {
-				res = p
-			} else {
-				res = res.Merge(p, true)
+	// Rules bound to the member directly take precedence
+	// over the rules of their roles.
+	userPerms, err := p.db.GetUserPermissions(ctx, guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := p.Groups(ctx, guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	var res perms.Tiers
+
+	if channelID != "" {
+		scopedPerms, err := p.db.GetScopedPermissions(ctx, guildID)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(scopedPerms) != 0 {
+			scopes, err := channelScopes(session, channelID)
+			if err != nil {
+				return nil, err
+			}
+
+			for i := len(scopes) - 1; i >= 0; i-- {
+				if sp, ok := scopedPerms[scopes[i]]; ok {
+					res = mergeTargets(res, membRoles, memberID, sp, sp, groups, scopes[i], trace)
+				}
 			}
 		}
 	}
 
-	return res, nil
+	// The guild wide rules are always the last tiers, so that
+	// the default rules are merged into the ones of the roles.
+	return mergeTargets(res, membRoles, memberID, guildPerms, userPerms, groups, "", trace), nil
+}
+
+// mergeTargets appends a tier with the rules of the member and
+// a tier with the rules of the given roles merged in order to
+// tiers.
+func mergeTargets(
+	tiers perms.Tiers,
+	roles []*discordgo.Role,
+	memberID string,
+	rolePerms, userPerms map[string]perms.Array,
+	groups map[string]perms.Array,
+	scopeID string,
+	trace ruleTrace,
+) perms.Tiers {
+	userTier, roleTier := len(tiers), len(tiers)+1
+	tiers = append(tiers, nil, nil)
+
+	for _, r := range roles {
+		if rp, ok := rolePerms[r.ID]; ok {
+			tiers[roleTier] = mergeRules(roleTier, tiers[roleTier], rp, groups,
+				Source{Kind: SourceRole, RoleID: r.ID, ScopeID: scopeID}, trace)
+		}
+	}
+
+	if up, ok := userPerms[memberID]; ok {
+		tiers[userTier] = mergeRules(userTier, tiers[userTier], up, groups,
+			Source{Kind: SourceUser, UserID: memberID, ScopeID: scopeID}, trace)
+	}
+
+	return tiers
+}
+
+// mergeRules merges the rules of the groups referenced by rules
+// in order and the remaining rules on top of res, so that rules
+// set directly take precedence over the ones of their groups.
+// References to unknown groups are skipped. The sources of the
+// rules are traced as rules of the given tier.
+func mergeRules(tier int, res, rules perms.Array, groups map[string]perms.Array, src Source, trace ruleTrace) perms.Array {
+	direct := make(perms.Array, 0, len(rules))
+	for _, rule := range rules {
+		name, ok := perms.GroupName(rule)
+		if !ok {
+			direct = append(direct, rule)
+			continue
+		}
+		if gp, ok := groups[name]; ok {
+			groupSrc := src
+			groupSrc.Group = name
+			res = trace.merge(tier, res, gp, groupSrc, true)
+		}
+	}
+
+	return trace.merge(tier, res, direct, src, true)
+}
+
+// channelScopes returns the IDs of the channel and its parents,
+// which are the category or, for threads, the parent channel
+// and its category, starting with the least specific one.
+func channelScopes(session *discordgo.Session, channelID string) ([]string, error) {
+	scopes := []string{channelID}
+
+	for id := channelID; len(scopes) < maxScopeDepth; {
+		ch, err := discordutils.GetChannel(session, id)
+		if err != nil {
+			return nil, err
+		}
+		if ch.ParentID == "" {
+			break
+		}
+		id = ch.ParentID
+		scopes = append([]string{id}, scopes...)
+	}
+
+	return scopes, nil
 }
 
-func (p *Permissions) HasPerms(session *discordgo.Session, guildID, userID, dn string) (ok, override bool, err error) {
-	perms, override, err := p.GetPerms(session, guildID, userID)
+func (p *Permissions) HasPerms(ctx context.Context, session *discordgo.Session, guildID, channelID, userID, dn string) (ok, override bool, err error) {
+	perms, override, err := p.GetPerms(ctx, session, guildID, channelID, userID)
 	if err != nil {
 		return false, false, err
 	}
@@ -168,7 +329,9 @@ func (p *Permissions) HasSubCmdPerms(ctx ken.Context, subPM string, explicit boo
 		msg = message[0]
 	}
 
-	permOk, override, err := p.HasPerms(ctx.GetSession(), ctx.GetEvent().GuildID, ctx.User().ID, pm)
+	reqCtx := ctx.Get(static.DiContext).(context.Context)
+	permOk, override, err := p.HasPerms(reqCtx, ctx.GetSession(), ctx.GetEvent().GuildID, ctx.GetEvent().ChannelID,
+		ctx.User().ID, pm)
 	if err != nil {
 		return false, err
 	}
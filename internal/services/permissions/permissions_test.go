@@ -0,0 +1,109 @@
+package permissions
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zekurio/daemon/pkg/perms"
+)
+
+func TestChannelScopes(t *testing.T) {
+	s := &discordgo.Session{State: discordgo.NewState()}
+	require.Nil(t, s.State.GuildAdd(&discordgo.Guild{ID: "1"}))
+	for _, ch := range []*discordgo.Channel{
+		{ID: "10", GuildID: "1", Type: discordgo.ChannelTypeGuildCategory},
+		{ID: "11", GuildID: "1", ParentID: "10"},
+		{ID: "12", GuildID: "1", ParentID: "11", Type: discordgo.ChannelTypeGuildPublicThread},
+		{ID: "13", GuildID: "1"},
+	} {
+		require.Nil(t, s.State.ChannelAdd(ch))
+	}
+
+	scopes, err := channelScopes(s, "12")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"10", "11", "12"}, scopes)
+
+	scopes, err = channelScopes(s, "11")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"10", "11"}, scopes)
+
+	scopes, err = channelScopes(s, "13")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"13"}, scopes)
+}
+
+func TestMergeTargetsScopes(t *testing.T) {
+	var (
+		roles     = []*discordgo.Role{{ID: "2"}, {ID: "3"}}
+		rolePerms = map[string]perms.Array{
+			"2": {"-dm.chat.*"},
+			"3": {"+dm.chat.profile"},
+		}
+		category = map[string]perms.Array{
+			"3":  {"-dm.chat.profile"},
+			"40": {"+dm.chat.autovoice"},
+		}
+		channel = map[string]perms.Array{
+			"2": {"+dm.chat.vote"},
+		}
+		trace = make(ruleTrace)
+	)
+
+	// The scopes are collected from the most specific one to
+	// the guild, like collectMemberPerms does.
+	res := mergeTargets(nil, roles, "40", channel, channel, nil, "11", trace)
+	res = mergeTargets(res, roles, "40", category, category, nil, "10", trace)
+	res = mergeTargets(res, roles, "40", rolePerms, nil, nil, "", trace)
+	require.Len(t, res, 6)
+
+	// A rule of the channel decides over a less specific
+	// rule of the guild.
+	assert.True(t, res.Has("dm.chat.vote"))
+	assert.False(t, res.Has("dm.chat.profile"))
+	assert.True(t, res.Has("dm.chat.autovoice"))
+	assert.False(t, res.Has("dm.chat.other"))
+
+	assert.Equal(t, Source{Kind: SourceRole, RoleID: "2", ScopeID: "11"}, trace[traceKey{1, "+dm.chat.vote"}])
+	assert.Equal(t, Source{Kind: SourceUser, UserID: "40", ScopeID: "10"}, trace[traceKey{2, "+dm.chat.autovoice"}])
+	assert.Equal(t, "<@&2> in <#11>", trace[traceKey{1, "+dm.chat.vote"}].String())
+}
+
+func TestMergeTargetsCrossSource(t *testing.T) {
+	roles := []*discordgo.Role{{ID: "2"}}
+
+	tests := []struct {
+		name      string
+		roleRules perms.Array
+		userRules perms.Array
+		scoped    bool
+		want      bool
+	}{
+		{"overlapping allow of member wins", perms.Array{"-dm.*.vote"}, perms.Array{"+dm.chat.*"}, false, true},
+		{"overlapping deny of member wins", perms.Array{"+dm.*.vote"}, perms.Array{"-dm.chat.*"}, false, false},
+		{"same pattern of member overrides role", perms.Array{"-dm.chat.vote"}, perms.Array{"+dm.chat.vote"}, false, true},
+		{"exact rule of member wins", perms.Array{"-dm.*.vote"}, perms.Array{"+dm.chat.vote"}, false, true},
+		{"less specific rule of member wins", perms.Array{"+dm.chat.vote"}, perms.Array{"-dm.chat.*"}, false, false},
+		{"role decides without matching member rule", perms.Array{"-dm.*.vote"}, perms.Array{"+dm.guild.*"}, false, false},
+		{"scoped role wins over guild wide member rule", perms.Array{"-dm.*.vote"}, perms.Array{"+dm.chat.*"}, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userPerms := map[string]perms.Array{"40": tt.userRules}
+			rolePerms := map[string]perms.Array{"2": tt.roleRules}
+
+			var res perms.Tiers
+			if tt.scoped {
+				res = mergeTargets(res, roles, "40", rolePerms, nil, nil, "10", nil)
+				res = mergeTargets(res, roles, "40", nil, userPerms, nil, "", nil)
+			} else {
+				res = mergeTargets(res, roles, "40", rolePerms, userPerms, nil, "", nil)
+			}
+
+			assert.Equal(t, tt.want, res.Has("dm.chat.vote"))
+		})
+	}
+}
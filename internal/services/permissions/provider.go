@@ -1,6 +1,8 @@
 package permissions
 
 import (
+	"context"
+
 	"github.com/bwmarrin/discordgo"
 	"github.com/zekrotja/ken"
 
@@ -11,13 +13,16 @@ type PermsProvider interface {
 	ken.MiddlewareBefore
 
 	// GetPerms collects the permissions of a user from their roles.
-	GetPerms(session *discordgo.Session, guildID, userID string) (perm perms.Array, override bool, err error)
+	// Rules scoped to channelID and its parents are included, an
+	// empty channelID only collects the guild wide rules. The rules
+	// are returned in tiers by their precedence.
+	GetPerms(ctx context.Context, session *discordgo.Session, guildID, channelID, userID string) (perm perms.Tiers, override bool, err error)
 
 	// GetMemberPerms collects the permissions of a member from their roles.
-	GetMemberPerms(session *discordgo.Session, guildID string, memberID string) (perms.Array, error)
+	GetMemberPerms(ctx context.Context, session *discordgo.Session, guildID, channelID, memberID string) (perms.Tiers, error)
 
-	// HasPerms checks if a user has the given permission.
-	HasPerms(session *discordgo.Session, guildID, userID, perm string) (ok, override bool, err error)
+	// HasPerms checks if a user has the given permission in the channel.
+	HasPerms(ctx context.Context, session *discordgo.Session, guildID, channelID, userID, perm string) (ok, override bool, err error)
 
 	// HasSubCmdPerms checks if a user has the given permission for a subcommand.
 	HasSubCmdPerms(ctx ken.Context, subPM string, explicit bool, message ...string) (ok bool, err error)
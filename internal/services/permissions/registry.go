@@ -0,0 +1,197 @@
+package permissions
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/zekrotja/ken"
+
+	"github.com/zekurio/daemon/pkg/perms"
+)
+
+// RegistryEntry is a permission required by a command
+// or one of its sub commands.
+type RegistryEntry struct {
+	DN          string
+	Description string
+	Explicit    bool
+}
+
+// RegistryNode is a node of the permission tree. Nodes
+// which only group other permissions have no Entry.
+type RegistryNode struct {
+	DN    string
+	Depth int
+	Entry *RegistryEntry
+}
+
+// Registry holds all permissions known from the
+// registered commands.
+type Registry struct {
+	mtx     sync.RWMutex
+	entries map[string]RegistryEntry
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		entries: make(map[string]RegistryEntry),
+	}
+}
+
+// Collect adds the permissions of all commands implementing
+// CommandPerms from the given command info list.
+func (r *Registry) Collect(cis ken.CommandInfoList) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	for _, ci := range cis {
+		perm, ok := implementation[string](ci, "Perm")
+		if !ok || perm == "" {
+			continue
+		}
+
+		r.entries[perm] = RegistryEntry{
+			DN:          perm,
+			Description: ci.ApplicationCommand.Description,
+		}
+
+		subPerms, _ := implementation[[]SubCommandPerms](ci, "SubPerms")
+		for _, sp := range subPerms {
+			dn := perm + "." + sp.Perm
+			r.entries[dn] = RegistryEntry{
+				DN:          dn,
+				Description: sp.Description,
+				Explicit:    sp.Explicit,
+			}
+		}
+	}
+}
+
+// Entries returns all registered permissions sorted by DN.
+func (r *Registry) Entries() []RegistryEntry {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	entries := make([]RegistryEntry, 0, len(r.entries))
+	for _, e := range r.entries {
+		entries = append(entries, e)
+	}
+	// Entries are sorted by segments so that permissions
+	// directly follow their parent in the tree.
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := strings.Split(entries[i].DN, "."), strings.Split(entries[j].DN, ".")
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+
+	return entries
+}
+
+// Tree returns the registered permissions together with all
+// of their parent domains in depth-first order.
+func (r *Registry) Tree() []RegistryNode {
+	var (
+		nodes []RegistryNode
+		seen  = make(map[string]struct{})
+	)
+
+	for _, e := range r.Entries() {
+		segments := strings.Split(e.DN, ".")
+		for i := range segments {
+			dn := strings.Join(segments[:i+1], ".")
+			if _, ok := seen[dn]; ok {
+				continue
+			}
+			seen[dn] = struct{}{}
+
+			node := RegistryNode{DN: dn, Depth: i}
+			if dn == e.DN {
+				entry := e
+				node.Entry = &entry
+			}
+			nodes = append(nodes, node)
+		}
+	}
+
+	return nodes
+}
+
+// IsValid returns true if dn is a registered permission or a
+// wildcard pattern which matches at least one registered
+// permission. Patterns starting with a wildcard are rejected
+// as they would match every permission.
+func (r *Registry) IsValid(dn string) bool {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	if _, ok := r.entries[dn]; ok {
+		return true
+	}
+
+	if !strings.Contains(dn, "*") || strings.HasPrefix(dn, "*") || !perms.ValidPattern(dn) {
+		return false
+	}
+
+	for known := range r.entries {
+		if perms.Matches(dn, known) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Search returns up to limit registered permissions and
+// wildcards containing query, preferring those which start
+// with it.
+func (r *Registry) Search(query string, limit int) []RegistryEntry {
+	query = strings.ToLower(strings.TrimSpace(query))
+
+	var (
+		nodes               = r.Tree()
+		prefixed, contained []RegistryEntry
+	)
+
+	for i, n := range nodes {
+		var candidates []RegistryEntry
+		if n.Entry != nil {
+			candidates = append(candidates, *n.Entry)
+		}
+		if i+1 < len(nodes) && nodes[i+1].Depth > n.Depth {
+			candidates = append(candidates, RegistryEntry{
+				DN:          n.DN + ".*",
+				Description: "All permissions of " + n.DN,
+			})
+		}
+
+		for _, c := range candidates {
+			switch {
+			case strings.HasPrefix(c.DN, query):
+				prefixed = append(prefixed, c)
+			case strings.Contains(c.DN, query):
+				contained = append(contained, c)
+			}
+		}
+	}
+
+	results := append(prefixed, contained...)
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results
+}
+
+func implementation[T any](ci *ken.CommandInfo, method string) (v T, ok bool) {
+	vals := ci.Implementations[method]
+	if len(vals) == 0 {
+		return
+	}
+	v, ok = vals[0].(T)
+	return
+}
@@ -0,0 +1,109 @@
+package permissions
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/zekrotja/ken"
+)
+
+func newTestRegistry() *Registry {
+	r := NewRegistry()
+	r.Collect(ken.CommandInfoList{
+		{
+			ApplicationCommand: &discordgo.ApplicationCommand{Description: "Create votes."},
+			Implementations: map[string][]interface{}{
+				"Perm": {"dm.chat.vote"},
+				"SubPerms": {[]SubCommandPerms{
+					{Perm: "close", Explicit: true, Description: "Close votes of others."},
+				}},
+			},
+		},
+		{
+			ApplicationCommand: &discordgo.ApplicationCommand{Description: "Show profiles."},
+			Implementations: map[string][]interface{}{
+				"Perm":     {"dm.chat.profile"},
+				"SubPerms": {[]SubCommandPerms(nil)},
+			},
+		},
+		{
+			ApplicationCommand: &discordgo.ApplicationCommand{Description: "Set permissions."},
+			Implementations: map[string][]interface{}{
+				"Perm": {"dm.guild.config.perms"},
+			},
+		},
+		{
+			// commands without CommandPerms are skipped
+			ApplicationCommand: &discordgo.ApplicationCommand{Description: "About."},
+			Implementations:    map[string][]interface{}{},
+		},
+	})
+	return r
+}
+
+func TestRegistryTree(t *testing.T) {
+	r := newTestRegistry()
+
+	var dns []string
+	for _, n := range r.Tree() {
+		dns = append(dns, n.DN)
+	}
+
+	assert.Equal(t, []string{
+		"dm",
+		"dm.chat",
+		"dm.chat.profile",
+		"dm.chat.vote",
+		"dm.chat.vote.close",
+		"dm.guild",
+		"dm.guild.config",
+		"dm.guild.config.perms",
+	}, dns)
+
+	tree := r.Tree()
+	assert.Nil(t, tree[0].Entry)
+	assert.Equal(t, 3, tree[4].Depth)
+	assert.True(t, tree[4].Entry.Explicit)
+	assert.Equal(t, "Create votes.", tree[3].Entry.Description)
+}
+
+func TestRegistryIsValid(t *testing.T) {
+	r := newTestRegistry()
+
+	assert.True(t, r.IsValid("dm.chat.vote"))
+	assert.True(t, r.IsValid("dm.chat.vote.close"))
+	assert.True(t, r.IsValid("dm.chat.*"))
+	assert.True(t, r.IsValid("dm.*"))
+	assert.True(t, r.IsValid("dm.chat.vote.*"))
+	assert.True(t, r.IsValid("dm.*.vote"))
+	assert.True(t, r.IsValid("dm.*.*.close"))
+
+	assert.False(t, r.IsValid("dm.chat.votes"))
+	assert.False(t, r.IsValid("dm.chat.vote.open"))
+	assert.False(t, r.IsValid("dm.etc.*"))
+	assert.False(t, r.IsValid("dm.chat.vo*"))
+	assert.False(t, r.IsValid("*"))
+	assert.False(t, r.IsValid("*.chat.vote"))
+	assert.False(t, r.IsValid("dm.*.votes"))
+	assert.False(t, r.IsValid("dm..vote"))
+	assert.False(t, r.IsValid(""))
+}
+
+func TestRegistrySearch(t *testing.T) {
+	r := newTestRegistry()
+
+	var dns []string
+	for _, e := range r.Search("dm.chat.v", 25) {
+		dns = append(dns, e.DN)
+	}
+	assert.Equal(t, []string{"dm.chat.vote", "dm.chat.vote.*", "dm.chat.vote.close"}, dns)
+
+	dns = nil
+	for _, e := range r.Search("perms", 25) {
+		dns = append(dns, e.DN)
+	}
+	assert.Equal(t, []string{"dm.guild.config.perms"}, dns)
+
+	assert.Len(t, r.Search("", 3), 3)
+}
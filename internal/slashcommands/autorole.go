@@ -1,6 +1,7 @@
 package slashcommands
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -105,8 +106,9 @@ func (c *Autorole) Run(ctx ken.Context) (err error) {
 
 func (c *Autorole) list(ctx ken.SubCommandContext) (err error) {
 	db := ctx.Get(static.DiDatabase).(database.Database)
+	reqCtx := ctx.Get(static.DiContext).(context.Context)
 
-	autoroles, err := db.GetAutoRoles(ctx.GetEvent().GuildID)
+	autoroles, err := db.GetAutoRoles(reqCtx, ctx.GetEvent().GuildID)
 	if err != nil && err != dberr.ErrNotFound {
 		return err
 	}
@@ -129,11 +131,12 @@ func (c *Autorole) list(ctx ken.SubCommandContext) (err error) {
 
 func (c *Autorole) add(ctx ken.SubCommandContext) (err error) {
 	db := ctx.Get(static.DiDatabase).(database.Database)
+	reqCtx := ctx.Get(static.DiContext).(context.Context)
 
 	role := ctx.Options().Get(0).
 		RoleValue(ctx)
 
-	autoroles, err := db.GetAutoRoles(ctx.GetEvent().GuildID)
+	autoroles, err := db.GetAutoRoles(reqCtx, ctx.GetEvent().GuildID)
 	if err != nil && err != dberr.ErrNotFound {
 		return
 	}
@@ -143,7 +146,7 @@ func (c *Autorole) add(ctx ken.SubCommandContext) (err error) {
 		return
 	}
 
-	if err = db.SetAutoRoles(ctx.GetEvent().GuildID, append(autoroles, role.ID)); err != nil {
+	if err = db.SetAutoRoles(reqCtx, ctx.GetEvent().GuildID, append(autoroles, role.ID)); err != nil {
 		return
 	}
 
@@ -157,11 +160,12 @@ func (c *Autorole) add(ctx ken.SubCommandContext) (err error) {
 
 func (c *Autorole) remove(ctx ken.SubCommandContext) (err error) {
 	db := ctx.Get(static.DiDatabase).(database.Database)
+	reqCtx := ctx.Get(static.DiContext).(context.Context)
 
 	role := ctx.Options().Get(0).
 		RoleValue(ctx)
 
-	autoroles, err := db.GetAutoRoles(ctx.GetEvent().GuildID)
+	autoroles, err := db.GetAutoRoles(reqCtx, ctx.GetEvent().GuildID)
 	if err != nil && err != dberr.ErrNotFound {
 		return
 	}
@@ -172,7 +176,7 @@ func (c *Autorole) remove(ctx ken.SubCommandContext) (err error) {
 	}
 
 	autoroles = arrayutils.RemoveLazy(autoroles, role.ID)
-	if err = db.SetAutoRoles(ctx.GetEvent().GuildID, autoroles); err != nil {
+	if err = db.SetAutoRoles(reqCtx, ctx.GetEvent().GuildID, autoroles); err != nil {
 		return
 	}
 
@@ -186,8 +190,9 @@ func (c *Autorole) remove(ctx ken.SubCommandContext) (err error) {
 
 func (c *Autorole) purge(ctx ken.SubCommandContext) (err error) {
 	db := ctx.Get(static.DiDatabase).(database.Database)
+	reqCtx := ctx.Get(static.DiContext).(context.Context)
 
-	if err = db.SetAutoRoles(ctx.GetEvent().GuildID, []string{}); err != nil && err != dberr.ErrNotFound {
+	if err = db.SetAutoRoles(reqCtx, ctx.GetEvent().GuildID, []string{}); err != nil && err != dberr.ErrNotFound {
 		return
 	}
 
@@ -1,6 +1,7 @@
 package slashcommands
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -106,8 +107,9 @@ func (c *Autovoice) Run(ctx ken.Context) (err error) {
 
 func (c *Autovoice) list(ctx ken.SubCommandContext) (err error) {
 	db := ctx.Get(static.DiDatabase).(database.Database)
+	reqCtx := ctx.Get(static.DiContext).(context.Context)
 
-	autovoice, err := db.GetAutoVoice(ctx.GetEvent().GuildID)
+	autovoice, err := db.GetAutoVoice(reqCtx, ctx.GetEvent().GuildID)
 	if err != nil && err != dberr.ErrNotFound {
 		return err
 	}
@@ -131,6 +133,7 @@ func (c *Autovoice) list(ctx ken.SubCommandContext) (err error) {
 
 func (c *Autovoice) add(ctx ken.SubCommandContext) (err error) {
 	db := ctx.Get(static.DiDatabase).(database.Database)
+	reqCtx := ctx.Get(static.DiContext).(context.Context)
 
 	channel := ctx.Options().Get(0).
 		ChannelValue(ctx)
@@ -139,7 +142,7 @@ func (c *Autovoice) add(ctx ken.SubCommandContext) (err error) {
 		return ctx.FollowUpError("The given channel is not a voice channel.", "Argument Error").Send().Error
 	}
 
-	autovoice, err := db.GetAutoVoice(ctx.GetEvent().GuildID)
+	autovoice, err := db.GetAutoVoice(reqCtx, ctx.GetEvent().GuildID)
 	if err != nil && err != dberr.ErrNotFound {
 		return ctx.FollowUpError("An error occurred while fetching autovoice channels.", "Database Error").Send().Error
 	}
@@ -148,7 +151,7 @@ func (c *Autovoice) add(ctx ken.SubCommandContext) (err error) {
 		return ctx.FollowUpError("The given autovoice is already assigned.", "").Send().Error
 	}
 
-	if err = db.SetAutoVoice(ctx.GetEvent().GuildID, append(autovoice, channel.ID)); err != nil {
+	if err = db.SetAutoVoice(reqCtx, ctx.GetEvent().GuildID, append(autovoice, channel.ID)); err != nil {
 		return
 	}
 
@@ -161,11 +164,12 @@ func (c *Autovoice) add(ctx ken.SubCommandContext) (err error) {
 
 func (c *Autovoice) remove(ctx ken.SubCommandContext) (err error) {
 	db := ctx.Get(static.DiDatabase).(database.Database)
+	reqCtx := ctx.Get(static.DiContext).(context.Context)
 
 	channel := ctx.Options().Get(0).
 		ChannelValue(ctx)
 
-	autovoice, err := db.GetAutoVoice(ctx.GetEvent().GuildID)
+	autovoice, err := db.GetAutoVoice(reqCtx, ctx.GetEvent().GuildID)
 	if err != nil && err != dberr.ErrNotFound {
 		return
 	}
@@ -176,7 +180,7 @@ func (c *Autovoice) remove(ctx ken.SubCommandContext) (err error) {
 	}
 
 	autovoice = arrayutils.RemoveLazy(autovoice, channel.ID)
-	if err = db.SetAutoVoice(ctx.GetEvent().GuildID, autovoice); err != nil {
+	if err = db.SetAutoVoice(reqCtx, ctx.GetEvent().GuildID, autovoice); err != nil {
 		return
 	}
 
@@ -189,8 +193,9 @@ func (c *Autovoice) remove(ctx ken.SubCommandContext) (err error) {
 
 func (c *Autovoice) purge(ctx ken.SubCommandContext) (err error) {
 	db := ctx.Get(static.DiDatabase).(database.Database)
+	reqCtx := ctx.Get(static.DiContext).(context.Context)
 
-	if err = db.SetAutoVoice(ctx.GetEvent().GuildID, []string{}); err != nil && err != dberr.ErrNotFound {
+	if err = db.SetAutoVoice(reqCtx, ctx.GetEvent().GuildID, []string{}); err != nil && err != dberr.ErrNotFound {
 		return
 	}
 
@@ -0,0 +1,75 @@
+package slashcommands
+
+import (
+	"context"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/zekrotja/ken"
+
+	"github.com/zekurio/daemon/internal/util/static"
+)
+
+// confirmTimeout is the time the user has to answer
+// a confirmation prompt.
+const confirmTimeout = 2 * time.Minute
+
+// confirm sends emb with a confirm and a cancel button and waits
+// until one of them is clicked, the prompt times out or the
+// request is canceled. The returned message can be edited to
+// show the result. When the prompt is not confirmed, it is
+// replaced with a notice already.
+func confirm(ctx ken.SubCommandContext, emb *discordgo.MessageEmbed, confirmLabel string) (fum *ken.FollowUpMessage, ok bool, err error) {
+	reqCtx := ctx.Get(static.DiContext).(context.Context)
+
+	promptID := snowflakeNode.Generate().String()
+	confirmed := make(chan bool, 1)
+	answer := func(ok bool) ken.ComponentHandlerFunc {
+		return func(cctx ken.ComponentContext) bool {
+			cctx.Respond(&discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseDeferredMessageUpdate,
+			})
+			confirmed <- ok
+			return true
+		}
+	}
+
+	fum = ctx.FollowUpEmbed(emb).AddComponents(func(cb *ken.ComponentBuilder) {
+		cb.AddActionsRow(func(b ken.ComponentAssembler) {
+			b.Add(discordgo.Button{
+				CustomID: "confirm-" + promptID,
+				Label:    confirmLabel,
+				Style:    discordgo.DangerButton,
+			}, answer(true))
+			b.Add(discordgo.Button{
+				CustomID: "cancel-" + promptID,
+				Label:    "Cancel",
+				Style:    discordgo.SecondaryButton,
+			}, answer(false))
+		}, true)
+	}).Send()
+	if fum.HasError() {
+		return nil, false, fum.Error
+	}
+
+	select {
+	case ok = <-confirmed:
+	case <-time.After(confirmTimeout):
+	case <-reqCtx.Done():
+	}
+
+	if ok {
+		return fum, true, nil
+	}
+
+	fum.UnregisterComponentHandlers()
+	return fum, false, fum.Edit(&discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{
+			{
+				Color:       static.ColorGrey,
+				Description: "Canceled, nothing has been changed.",
+			},
+		},
+		Components: &[]discordgo.MessageComponent{},
+	})
+}
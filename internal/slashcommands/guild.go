@@ -1,20 +1,37 @@
 package slashcommands
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
+	"github.com/bwmarrin/snowflake"
+	"github.com/charmbracelet/log"
 	"github.com/zekrotja/ken"
 
+	"github.com/zekurio/daemon/internal/services/database"
+	"github.com/zekurio/daemon/internal/services/database/dberr"
 	"github.com/zekurio/daemon/internal/services/permissions"
+	"github.com/zekurio/daemon/internal/util/guildbackup"
 	"github.com/zekurio/daemon/internal/util/static"
+	"github.com/zekurio/daemon/internal/util/vote"
 	"github.com/zekurio/daemon/pkg/colorutils"
 	"github.com/zekurio/daemon/pkg/discordutils"
+	"github.com/zekurio/daemon/pkg/httputils"
 	"github.com/zekurio/daemon/pkg/quickembed"
 )
 
+// maxBackupSize is the maximum size of a backup file
+// which is accepted by /guild import.
+const maxBackupSize = 1 << 20
+
+var snowflakeNode, _ = snowflake.NewNode(0)
+
 type Guild struct {
 	ken.EphemeralCommand
 }
@@ -29,11 +46,11 @@ func (c *Guild) Name() string {
 }
 
 func (c *Guild) Description() string {
-	return "Displays information about the current guild."
+	return "Displays information about the current guild and manages its configuration."
 }
 
 func (c *Guild) Version() string {
-	return "1.0.0"
+	return "1.1.0"
 }
 
 func (c *Guild) Type() discordgo.ApplicationCommandType {
@@ -41,7 +58,31 @@ func (c *Guild) Type() discordgo.ApplicationCommandType {
 }
 
 func (c *Guild) Options() []*discordgo.ApplicationCommandOption {
-	return []*discordgo.ApplicationCommandOption{}
+	return []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "info",
+			Description: "Displays information about the current guild.",
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "export",
+			Description: "Export the configuration of this guild as JSON file.",
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "import",
+			Description: "Import a configuration exported with /guild export.",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionAttachment,
+					Name:        "file",
+					Description: "The exported JSON file.",
+					Required:    true,
+				},
+			},
+		},
+	}
 }
 
 func (c *Guild) Perm() string {
@@ -49,7 +90,18 @@ func (c *Guild) Perm() string {
 }
 
 func (c *Guild) SubPerms() []permissions.SubCommandPerms {
-	return nil
+	return []permissions.SubCommandPerms{
+		{
+			Perm:        "export",
+			Explicit:    true,
+			Description: "Allows exporting the guild configuration.",
+		},
+		{
+			Perm:        "import",
+			Explicit:    true,
+			Description: "Allows importing a guild configuration, which replaces the current one.",
+		},
+	}
 }
 
 func (c *Guild) Run(ctx ken.Context) (err error) {
@@ -57,6 +109,16 @@ func (c *Guild) Run(ctx ken.Context) (err error) {
 		return
 	}
 
+	err = ctx.HandleSubCommands(
+		ken.SubCommandHandler{Name: "info", Run: c.info},
+		ken.SubCommandHandler{Name: "export", Run: c.export},
+		ken.SubCommandHandler{Name: "import", Run: c.importBackup},
+	)
+
+	return
+}
+
+func (c *Guild) info(ctx ken.SubCommandContext) (err error) {
 	s := ctx.GetSession()
 
 	const maxGuildRoles = 16
@@ -133,3 +195,248 @@ func (c *Guild) Run(ctx ken.Context) (err error) {
 
 	return ctx.FollowUpEmbed(emb).Send().Error
 }
+
+func (c *Guild) export(ctx ken.SubCommandContext) (err error) {
+	db := ctx.Get(static.DiDatabase).(database.Database)
+	reqCtx := ctx.Get(static.DiContext).(context.Context)
+	s := ctx.GetSession()
+	guildID := ctx.GetEvent().GuildID
+
+	if ok, err := c.hasSubPerm(ctx, "export"); !ok || err != nil {
+		return err
+	}
+
+	var data database.GuildData
+
+	data.AutoRoles, err = db.GetAutoRoles(reqCtx, guildID)
+	if err != nil && err != dberr.ErrNotFound {
+		return
+	}
+
+	data.AutoVoice, err = db.GetAutoVoice(reqCtx, guildID)
+	if err != nil && err != dberr.ErrNotFound {
+		return
+	}
+
+	data.Permissions, err = db.GetPermissions(reqCtx, guildID)
+	if err != nil {
+		return
+	}
+
+	data.UserPermissions, err = db.GetUserPermissions(reqCtx, guildID)
+	if err != nil {
+		return
+	}
+
+	data.ScopedPermissions, err = db.GetScopedPermissions(reqCtx, guildID)
+	if err != nil {
+		return
+	}
+
+	data.PermissionGroups, err = db.GetPermissionGroups(reqCtx, guildID)
+	if err != nil {
+		return
+	}
+
+	data.PermissionGrants, err = db.GetPermissionGrants(reqCtx, guildID)
+	if err != nil {
+		return
+	}
+
+	votes, err := db.GetVotes(reqCtx, guildID)
+	if err != nil {
+		return
+	}
+	for _, v := range votes {
+		data.Votes = append(data.Votes, v)
+	}
+	sort.Slice(data.Votes, func(i, j int) bool {
+		return data.Votes[i].ID < data.Votes[j].ID
+	})
+
+	guild, err := discordutils.GetGuild(s, guildID)
+	if err != nil {
+		return
+	}
+
+	channels, err := s.GuildChannels(guildID)
+	if err != nil {
+		return
+	}
+
+	backup := guildbackup.New(guild, channels, data)
+	raw, err := json.MarshalIndent(backup, "", "  ")
+	if err != nil {
+		return
+	}
+
+	return ctx.FollowUp(true, &discordgo.WebhookParams{
+		Embeds: []*discordgo.MessageEmbed{
+			{
+				Color: static.ColorGreen,
+				Description: fmt.Sprintf("Exported %d autoroles, %d autovoice channels, "+
+					"rules of %d roles, %d members, %d channels and %d groups, %d temporary rules and %d votes.",
+					len(backup.AutoRoles), len(backup.AutoVoice), len(backup.Permissions), len(backup.UserPermissions),
+					len(backup.ScopedPermissions), len(backup.PermissionGroups), len(backup.PermissionGrants),
+					len(backup.Votes)),
+			},
+		},
+		Files: []*discordgo.File{
+			{
+				Name:        fmt.Sprintf("daemon-%s-%s.json", guildID, backup.ExportedAt.Format("20060102")),
+				ContentType: "application/json",
+				Reader:      bytes.NewReader(raw),
+			},
+		},
+	}).Send().Error
+}
+
+func (c *Guild) importBackup(ctx ken.SubCommandContext) (err error) {
+	db := ctx.Get(static.DiDatabase).(database.Database)
+	reqCtx := ctx.Get(static.DiContext).(context.Context)
+	s := ctx.GetSession()
+	guildID := ctx.GetEvent().GuildID
+
+	if ok, err := c.hasSubPerm(ctx, "import"); !ok || err != nil {
+		return err
+	}
+
+	attachmentID := ctx.Options().GetByName("file").StringValue()
+	attachment := ctx.GetEvent().ApplicationCommandData().Resolved.Attachments[attachmentID]
+	if attachment == nil {
+		return ctx.FollowUpError("The backup file could not be found.", "").Send().Error
+	}
+	if attachment.Size > maxBackupSize {
+		return ctx.FollowUpError("The backup file is too large.", "").Send().Error
+	}
+
+	res, err := httputils.Get(attachment.URL, nil)
+	if err != nil {
+		return
+	}
+	defer res.Release()
+	if res.StatusCode() != 200 {
+		return fmt.Errorf("failed downloading backup: %s", res.Status())
+	}
+
+	backup, err := guildbackup.Decode(bytes.NewReader(res.Body()))
+	if err != nil {
+		return ctx.FollowUpError(
+			fmt.Sprintf("The backup file is invalid:\n```\n%s\n```", err.Error()), "").
+			Send().Error
+	}
+
+	guild, err := discordutils.GetGuild(s, guildID)
+	if err != nil {
+		return
+	}
+
+	channels, err := s.GuildChannels(guildID)
+	if err != nil {
+		return
+	}
+
+	data, err := backup.Resolve(guild, channels)
+	if uerr, ok := err.(*guildbackup.UnresolvedError); ok {
+		var msg strings.Builder
+		msg.WriteString("The following roles and channels could not be found in this guild. " +
+			"Please create them or make sure their names are unique.\n")
+		for _, name := range uerr.Roles {
+			msg.WriteString(fmt.Sprintf("\n- Role `%s`", name))
+		}
+		for _, name := range uerr.Channels {
+			msg.WriteString(fmt.Sprintf("\n- Channel `%s`", name))
+		}
+		return ctx.FollowUpError(msg.String(), "Import failed").Send().Error
+	}
+	if err != nil {
+		return
+	}
+
+	// The vote messages belong to the exported guild or are
+	// gone, so every imported running vote is posted again,
+	// pending votes are posted when they start. Votes which
+	// are stored for this guild already, i.e. when a backup
+	// is imported again, are skipped. When the vote ID is
+	// taken by another guild, a new one is assigned, which
+	// drops the ticks as they are salted with the ID.
+	var (
+		votes  = make([]vote.Vote, 0, len(data.Votes))
+		posted []*discordgo.Message
+	)
+
+	deletePosted := func() {
+		for _, msg := range posted {
+			if err := s.ChannelMessageDelete(msg.ChannelID, msg.ID); err != nil {
+				log.With(err).Error("Failed deleting imported vote message", "ChannelID", msg.ChannelID)
+			}
+		}
+	}
+
+	for _, v := range data.Votes {
+		stored, err := db.GetVote(reqCtx, v.ID)
+		switch {
+		case err == nil && stored.GuildID == guildID:
+			continue
+		case err == nil:
+			v.ID = snowflakeNode.Generate().String()
+			v.Ticks = make(map[string]*vote.Tick)
+		case err != dberr.ErrNotFound:
+			deletePosted()
+			return err
+		}
+
+		if !v.Pending() {
+			msg, err := v.Post(s)
+			if err != nil {
+				deletePosted()
+				return err
+			}
+			posted = append(posted, msg)
+		}
+
+		votes = append(votes, v)
+	}
+	data.Votes = votes
+
+	if err = db.ImportGuildData(reqCtx, guildID, data); err != nil {
+		deletePosted()
+		return
+	}
+
+	for _, v := range votes {
+		if !v.Pending() {
+			vote.SetRunning(v)
+		}
+	}
+
+	return ctx.FollowUpEmbed(&discordgo.MessageEmbed{
+		Color: static.ColorGreen,
+		Description: fmt.Sprintf("Imported %d autoroles, %d autovoice channels, "+
+			"rules of %d roles, %d members, %d channels and %d groups, %d temporary rules and %d votes from **%s**.",
+			len(data.AutoRoles), len(data.AutoVoice), len(data.Permissions), len(data.UserPermissions),
+			len(data.ScopedPermissions), len(data.PermissionGroups), len(data.PermissionGrants), len(votes),
+			backup.GuildName),
+	}).Send().Error
+}
+
+// hasSubPerm checks the explicit sub command permission and
+// responds with an error when the user is not permitted.
+func (c *Guild) hasSubPerm(ctx ken.SubCommandContext, subPerm string) (bool, error) {
+	p := ctx.Get(static.DiPermissions).(*permissions.Permissions)
+	reqCtx := ctx.Get(static.DiContext).(context.Context)
+
+	ok, override, err := p.HasPerms(reqCtx, ctx.GetSession(), ctx.GetEvent().GuildID, ctx.GetEvent().ChannelID,
+		ctx.User().ID, "!"+c.Perm()+"."+subPerm)
+	if err != nil {
+		return false, err
+	}
+
+	if !ok && !override {
+		return false, ctx.FollowUpError(
+			"You are not permitted to use this command!", "Missing Permission").
+			Send().Error
+	}
+
+	return true, nil
+}
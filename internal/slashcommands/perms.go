@@ -1,8 +1,13 @@
 package slashcommands
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/zekrotja/ken"
@@ -10,9 +15,14 @@ import (
 	"github.com/zekurio/daemon/internal/services/database"
 	"github.com/zekurio/daemon/internal/services/database/dberr"
 	"github.com/zekurio/daemon/internal/services/permissions"
+	"github.com/zekurio/daemon/internal/util/permsfile"
 	"github.com/zekurio/daemon/internal/util/static"
+	"github.com/zekurio/daemon/pkg/arrayutils"
+	"github.com/zekurio/daemon/pkg/discordutils"
+	"github.com/zekurio/daemon/pkg/httputils"
 	"github.com/zekurio/daemon/pkg/perms"
 	"github.com/zekurio/daemon/pkg/roleutils"
+	"github.com/zekurio/daemon/pkg/timeutils"
 )
 
 type Perms struct {
@@ -22,8 +32,31 @@ type Perms struct {
 const (
 	permAllow = "+"
 	permDeny  = "-"
+
+	maxEmbedDescription = 4096
+
+	// maxPermsFileSize is the maximum size of a rules
+	// file which is accepted by /perms import.
+	maxPermsFileSize = 1 << 16
+
+	// historyPageSize is the number of changes shown on
+	// one page of /perms history.
+	historyPageSize = 5
+	// historyTimeout is the time after which the page
+	// buttons of /perms history are removed.
+	historyTimeout = 10 * time.Minute
 )
 
+// scopeChannelTypes are the channels rules can be limited to.
+var scopeChannelTypes = []discordgo.ChannelType{
+	discordgo.ChannelTypeGuildText,
+	discordgo.ChannelTypeGuildNews,
+	discordgo.ChannelTypeGuildVoice,
+	discordgo.ChannelTypeGuildStageVoice,
+	discordgo.ChannelTypeGuildForum,
+	discordgo.ChannelTypeGuildCategory,
+}
+
 var (
 	_ ken.SlashCommand         = (*Perms)(nil)
 	_ permissions.CommandPerms = (*Perms)(nil)
@@ -38,7 +71,7 @@ func (c *Perms) Description() string {
 }
 
 func (c *Perms) Version() string {
-	return "1.0.0"
+	return "1.7.0"
 }
 
 func (c *Perms) Type() discordgo.ApplicationCommandType {
@@ -55,7 +88,7 @@ func (c *Perms) Options() []*discordgo.ApplicationCommandOption {
 		{
 			Type:        discordgo.ApplicationCommandOptionSubCommand,
 			Name:        "set",
-			Description: "Set a permission rule for specific roles.",
+			Description: "Set a permission rule for a role or a single member.",
 			Options: []*discordgo.ApplicationCommandOption{
 				{
 					Type:        discordgo.ApplicationCommandOptionString,
@@ -73,16 +106,204 @@ func (c *Perms) Options() []*discordgo.ApplicationCommandOption {
 						},
 					},
 				},
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "perm",
+					Description:  "Permission Domain Name Specifier or group reference (@group:name)",
+					Required:     true,
+					Autocomplete: true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionRole,
+					Name:        "role",
+					Description: "The role to apply the permission to.",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionUser,
+					Name:        "user",
+					Description: "The member to apply the permission to.",
+				},
+				{
+					Type:         discordgo.ApplicationCommandOptionChannel,
+					Name:         "channel",
+					Description:  "Limit the rule to a channel or category.",
+					ChannelTypes: scopeChannelTypes,
+				},
 				{
 					Type:        discordgo.ApplicationCommandOptionString,
-					Name:        "perm",
-					Description: "Permission Domain Name Specifier",
+					Name:        "duration",
+					Description: "Remove the rule again after this duration (i.e. `1h`, `30m`, ...)",
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "tree",
+			Description: "List all available permissions.",
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "explain",
+			Description: "Explain why a permission is granted or denied for a member.",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionUser,
+					Name:        "user",
+					Description: "The member to check.",
 					Required:    true,
 				},
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "perm",
+					Description:  "Permission Domain Name Specifier",
+					Required:     true,
+					Autocomplete: true,
+				},
+				{
+					Type:         discordgo.ApplicationCommandOptionChannel,
+					Name:         "channel",
+					Description:  "The channel to check in, defaults to the current one.",
+					ChannelTypes: scopeChannelTypes,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "group-set",
+			Description: "Set a rule of a permission group, creating the group if needed.",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "name",
+					Description: "The name of the group (i.e. `moderator`).",
+					Required:    true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "mode",
+					Description: "Set the permission as allow or deny.",
+					Required:    true,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{
+							Name:  "allow",
+							Value: permAllow,
+						},
+						{
+							Name:  "deny",
+							Value: permDeny,
+						},
+					},
+				},
+				{
+					Type:         discordgo.ApplicationCommandOptionString,
+					Name:         "perm",
+					Description:  "Permission Domain Name Specifier",
+					Required:     true,
+					Autocomplete: true,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "group-delete",
+			Description: "Delete a permission group of this guild.",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "name",
+					Description: "The name of the group.",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "copy",
+			Description: "Replace the rules of a role with the ones of another role.",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionRole,
+					Name:        "from",
+					Description: "The role to copy the rules from.",
+					Required:    true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionRole,
+					Name:        "to",
+					Description: "The role to copy the rules to.",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "clear",
+			Description: "Remove all rules of a role, including the ones limited to channels.",
+			Options: []*discordgo.ApplicationCommandOption{
 				{
 					Type:        discordgo.ApplicationCommandOptionRole,
 					Name:        "role",
-					Description: "The role to apply the permission to.",
+					Description: "The role to remove the rules from.",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "export",
+			Description: "Export the guild wide rules of roles and members as file.",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "format",
+					Description: "The file format, defaults to TOML.",
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{
+							Name:  "toml",
+							Value: permsfile.FormatTOML,
+						},
+						{
+							Name:  "json",
+							Value: permsfile.FormatJSON,
+						},
+					},
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "import",
+			Description: "Replace the guild wide rules of roles and members with the ones of a file.",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionAttachment,
+					Name:        "file",
+					Description: "A TOML or JSON file as created by /perms export.",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "history",
+			Description: "List the recent changes of the rules.",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionRole,
+					Name:        "role",
+					Description: "Only list the changes of this role.",
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "revert",
+			Description: "Restore the rules a target had before a change listed by /perms history.",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "change",
+					Description: "The ID of the change.",
 					Required:    true,
 				},
 			},
@@ -106,6 +327,16 @@ func (c *Perms) Run(ctx ken.Context) (err error) {
 	err = ctx.HandleSubCommands(
 		ken.SubCommandHandler{Name: "list", Run: c.list},
 		ken.SubCommandHandler{Name: "set", Run: c.set},
+		ken.SubCommandHandler{Name: "tree", Run: c.tree},
+		ken.SubCommandHandler{Name: "explain", Run: c.explain},
+		ken.SubCommandHandler{Name: "group-set", Run: c.groupSet},
+		ken.SubCommandHandler{Name: "group-delete", Run: c.groupDelete},
+		ken.SubCommandHandler{Name: "copy", Run: c.copyRules},
+		ken.SubCommandHandler{Name: "clear", Run: c.clearRules},
+		ken.SubCommandHandler{Name: "export", Run: c.exportRules},
+		ken.SubCommandHandler{Name: "import", Run: c.importRules},
+		ken.SubCommandHandler{Name: "history", Run: c.history},
+		ken.SubCommandHandler{Name: "revert", Run: c.revert},
 	)
 
 	return
@@ -113,19 +344,51 @@ func (c *Perms) Run(ctx ken.Context) (err error) {
 
 func (c *Perms) list(ctx ken.SubCommandContext) (err error) {
 	db := ctx.Get(static.DiDatabase).(database.Database)
+	p := ctx.Get(static.DiPermissions).(*permissions.Permissions)
+	reqCtx := ctx.Get(static.DiContext).(context.Context)
 	s := ctx.Get(static.DiDiscord).(*discordgo.Session)
 
-	gPerms, err := db.GetPermissions(ctx.GetEvent().GuildID)
+	gPerms, err := db.GetPermissions(reqCtx, ctx.GetEvent().GuildID)
 	if err != nil && err != dberr.ErrNotFound {
 		return
 	}
 
-	sortedGuildRoles, err := roleutils.GetSortedGuildRoles(s, ctx.GetEvent().GuildID, true)
+	uPerms, err := db.GetUserPermissions(reqCtx, ctx.GetEvent().GuildID)
+	if err != nil && err != dberr.ErrNotFound {
+		return
+	}
+
+	sPerms, err := db.GetScopedPermissions(reqCtx, ctx.GetEvent().GuildID)
+	if err != nil && err != dberr.ErrNotFound {
+		return
+	}
+
+	grants, err := db.GetPermissionGrants(reqCtx, ctx.GetEvent().GuildID)
+	if err != nil {
+		return
+	}
+
+	expiries := make(ruleExpiries, len(grants))
+	for _, g := range grants {
+		expiries[[3]string{g.ScopeID, g.TargetID, g.Rule}] = g.Expires
+	}
+
+	groups, err := p.Groups(reqCtx, ctx.GetEvent().GuildID)
+	if err != nil {
+		return
+	}
+
+	guildGroups, err := db.GetPermissionGroups(reqCtx, ctx.GetEvent().GuildID)
+	if err != nil {
+		return
+	}
+
+	sortedGuildRoles, err := roleutils.GetSortedGuildRoles(s, ctx.GetEvent().GuildID, false)
 	if err != nil {
 		return err
 	}
 
-	if len(gPerms) == 0 {
+	if len(gPerms) == 0 && len(uPerms) == 0 && len(sPerms) == 0 && len(groups) == 0 {
 		return ctx.FollowUpEmbed(&discordgo.MessageEmbed{
 			Title:       "Permissions",
 			Description: "No permissions set.",
@@ -134,49 +397,980 @@ func (c *Perms) list(ctx ken.SubCommandContext) (err error) {
 
 	msgstr := ""
 
-	for _, role := range sortedGuildRoles {
-		if pa, ok := gPerms[role.ID]; ok {
-			msgstr += fmt.Sprintf("**<@&%s>**\n%s\n\n", role.ID, strings.Join(pa, "\n"))
+	msgstr += formatTargetPerms(sortedGuildRoles, gPerms, uPerms, "", expiries)
+
+	scopeIDs := make([]string, 0, len(sPerms))
+	for id := range sPerms {
+		scopeIDs = append(scopeIDs, id)
+	}
+	sort.Strings(scopeIDs)
+
+	for _, id := range scopeIDs {
+		msgstr += formatTargetPerms(sortedGuildRoles, sPerms[id], sPerms[id], id, expiries)
+	}
+
+	groupNames := make([]string, 0, len(groups))
+	for name := range groups {
+		groupNames = append(groupNames, name)
+	}
+	sort.Strings(groupNames)
+
+	for _, name := range groupNames {
+		origin := "instance"
+		if _, ok := guildGroups[name]; ok {
+			origin = "guild"
+		}
+		msgstr += fmt.Sprintf("**%s%s** *(%s group)*\n%s\n\n", perms.GroupPrefix, name, origin,
+			strings.Join(groups[name], "\n"))
+	}
+
+	// Cut the list off at the last line fitting into the
+	// description of the embed.
+	var desc strings.Builder
+	for _, line := range strings.SplitAfter(msgstr, "\n") {
+		if desc.Len()+len(line) > maxEmbedDescription-10 {
+			desc.WriteString("\n...")
+			break
 		}
+		desc.WriteString(line)
 	}
 
 	return ctx.FollowUpEmbed(&discordgo.MessageEmbed{
 		Title:       "Permissions",
-		Description: msgstr,
+		Description: desc.String(),
 	}).Send().Error
 
 }
 
+// formatTargetPerms lists the rules of the roles in order and
+// afterwards the rules of users. IDs of roles are skipped in
+// userPerms, so both may be the same map of a scope.
+func formatTargetPerms(
+	roles []*discordgo.Role,
+	rolePerms, userPerms map[string]perms.Array,
+	scopeID string,
+	expiries ruleExpiries,
+) string {
+	var (
+		res     string
+		scope   string
+		roleIDs = make(map[string]struct{}, len(roles))
+	)
+
+	if scopeID != "" {
+		scope = fmt.Sprintf(" in <#%s>", scopeID)
+	}
+
+	for _, role := range roles {
+		roleIDs[role.ID] = struct{}{}
+		if pa, ok := rolePerms[role.ID]; ok {
+			res += fmt.Sprintf("**<@&%s>**%s\n%s\n\n", role.ID, scope, expiries.format(scopeID, role.ID, pa))
+		}
+	}
+
+	userIDs := make([]string, 0, len(userPerms))
+	for id := range userPerms {
+		if _, ok := roleIDs[id]; !ok {
+			userIDs = append(userIDs, id)
+		}
+	}
+	sort.Strings(userIDs)
+
+	for _, id := range userIDs {
+		res += fmt.Sprintf("**<@%s>**%s\n%s\n\n", id, scope, expiries.format(scopeID, id, userPerms[id]))
+	}
+
+	return res
+}
+
+// ruleExpiries maps the scope, target and rule of the
+// pending grants of a guild to their expiry.
+type ruleExpiries map[[3]string]time.Time
+
+// format lists the rules and marks those which expire.
+func (e ruleExpiries) format(scopeID, targetID string, rules perms.Array) string {
+	lines := make([]string, len(rules))
+	for i, rule := range rules {
+		lines[i] = rule
+		if expires, ok := e[[3]string{scopeID, targetID, rule}]; ok {
+			lines[i] += fmt.Sprintf(" *(expires <t:%d:R>)*", expires.Unix())
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
 func (c *Perms) set(ctx ken.SubCommandContext) (err error) {
 	db := ctx.Get(static.DiDatabase).(database.Database)
+	p := ctx.Get(static.DiPermissions).(*permissions.Permissions)
+	reqCtx := ctx.Get(static.DiContext).(context.Context)
+	guildID := ctx.GetEvent().GuildID
 
 	mode := ctx.Options().GetByName("mode").StringValue()
 	nPerm := ctx.Options().GetByName("perm").StringValue()
-	role := ctx.Options().GetByName("role").RoleValue(ctx)
+	roleOpt, isRole := ctx.Options().GetByNameOptional("role")
+	userOpt, isUser := ctx.Options().GetByNameOptional("user")
+	channelOpt, isScoped := ctx.Options().GetByNameOptional("channel")
+
+	if isRole == isUser {
+		return ctx.FollowUpError("Please specify either a role or a user.", "").
+			Send().Error
+	}
+
+	groupName, isGroup := perms.GroupName(nPerm)
+	if isGroup {
+		groups, err := p.Groups(reqCtx, guildID)
+		if err != nil {
+			return err
+		}
+		if _, ok := groups[groupName]; !ok {
+			return ctx.FollowUpError(
+				fmt.Sprintf("There is no permission group `%s`. Use `/perms list` to list all groups.", groupName), "").
+				Send().Error
+		}
+	} else if !p.Registry().IsValid(nPerm) {
+		return ctx.FollowUpError(
+			fmt.Sprintf("`%s` is not a known permission. Use `/perms tree` to list all permissions.", nPerm), "").
+			Send().Error
+	}
+
+	var expires time.Time
+	if durationV, ok := ctx.Options().GetByNameOptional("duration"); ok {
+		duration, err := timeutils.ParseDuration(durationV.StringValue())
+		if err != nil || duration <= 0 {
+			return ctx.FollowUpError(
+				"Invalid duration format. Please take a look "+
+					"[here](https://golang.org/pkg/time/#ParseDuration) how to format duration parameter.", "").
+				Send().Error
+		}
+		expires = time.Now().Add(duration)
+	}
+
+	// Groups are referenced without mode, denying a group
+	// removes the reference.
+	if !isGroup {
+		nPerm = mode + nPerm
+	}
+
+	grant := database.PermissionGrant{
+		GuildID:    guildID,
+		TargetType: database.GrantTargetRole,
+		Rule:       nPerm,
+		ChannelID:  ctx.GetEvent().ChannelID,
+		Expires:    expires,
+	}
+
+	var (
+		targetID, target string
+		current          map[string]perms.Array
+	)
+
+	if isRole {
+		role := roleOpt.RoleValue(ctx)
+		targetID, target = role.ID, "role `"+role.Name+"`"
+	} else {
+		user := userOpt.UserValue(ctx)
+		targetID, target = user.ID, "user "+user.Mention()
+		grant.TargetType = database.GrantTargetUser
+	}
 
-	nPerm = mode + nPerm
+	// Scoped rules of roles and users share one map per
+	// scope as their IDs never collide.
+	switch {
+	case isScoped:
+		ch := channelOpt.ChannelValue(ctx)
+		target += " in " + ch.Mention()
+		grant.ScopeID = ch.ID
 
-	gPerms, err := db.GetPermissions(ctx.GetEvent().GuildID)
+		var scoped database.ScopedPermissions
+		scoped, err = db.GetScopedPermissions(reqCtx, guildID)
+		current = scoped[ch.ID]
+	case isRole:
+		current, err = db.GetPermissions(reqCtx, guildID)
+	default:
+		current, err = db.GetUserPermissions(reqCtx, guildID)
+	}
 	if err != nil {
 		return err
 	}
 
-	cPerm, ok := gPerms[role.ID]
-	if !ok {
-		cPerm = make(perms.Array, 0)
-	}
+	// Removing a group reference modifies the rules in
+	// place, so the previous ones are copied for the history.
+	previous := append(perms.Array{}, current[targetID]...)
+	cPerm := append(perms.Array{}, previous...)
 
-	cPerm, changed := cPerm.Update(nPerm, false)
+	var changed bool
+	if isGroup && mode == permDeny {
+		cPerm = arrayutils.RemoveLazy(cPerm, nPerm)
+		changed = len(cPerm) != len(previous)
+	} else {
+		cPerm, changed = cPerm.Update(nPerm, false)
+	}
 	if changed {
-		err := db.SetPermissions(ctx.GetEvent().GuildID, role.ID, cPerm)
+		err := saveRules(ctx, database.PermissionChange{
+			ScopeID:    grant.ScopeID,
+			TargetID:   targetID,
+			TargetType: grant.TargetType,
+			Old:        previous,
+			New:        cPerm,
+		})
 		if err != nil {
 			return err
 		}
 	}
 
-	return ctx.RespondEmbed(&discordgo.MessageEmbed{
+	// Setting a rule without duration makes it permanent,
+	// which also applies when the rule has been removed.
+	grant.TargetID = targetID
+	msg := fmt.Sprintf("Set permission `%s` for %s", nPerm, target)
+	if isGroup && mode == permDeny {
+		msg = fmt.Sprintf("Removed group `%s` from %s", groupName, target)
+	} else if isGroup {
+		msg = fmt.Sprintf("Assigned group `%s` to %s", groupName, target)
+	}
+	if !expires.IsZero() && arrayutils.Contains(cPerm, nPerm) {
+		err = db.AddPermissionGrant(reqCtx, grant)
+		msg += fmt.Sprintf(", it will be removed <t:%d:R>", expires.Unix())
+	} else {
+		err = db.DeletePermissionGrant(reqCtx, grant)
+	}
+	if err != nil {
+		return err
+	}
+
+	return ctx.FollowUpEmbed(&discordgo.MessageEmbed{
 		Title:       "Permissions set",
-		Description: fmt.Sprintf("Set permission `%s` for role `%s`", nPerm, role.Name),
+		Description: msg,
+	}).Send().Error
+
+}
+
+func (c *Perms) tree(ctx ken.SubCommandContext) (err error) {
+	p := ctx.Get(static.DiPermissions).(*permissions.Permissions)
+
+	var msg strings.Builder
+	for _, n := range p.Registry().Tree() {
+		line := strings.Repeat("\u2003", n.Depth)
+		if n.Entry == nil {
+			line += fmt.Sprintf("`%s.*`", n.DN)
+		} else {
+			line += fmt.Sprintf("`%s`", n.DN)
+			if n.Entry.Explicit {
+				line += " *(explicit)*"
+			}
+			if n.Entry.Description != "" {
+				line += " - " + n.Entry.Description
+			}
+		}
+		if msg.Len()+len(line) > maxEmbedDescription-10 {
+			msg.WriteString("\n...")
+			break
+		}
+		msg.WriteString(line + "\n")
+	}
+
+	return ctx.FollowUpEmbed(&discordgo.MessageEmbed{
+		Title:       "Permission tree",
+		Description: msg.String(),
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "Explicit permissions are not granted by wildcards.",
+		},
+	}).Send().Error
+}
+
+func (c *Perms) explain(ctx ken.SubCommandContext) (err error) {
+	p := ctx.Get(static.DiPermissions).(*permissions.Permissions)
+	reqCtx := ctx.Get(static.DiContext).(context.Context)
+
+	user := ctx.Options().GetByName("user").UserValue(ctx)
+	dn := ctx.Options().GetByName("perm").StringValue()
+
+	channelID := ctx.GetEvent().ChannelID
+	if ch, ok := ctx.Options().GetByNameOptional("channel"); ok {
+		channelID = ch.ChannelValue(ctx).ID
+	}
+
+	e, err := p.Explain(reqCtx, ctx.GetSession(), ctx.GetEvent().GuildID, channelID, user.ID, dn)
+	if err != nil {
+		return
+	}
+
+	verdict, color := "denied", static.ColorRed
+	if e.Allowed {
+		verdict, color = "allowed", static.ColorGreen
+	}
+
+	var msg strings.Builder
+	msg.WriteString(fmt.Sprintf("`%s` is **%s** for %s in <#%s>", e.Perm, verdict, user.Mention(), channelID))
+	if e.Winner >= 0 {
+		w := e.Candidates[e.Winner]
+		msg.WriteString(fmt.Sprintf(" by rule `%s` from %s.", w.Rule, w.Source))
+	} else {
+		msg.WriteString(" because no rule matches.")
+	}
+	if e.Override {
+		msg.WriteString("\nThe member is an owner or administrator and bypasses explicit permissions.")
+	}
+
+	msg.WriteString("\n\n**Rules** (checked from the most specific scope to the guild, member rules " +
+		"before role rules, higher scores are more specific, deny wins on equal scores)\n")
+	if len(e.Candidates) == 0 {
+		msg.WriteString("*no rules*")
+	}
+	for i, cd := range e.Candidates {
+		score := "no match"
+		if cd.Score >= 0 {
+			score = fmt.Sprintf("score %d", cd.Score)
+		}
+		line := fmt.Sprintf("`%s` from %s, %s", cd.Rule, cd.Source, score)
+		if i == e.Winner {
+			line = "**" + line + "**  ← decides"
+		}
+		if msg.Len()+len(line) > maxEmbedDescription-10 {
+			msg.WriteString("\n...")
+			break
+		}
+		msg.WriteString("\n" + line)
+	}
+
+	return ctx.FollowUpEmbed(&discordgo.MessageEmbed{
+		Color:       color,
+		Title:       "Permission explanation",
+		Description: msg.String(),
+	}).Send().Error
+}
+
+func (c *Perms) groupSet(ctx ken.SubCommandContext) (err error) {
+	p := ctx.Get(static.DiPermissions).(*permissions.Permissions)
+	reqCtx := ctx.Get(static.DiContext).(context.Context)
+	guildID := ctx.GetEvent().GuildID
+
+	name := strings.ToLower(ctx.Options().GetByName("name").StringValue())
+	mode := ctx.Options().GetByName("mode").StringValue()
+	nPerm := ctx.Options().GetByName("perm").StringValue()
+
+	if !perms.ValidGroupName(name) {
+		return ctx.FollowUpError(
+			"Group names must consist of up to 32 lowercase letters, digits, `_` or `-`.", "").
+			Send().Error
+	}
+
+	if !p.Registry().IsValid(nPerm) {
+		return ctx.FollowUpError(
+			fmt.Sprintf("`%s` is not a known permission. Use `/perms tree` to list all permissions.", nPerm), "").
+			Send().Error
+	}
+
+	groups, err := p.Groups(reqCtx, guildID)
+	if err != nil {
+		return
+	}
+
+	// Setting a rule of an instance wide group creates a
+	// group of the guild with the same rules replacing it.
+	rules, changed := groups[name].Update(mode+nPerm, false)
+	if changed {
+		err = saveRules(ctx, database.PermissionChange{
+			TargetID:   name,
+			TargetType: database.ChangeTargetGroup,
+			Old:        groups[name],
+			New:        rules,
+		})
+		if err != nil {
+			return
+		}
+	}
+
+	return ctx.FollowUpEmbed(&discordgo.MessageEmbed{
+		Title: "Permissions set",
+		Description: fmt.Sprintf("Set permission `%s` for group `%s`, which applies to every role using `%s%s`.",
+			mode+nPerm, name, perms.GroupPrefix, name),
+	}).Send().Error
+}
+
+func (c *Perms) groupDelete(ctx ken.SubCommandContext) (err error) {
+	db := ctx.Get(static.DiDatabase).(database.Database)
+	p := ctx.Get(static.DiPermissions).(*permissions.Permissions)
+	reqCtx := ctx.Get(static.DiContext).(context.Context)
+	guildID := ctx.GetEvent().GuildID
+
+	name := strings.ToLower(ctx.Options().GetByName("name").StringValue())
+
+	guildGroups, err := db.GetPermissionGroups(reqCtx, guildID)
+	if err != nil {
+		return
+	}
+
+	if _, ok := guildGroups[name]; !ok {
+		msg := fmt.Sprintf("There is no permission group `%s` in this guild.", name)
+		if _, ok := p.InstanceGroup(name); ok {
+			msg = fmt.Sprintf("The group `%s` is defined in the config of the bot and can not be deleted.", name)
+		}
+		return ctx.FollowUpError(msg, "").Send().Error
+	}
+
+	err = saveRules(ctx, database.PermissionChange{
+		TargetID:   name,
+		TargetType: database.ChangeTargetGroup,
+		Old:        guildGroups[name],
+		New:        perms.Array{},
+	})
+	if err != nil {
+		return
+	}
+
+	msg := fmt.Sprintf("Deleted group `%s`, references to it are ignored from now on.", name)
+	if _, ok := p.InstanceGroup(name); ok {
+		msg = fmt.Sprintf("Deleted group `%s`, references to it use the group of the config again.", name)
+	}
+
+	return ctx.FollowUpEmbed(&discordgo.MessageEmbed{
+		Title:       "Permissions set",
+		Description: msg,
+	}).Send().Error
+}
+
+func (c *Perms) copyRules(ctx ken.SubCommandContext) (err error) {
+	db := ctx.Get(static.DiDatabase).(database.Database)
+	reqCtx := ctx.Get(static.DiContext).(context.Context)
+
+	from := ctx.Options().GetByName("from").RoleValue(ctx)
+	to := ctx.Options().GetByName("to").RoleValue(ctx)
+
+	if from.ID == to.ID {
+		return ctx.FollowUpError("Please specify two different roles.", "").
+			Send().Error
+	}
+
+	current, err := db.GetPermissions(reqCtx, ctx.GetEvent().GuildID)
+	if err != nil && err != dberr.ErrNotFound {
+		return
+	}
+
+	return c.confirmChanges(ctx,
+		fmt.Sprintf("Copy the rules of %s to %s?", from.Mention(), to.Mention()),
+		ruleChanges{
+			targetType: database.GrantTargetRole,
+			changes: permsfile.Diff(
+				map[string]perms.Array{to.ID: current[to.ID]},
+				map[string]perms.Array{to.ID: current[from.ID]}),
+		})
+}
+
+func (c *Perms) clearRules(ctx ken.SubCommandContext) (err error) {
+	db := ctx.Get(static.DiDatabase).(database.Database)
+	reqCtx := ctx.Get(static.DiContext).(context.Context)
+	guildID := ctx.GetEvent().GuildID
+
+	role := ctx.Options().GetByName("role").RoleValue(ctx)
+
+	current, err := db.GetPermissions(reqCtx, guildID)
+	if err != nil && err != dberr.ErrNotFound {
+		return
+	}
+
+	scoped, err := db.GetScopedPermissions(reqCtx, guildID)
+	if err != nil && err != dberr.ErrNotFound {
+		return
+	}
+
+	sets := []ruleChanges{
+		{
+			targetType: database.GrantTargetRole,
+			changes:    permsfile.Diff(map[string]perms.Array{role.ID: current[role.ID]}, nil),
+		},
+	}
+
+	scopeIDs := make([]string, 0, len(scoped))
+	for id := range scoped {
+		scopeIDs = append(scopeIDs, id)
+	}
+	sort.Strings(scopeIDs)
+
+	for _, id := range scopeIDs {
+		sets = append(sets, ruleChanges{
+			scopeID:    id,
+			targetType: database.GrantTargetRole,
+			changes:    permsfile.Diff(map[string]perms.Array{role.ID: scoped[id][role.ID]}, nil),
+		})
+	}
+
+	return c.confirmChanges(ctx, fmt.Sprintf("Remove all rules of %s?", role.Mention()), sets...)
+}
+
+func (c *Perms) exportRules(ctx ken.SubCommandContext) (err error) {
+	db := ctx.Get(static.DiDatabase).(database.Database)
+	reqCtx := ctx.Get(static.DiContext).(context.Context)
+	guildID := ctx.GetEvent().GuildID
+
+	format := permsfile.FormatTOML
+	if formatOpt, ok := ctx.Options().GetByNameOptional("format"); ok {
+		format = permsfile.Format(formatOpt.StringValue())
+	}
+
+	rolePerms, err := db.GetPermissions(reqCtx, guildID)
+	if err != nil && err != dberr.ErrNotFound {
+		return
+	}
+
+	userPerms, err := db.GetUserPermissions(reqCtx, guildID)
+	if err != nil && err != dberr.ErrNotFound {
+		return
+	}
+
+	guild, err := discordutils.GetGuild(ctx.GetSession(), guildID)
+	if err != nil {
+		return
+	}
+
+	f := permsfile.New(guild.Roles, rolePerms, userPerms)
+
+	var buf bytes.Buffer
+	if err = f.Encode(&buf, format); err != nil {
+		return
+	}
+
+	return ctx.FollowUp(true, &discordgo.WebhookParams{
+		Embeds: []*discordgo.MessageEmbed{
+			{
+				Color: static.ColorGreen,
+				Description: fmt.Sprintf("Exported the rules of %d roles and %d members. "+
+					"Rules limited to channels are not included.",
+					len(f.Roles), len(f.Users)),
+			},
+		},
+		Files: []*discordgo.File{
+			{
+				Name:        fmt.Sprintf("daemon-%s-perms.%s", guildID, format),
+				ContentType: format.ContentType(),
+				Reader:      &buf,
+			},
+		},
+	}).Send().Error
+}
+
+func (c *Perms) importRules(ctx ken.SubCommandContext) (err error) {
+	db := ctx.Get(static.DiDatabase).(database.Database)
+	p := ctx.Get(static.DiPermissions).(*permissions.Permissions)
+	reqCtx := ctx.Get(static.DiContext).(context.Context)
+	guildID := ctx.GetEvent().GuildID
+
+	attachmentID := ctx.Options().GetByName("file").StringValue()
+	attachment := ctx.GetEvent().ApplicationCommandData().Resolved.Attachments[attachmentID]
+	if attachment == nil {
+		return ctx.FollowUpError("The rules file could not be found.", "").Send().Error
+	}
+	if attachment.Size > maxPermsFileSize {
+		return ctx.FollowUpError("The rules file is too large.", "").Send().Error
+	}
+
+	format, ok := permsfile.FormatOf(attachment.Filename)
+	if !ok {
+		return ctx.FollowUpError("Please upload a `.toml` or `.json` file.", "").Send().Error
+	}
+
+	res, err := httputils.Get(attachment.URL, nil)
+	if err != nil {
+		return
+	}
+	defer res.Release()
+	if res.StatusCode() != 200 {
+		return fmt.Errorf("failed downloading rules file: %s", res.Status())
+	}
+
+	f, err := permsfile.Decode(bytes.NewReader(res.Body()), format)
+	if err != nil {
+		return ctx.FollowUpError(
+			fmt.Sprintf("The rules file is invalid:\n```\n%s\n```", err.Error()), "").
+			Send().Error
+	}
+
+	groups, err := p.Groups(reqCtx, guildID)
+	if err != nil {
+		return
+	}
+
+	isValid := func(dn string) bool {
+		if name, ok := perms.GroupName(dn); ok {
+			_, ok = groups[name]
+			return ok
+		}
+		return p.Registry().IsValid(dn)
+	}
+
+	if invalid := f.InvalidRules(isValid); len(invalid) != 0 {
+		var msg strings.Builder
+		msg.WriteString("The following rules are invalid. Rules must start with `+` or `-` " +
+			"followed by a permission listed by `/perms tree` or reference an existing group.\n")
+		for _, rule := range invalid {
+			msg.WriteString(fmt.Sprintf("\n- `%s`", rule))
+		}
+		return ctx.FollowUpError(msg.String(), "Import failed").Send().Error
+	}
+
+	guild, err := discordutils.GetGuild(ctx.GetSession(), guildID)
+	if err != nil {
+		return
+	}
+
+	rolePerms, userPerms, err := f.Resolve(guild.Roles)
+	if uerr, ok := err.(*permsfile.UnresolvedError); ok {
+		var msg strings.Builder
+		msg.WriteString("The following roles and members could not be found. " +
+			"Please use IDs or make sure the role names are unique.\n")
+		for _, name := range uerr.Roles {
+			msg.WriteString(fmt.Sprintf("\n- Role `%s`", name))
+		}
+		for _, name := range uerr.Users {
+			msg.WriteString(fmt.Sprintf("\n- Member `%s`", name))
+		}
+		return ctx.FollowUpError(msg.String(), "Import failed").Send().Error
+	}
+	if err != nil {
+		return
+	}
+
+	currentRoles, err := db.GetPermissions(reqCtx, guildID)
+	if err != nil && err != dberr.ErrNotFound {
+		return
+	}
+
+	currentUsers, err := db.GetUserPermissions(reqCtx, guildID)
+	if err != nil && err != dberr.ErrNotFound {
+		return
+	}
+
+	return c.confirmChanges(ctx, "Replace the guild wide rules with the ones of the file?",
+		ruleChanges{
+			targetType: database.GrantTargetRole,
+			changes:    permsfile.Diff(currentRoles, rolePerms),
+		},
+		ruleChanges{
+			targetType: database.GrantTargetUser,
+			changes:    permsfile.Diff(currentUsers, userPerms),
+		})
+}
+
+// ruleChanges are changes to the rules of targets of one
+// type in one scope.
+type ruleChanges struct {
+	scopeID    string
+	targetType string
+	changes    []permsfile.Change
+}
+
+// confirmChanges shows a preview of the changes and writes
+// them after the user confirmed them.
+func (c *Perms) confirmChanges(ctx ken.SubCommandContext, question string, sets ...ruleChanges) (err error) {
+	var (
+		preview   strings.Builder
+		nChanges  int
+		truncated bool
+	)
+
+	preview.WriteString(question + "\n")
+	for _, set := range sets {
+		for _, change := range set.changes {
+			nChanges++
+			if truncated {
+				continue
+			}
+
+			block := fmt.Sprintf("\n**%s**\n%s",
+				formatChangeTarget(set.targetType, change.TargetID, set.scopeID), formatRuleDiff(change))
+
+			if preview.Len()+len(block) > maxEmbedDescription-10 {
+				preview.WriteString("\n...")
+				truncated = true
+				continue
+			}
+			preview.WriteString(block)
+		}
+	}
+
+	if nChanges == 0 {
+		return ctx.FollowUpEmbed(&discordgo.MessageEmbed{
+			Title:       "Permissions",
+			Description: "Nothing to change, the rules are already up to date.",
+		}).Send().Error
+	}
+
+	fum, ok, err := confirm(ctx, &discordgo.MessageEmbed{
+		Color:       static.ColorOrange,
+		Title:       "Permission changes",
+		Description: preview.String(),
+	}, "Apply")
+	if !ok || err != nil {
+		return
+	}
+
+	for _, set := range sets {
+		for _, change := range set.changes {
+			err = saveRules(ctx, database.PermissionChange{
+				ScopeID:    set.scopeID,
+				TargetID:   change.TargetID,
+				TargetType: set.targetType,
+				Old:        change.Previous,
+				New:        change.Rules,
+			})
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	return fum.EditEmbed(&discordgo.MessageEmbed{
+		Color:       static.ColorGreen,
+		Title:       "Permissions set",
+		Description: fmt.Sprintf("Updated the rules of %d roles and members.", nChanges),
 	})
+}
+
+// saveRules writes the new rules of the change's target and
+// records the change in the permission history.
+func saveRules(ctx ken.SubCommandContext, change database.PermissionChange) (err error) {
+	db := ctx.Get(static.DiDatabase).(database.Database)
+	reqCtx := ctx.Get(static.DiContext).(context.Context)
+
+	change.GuildID = ctx.GetEvent().GuildID
+
+	switch {
+	case change.TargetType == database.ChangeTargetGroup:
+		err = db.SetPermissionGroup(reqCtx, change.GuildID, change.TargetID, change.New)
+	case change.ScopeID != "":
+		err = db.SetScopedPermissions(reqCtx, change.GuildID, change.ScopeID, change.TargetID, change.New)
+	case change.TargetType == database.GrantTargetUser:
+		err = db.SetUserPermissions(reqCtx, change.GuildID, change.TargetID, change.New)
+	default:
+		err = db.SetPermissions(reqCtx, change.GuildID, change.TargetID, change.New)
+	}
+	if err != nil {
+		return
+	}
+
+	change.ID = snowflakeNode.Generate().String()
+	change.ActorID = ctx.User().ID
+	change.Created = time.Now()
+
+	return db.AddPermissionChange(reqCtx, change)
+}
+
+// formatChangeTarget returns a mention of the role or user
+// or the reference of the group whose rules changed.
+func formatChangeTarget(targetType, targetID, scopeID string) (target string) {
+	switch targetType {
+	case database.ChangeTargetGroup:
+		target = fmt.Sprintf("`%s%s`", perms.GroupPrefix, targetID)
+	case database.GrantTargetUser:
+		target = fmt.Sprintf("<@%s>", targetID)
+	default:
+		target = fmt.Sprintf("<@&%s>", targetID)
+	}
+	if scopeID != "" {
+		target += fmt.Sprintf(" in <#%s>", scopeID)
+	}
+	return target
+}
+
+// formatRuleDiff lists the added and removed rules of the
+// change in a diff code block.
+func formatRuleDiff(change permsfile.Change) string {
+	block := "```diff\n"
+	for _, rule := range change.Added {
+		block += "+ " + rule + "\n"
+	}
+	for _, rule := range change.Removed {
+		block += "- " + rule + "\n"
+	}
+	return block + "```"
+}
+
+func (c *Perms) history(ctx ken.SubCommandContext) (err error) {
+	db := ctx.Get(static.DiDatabase).(database.Database)
+	reqCtx := ctx.Get(static.DiContext).(context.Context)
+	guildID := ctx.GetEvent().GuildID
+
+	var targetID, title string
+	title = "Permission history"
+	if roleOpt, ok := ctx.Options().GetByNameOptional("role"); ok {
+		role := roleOpt.RoleValue(ctx)
+		targetID = role.ID
+		title += " of " + role.Name
+	}
+
+	// One more change than shown is fetched to know
+	// whether there is a next page.
+	page := func(n int) (*discordgo.MessageEmbed, bool, error) {
+		changes, err := db.GetPermissionHistory(reqCtx, guildID, targetID, n*historyPageSize, historyPageSize+1)
+		if err != nil {
+			return nil, false, err
+		}
+		hasNext := len(changes) > historyPageSize
+		if hasNext {
+			changes = changes[:historyPageSize]
+		}
+		return formatHistoryPage(title, n, changes), hasNext, nil
+	}
+
+	emb, hasNext, err := page(0)
+	if err != nil {
+		return
+	}
+
+	if !hasNext {
+		return ctx.FollowUpEmbed(emb).Send().Error
+	}
+
+	pageID := snowflakeNode.Generate().String()
+	current := 0
+
+	buttons := func(hasNext bool) (prev, next discordgo.Button) {
+		prev = discordgo.Button{
+			CustomID: "history-prev-" + pageID,
+			Label:    "Previous",
+			Style:    discordgo.SecondaryButton,
+			Disabled: current == 0,
+		}
+		next = discordgo.Button{
+			CustomID: "history-next-" + pageID,
+			Label:    "Next",
+			Style:    discordgo.SecondaryButton,
+			Disabled: !hasNext,
+		}
+		return
+	}
+
+	// Clicks are handled one after another so that the
+	// current page stays consistent.
+	var mtx sync.Mutex
+	turn := func(delta int) ken.ComponentHandlerFunc {
+		return func(cctx ken.ComponentContext) bool {
+			mtx.Lock()
+			defer mtx.Unlock()
+
+			emb, hasNext, err := page(current + delta)
+			if err != nil {
+				return false
+			}
+			current += delta
+
+			prev, next := buttons(hasNext)
+			cctx.Respond(&discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseUpdateMessage,
+				Data: &discordgo.InteractionResponseData{
+					Embeds: []*discordgo.MessageEmbed{emb},
+					Components: []discordgo.MessageComponent{
+						discordgo.ActionsRow{Components: []discordgo.MessageComponent{prev, next}},
+					},
+				},
+			})
+			return true
+		}
+	}
+
+	prev, next := buttons(true)
+	fum := ctx.FollowUpEmbed(emb).AddComponents(func(cb *ken.ComponentBuilder) {
+		cb.AddActionsRow(func(b ken.ComponentAssembler) {
+			b.Add(prev, turn(-1))
+			b.Add(next, turn(1))
+		})
+	}).Send()
+	if fum.HasError() {
+		return fum.Error
+	}
+
+	go func() {
+		time.Sleep(historyTimeout)
+		fum.UnregisterComponentHandlers()
+	}()
+
+	return
+}
+
+// formatHistoryPage lists the changes on page n, which are
+// ordered from newest to oldest.
+func formatHistoryPage(title string, n int, changes []database.PermissionChange) *discordgo.MessageEmbed {
+	emb := &discordgo.MessageEmbed{
+		Title: title,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("Page %d · Use /perms revert with the ID of a change to undo it.", n+1),
+		},
+	}
+
+	if len(changes) == 0 {
+		emb.Description = "No changes recorded."
+		return emb
+	}
+
+	var desc strings.Builder
+	for _, change := range changes {
+		actor := "a deleted user"
+		if change.ActorID != "" {
+			actor = fmt.Sprintf("<@%s>", change.ActorID)
+		}
+
+		diff := permsfile.Diff(
+			map[string]perms.Array{change.TargetID: change.Old},
+			map[string]perms.Array{change.TargetID: change.New})
+		if len(diff) == 0 {
+			diff = []permsfile.Change{{}}
+		}
+
+		entry := fmt.Sprintf("**%s** by %s <t:%d:R>\nID `%s`\n%s\n",
+			formatChangeTarget(change.TargetType, change.TargetID, change.ScopeID),
+			actor, change.Created.Unix(), change.ID, formatRuleDiff(diff[0]))
+		if desc.Len()+len(entry) > maxEmbedDescription-10 {
+			desc.WriteString("...")
+			break
+		}
+		desc.WriteString(entry)
+	}
+	emb.Description = desc.String()
+
+	return emb
+}
+
+func (c *Perms) revert(ctx ken.SubCommandContext) (err error) {
+	db := ctx.Get(static.DiDatabase).(database.Database)
+	reqCtx := ctx.Get(static.DiContext).(context.Context)
+	guildID := ctx.GetEvent().GuildID
+
+	id := strings.TrimSpace(ctx.Options().GetByName("change").StringValue())
+
+	change, err := db.GetPermissionChange(reqCtx, id)
+	if err == dberr.ErrNotFound || err == nil && change.GuildID != guildID {
+		return ctx.FollowUpError(
+			fmt.Sprintf("There is no change `%s`. Use `/perms history` to list the changes.", id), "").
+			Send().Error
+	}
+	if err != nil {
+		return
+	}
+
+	var current map[string]perms.Array
+	switch {
+	case change.TargetType == database.ChangeTargetGroup:
+		current, err = db.GetPermissionGroups(reqCtx, guildID)
+	case change.ScopeID != "":
+		var scoped database.ScopedPermissions
+		scoped, err = db.GetScopedPermissions(reqCtx, guildID)
+		current = scoped[change.ScopeID]
+	case change.TargetType == database.GrantTargetUser:
+		current, err = db.GetUserPermissions(reqCtx, guildID)
+	default:
+		current, err = db.GetPermissions(reqCtx, guildID)
+	}
+	if err != nil && err != dberr.ErrNotFound {
+		return
+	}
 
+	return c.confirmChanges(ctx,
+		fmt.Sprintf("Restore the rules %s had before the change <t:%d:R>?",
+			formatChangeTarget(change.TargetType, change.TargetID, change.ScopeID), change.Created.Unix()),
+		ruleChanges{
+			scopeID:    change.ScopeID,
+			targetType: change.TargetType,
+			changes: permsfile.Diff(
+				map[string]perms.Array{change.TargetID: current[change.TargetID]},
+				map[string]perms.Array{change.TargetID: change.Old}),
+		})
 }
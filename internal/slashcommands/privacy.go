@@ -0,0 +1,347 @@
+package slashcommands
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/charmbracelet/log"
+	"github.com/zekrotja/ken"
+
+	"github.com/zekurio/daemon/internal/services/database"
+	"github.com/zekurio/daemon/internal/services/database/dberr"
+	"github.com/zekurio/daemon/internal/util/static"
+	"github.com/zekurio/daemon/internal/util/vote"
+	"github.com/zekurio/daemon/pkg/discordutils"
+)
+
+// Privacy does not implement permissions.CommandPerms on
+// purpose, users must always be able to access their data.
+type Privacy struct {
+	ken.EphemeralCommand
+}
+
+var (
+	_ ken.SlashCommand = (*Privacy)(nil)
+	_ ken.DmCapable    = (*Privacy)(nil)
+)
+
+func (c *Privacy) Name() string {
+	return "privacy"
+}
+
+func (c *Privacy) Description() string {
+	return "Export or delete the data the bot stores about you."
+}
+
+func (c *Privacy) Version() string {
+	return "1.0.0"
+}
+
+func (c *Privacy) Type() discordgo.ApplicationCommandType {
+	return discordgo.ChatApplicationCommand
+}
+
+func (c *Privacy) IsDmCapable() bool {
+	return true
+}
+
+func (c *Privacy) Options() []*discordgo.ApplicationCommandOption {
+	return []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "export",
+			Description: "Sends you all data linked to you as JSON file via DM.",
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "delete",
+			Description: "Deletes all data linked to you.",
+		},
+	}
+}
+
+func (c *Privacy) Run(ctx ken.Context) (err error) {
+	if err = ctx.Defer(); err != nil {
+		return
+	}
+
+	err = ctx.HandleSubCommands(
+		ken.SubCommandHandler{Name: "export", Run: c.export},
+		ken.SubCommandHandler{Name: "delete", Run: c.delete},
+	)
+
+	return
+}
+
+type privacyExport struct {
+	UserID            string             `json:"user_id"`
+	ExportedAt        time.Time          `json:"exported_at"`
+	VotesCreated      []privacyVote      `json:"votes_created"`
+	VoteTicks         []privacyTick      `json:"vote_ticks"`
+	Permissions       []privacyPerms     `json:"permissions"`
+	PermissionChanges []privacyChange    `json:"permission_changes"`
+	AutovoiceChannels []privacyAVChannel `json:"autovoice_channels"`
+}
+
+// privacyVote omits the ticks of the vote as they
+// belong to other users.
+type privacyVote struct {
+	ID            string    `json:"id"`
+	GuildID       string    `json:"guild_id"`
+	ChannelID     string    `json:"channel_id"`
+	Description   string    `json:"description"`
+	ImageURL      string    `json:"image_url,omitempty"`
+	Possibilities []string  `json:"possibilities"`
+	Expires       time.Time `json:"expires"`
+}
+
+type privacyTick struct {
+	VoteID      string `json:"vote_id"`
+	GuildID     string `json:"guild_id"`
+	Description string `json:"description"`
+	// Choices are the picked possibilities, in ranked
+	// order for ranked votes.
+	Choices []string `json:"choices"`
+}
+
+type privacyPerms struct {
+	GuildID   string   `json:"guild_id"`
+	ChannelID string   `json:"channel_id,omitempty"`
+	Rules     []string `json:"rules"`
+}
+
+// privacyChange is a change of the rules of a role,
+// user or group made by the user.
+type privacyChange struct {
+	GuildID    string    `json:"guild_id"`
+	ChannelID  string    `json:"channel_id,omitempty"`
+	TargetID   string    `json:"target_id"`
+	TargetType string    `json:"target_type"`
+	Old        []string  `json:"old_rules"`
+	New        []string  `json:"new_rules"`
+	Created    time.Time `json:"created"`
+}
+
+type privacyAVChannel struct {
+	GuildID         string `json:"guild_id"`
+	ChannelID       string `json:"channel_id"`
+	OriginChannelID string `json:"origin_channel_id"`
+}
+
+func (c *Privacy) export(ctx ken.SubCommandContext) (err error) {
+	db := ctx.Get(static.DiDatabase).(database.Database)
+	reqCtx := ctx.Get(static.DiContext).(context.Context)
+	userID := ctx.User().ID
+
+	data, err := db.GetUserData(reqCtx, userID)
+	if err != nil {
+		return
+	}
+
+	export := privacyExport{
+		UserID:            userID,
+		ExportedAt:        time.Now().UTC(),
+		VotesCreated:      make([]privacyVote, 0, len(data.Votes)),
+		VoteTicks:         make([]privacyTick, 0, len(data.Ticks)),
+		Permissions:       make([]privacyPerms, 0, len(data.Permissions)),
+		PermissionChanges: make([]privacyChange, 0, len(data.PermissionChanges)),
+		AutovoiceChannels: make([]privacyAVChannel, 0, len(data.AVChannels)),
+	}
+
+	for _, v := range data.Votes {
+		export.VotesCreated = append(export.VotesCreated, privacyVote{
+			ID:            v.ID,
+			GuildID:       v.GuildID,
+			ChannelID:     v.ChannelID,
+			Description:   v.Description,
+			ImageURL:      v.ImageURL,
+			Possibilities: v.Possibilities,
+			Expires:       v.Expires,
+		})
+	}
+
+	for voteID, picks := range data.Ticks {
+		v, err := db.GetVote(reqCtx, voteID)
+		if err == dberr.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		t := privacyTick{
+			VoteID:      voteID,
+			GuildID:     v.GuildID,
+			Description: v.Description,
+			Choices:     []string{},
+		}
+		for _, c := range picks {
+			if c >= 0 && c < len(v.Possibilities) {
+				t.Choices = append(t.Choices, v.Possibilities[c])
+			}
+		}
+		export.VoteTicks = append(export.VoteTicks, t)
+	}
+
+	for guildID, rules := range data.Permissions {
+		export.Permissions = append(export.Permissions, privacyPerms{
+			GuildID: guildID,
+			Rules:   rules,
+		})
+	}
+	for guildID, scoped := range data.ScopedPermissions {
+		for scopeID, rules := range scoped {
+			export.Permissions = append(export.Permissions, privacyPerms{
+				GuildID:   guildID,
+				ChannelID: scopeID,
+				Rules:     rules[userID],
+			})
+		}
+	}
+	sort.Slice(export.Permissions, func(i, j int) bool {
+		a, b := export.Permissions[i], export.Permissions[j]
+		if a.GuildID != b.GuildID {
+			return a.GuildID < b.GuildID
+		}
+		return a.ChannelID < b.ChannelID
+	})
+
+	for _, change := range data.PermissionChanges {
+		export.PermissionChanges = append(export.PermissionChanges, privacyChange{
+			GuildID:    change.GuildID,
+			ChannelID:  change.ScopeID,
+			TargetID:   change.TargetID,
+			TargetType: change.TargetType,
+			Old:        change.Old,
+			New:        change.New,
+			Created:    change.Created,
+		})
+	}
+
+	for _, av := range data.AVChannels {
+		export.AutovoiceChannels = append(export.AutovoiceChannels, privacyAVChannel{
+			GuildID:         av.GuildID,
+			ChannelID:       av.CreatedChannelID,
+			OriginChannelID: av.OriginChannelID,
+		})
+	}
+
+	raw, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return
+	}
+
+	_, err = discordutils.SendMessageComplexDM(ctx.GetSession(), userID, &discordgo.MessageSend{
+		Content: "Here is all data the bot stores about you.",
+		Files: []*discordgo.File{
+			{
+				Name:        fmt.Sprintf("daemon-privacy-%s.json", export.ExportedAt.Format("20060102")),
+				ContentType: "application/json",
+				Reader:      bytes.NewReader(raw),
+			},
+		},
+	})
+	if err != nil {
+		return ctx.FollowUpError(
+			"The export could not be sent to you. Please make sure you allow direct messages from this bot.", "").
+			Send().Error
+	}
+
+	return ctx.FollowUpEmbed(&discordgo.MessageEmbed{
+		Color:       static.ColorGreen,
+		Description: "Your data has been sent to you via DM.",
+	}).Send().Error
+}
+
+func (c *Privacy) delete(ctx ken.SubCommandContext) (err error) {
+	db := ctx.Get(static.DiDatabase).(database.Database)
+	reqCtx := ctx.Get(static.DiContext).(context.Context)
+	s := ctx.GetSession()
+	userID := ctx.User().ID
+
+	fum, ok, err := confirm(ctx, &discordgo.MessageEmbed{
+		Color: static.ColorOrange,
+		Description: "This removes your ticks from all votes and the permission rules set for you, " +
+			"closes the autovoice channels you own and removes your name from the votes you created " +
+			"and the permission changes you made. " +
+			"This can not be undone.\n\n" +
+			"Do you want to delete your data?",
+	}, "Delete my data")
+	if !ok || err != nil {
+		return
+	}
+
+	data, err := db.GetUserData(reqCtx, userID)
+	if err != nil {
+		return
+	}
+
+	for _, av := range data.AVChannels {
+		if err := av.Delete(s); err != nil {
+			log.With(err).Warn("Failed closing autovoice channel", "ChannelID", av.CreatedChannelID)
+		}
+	}
+
+	if err = db.DeleteUserData(reqCtx, userID); err != nil {
+		return
+	}
+
+	c.anonymizeRunningVotes(s, userID)
+
+	ruleSets := len(data.Permissions)
+	for _, scoped := range data.ScopedPermissions {
+		ruleSets += len(scoped)
+	}
+
+	return fum.EditEmbed(&discordgo.MessageEmbed{
+		Color: static.ColorGreen,
+		Description: fmt.Sprintf("Your data has been deleted. Removed %d vote ticks, %d permission rule sets and "+
+			"%d autovoice channels, anonymized %d votes and %d permission changes.",
+			len(data.Ticks), ruleSets, len(data.AVChannels), len(data.Votes), len(data.PermissionChanges)),
+	})
+}
+
+// anonymizeRunningVotes removes the ticks and the name of the
+// user from the running votes and updates their messages.
+func (c *Privacy) anonymizeRunningVotes(s *discordgo.Session, userID string) {
+	for _, v := range vote.RunningVotes() {
+		if err := c.anonymizeRunningVote(s, v.ID, userID); err != nil {
+			log.With(err).Warn("Failed updating anonymized vote", "ID", v.ID)
+		}
+	}
+}
+
+// anonymizeRunningVote removes the tick and the name of the
+// user from the running vote with the given ID.
+func (c *Privacy) anonymizeRunningVote(s *discordgo.Session, id, userID string) error {
+	unlock := vote.Lock(id)
+	defer unlock()
+
+	v, ok := vote.GetRunning(id)
+	if !ok {
+		return nil
+	}
+
+	hash, err := vote.HashUserID(id, userID)
+	if err != nil {
+		return err
+	}
+
+	_, ticked := v.Ticks[hash]
+	if !ticked && v.CreatorID != userID {
+		return nil
+	}
+
+	delete(v.Ticks, hash)
+	if v.CreatorID == userID {
+		v.CreatorID = ""
+	}
+	vote.SetRunning(v)
+
+	return v.Update(s)
+}
@@ -1,21 +1,37 @@
 package slashcommands
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/zekrotja/ken"
 	"github.com/zekurio/daemon/internal/services/database"
+	"github.com/zekurio/daemon/internal/services/database/dberr"
 	"github.com/zekurio/daemon/internal/services/permissions"
 	"github.com/zekurio/daemon/internal/util/static"
 	"github.com/zekurio/daemon/internal/util/vote"
+	"github.com/zekurio/daemon/pkg/arrayutils"
+	"github.com/zekurio/daemon/pkg/discordutils"
 	"github.com/zekurio/daemon/pkg/timeutils"
 )
 
 type Vote struct{}
 
+// minMaxChoices is the lower bound of the max_choices option.
+var minMaxChoices float64 = 1
+
+// voteChannelTypes are the types of channels scheduled
+// votes can be posted in.
+var voteChannelTypes = []discordgo.ChannelType{
+	discordgo.ChannelTypeGuildText,
+	discordgo.ChannelTypeGuildNews,
+}
+
 var (
 	_ ken.SlashCommand         = (*Vote)(nil)
 	_ permissions.CommandPerms = (*Vote)(nil)
@@ -30,7 +46,7 @@ func (c *Vote) Description() string {
 }
 
 func (c *Vote) Version() string {
-	return "1.0.0"
+	return "1.5.0"
 }
 
 func (c *Vote) Type() discordgo.ApplicationCommandType {
@@ -43,7 +59,7 @@ func (c *Vote) Options() []*discordgo.ApplicationCommandOption {
 			Type:        discordgo.ApplicationCommandOptionSubCommand,
 			Name:        "create",
 			Description: "Create a new vote.",
-			Options: []*discordgo.ApplicationCommandOption{
+			Options: append([]*discordgo.ApplicationCommandOption{
 				{
 					Type:        discordgo.ApplicationCommandOptionString,
 					Name:        "body",
@@ -53,25 +69,46 @@ func (c *Vote) Options() []*discordgo.ApplicationCommandOption {
 				{
 					Type:        discordgo.ApplicationCommandOptionString,
 					Name:        "choices",
-					Description: "The choices - split by `,`.",
+					Description: "The choices - split by `,`, up to 25.",
 					Required:    true,
 				},
+			}, voteOptions()...),
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "schedule",
+			Description: "Schedule a vote to be posted later.",
+			Options: append([]*discordgo.ApplicationCommandOption{
 				{
 					Type:        discordgo.ApplicationCommandOptionString,
-					Name:        "imageurl",
-					Description: "An optional image URL.",
+					Name:        "body",
+					Description: "The vote body content.",
+					Required:    true,
 				},
 				{
 					Type:        discordgo.ApplicationCommandOptionString,
-					Name:        "timeout",
-					Description: "Timeout of the vote (i.e. `1h`, `30m`, ...)",
+					Name:        "choices",
+					Description: "The choices - split by `,`, up to 25.",
+					Required:    true,
 				},
-			},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "start",
+					Description: "When the vote is posted (i.e. `2h` or `2006-01-02 15:04` in UTC).",
+					Required:    true,
+				},
+				{
+					Type:         discordgo.ApplicationCommandOptionChannel,
+					Name:         "channel",
+					Description:  "The channel to post the vote in (default this channel).",
+					ChannelTypes: voteChannelTypes,
+				},
+			}, voteOptions()...),
 		},
 		{
 			Type:        discordgo.ApplicationCommandOptionSubCommand,
 			Name:        "list",
-			Description: "List currently running votes.",
+			Description: "List your running and scheduled votes.",
 		},
 		{
 			Type:        discordgo.ApplicationCommandOptionSubCommand,
@@ -110,6 +147,82 @@ func (c *Vote) Options() []*discordgo.ApplicationCommandOption {
 				},
 			},
 		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "results",
+			Description: "Export the results of a running or closed vote.",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "id",
+					Description: "The ID of the vote.",
+					Required:    true,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "format",
+					Description: "The format of the results.",
+					Required:    true,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "csv", Value: "csv"},
+						{Name: "json", Value: "json"},
+						{Name: "png", Value: "png"},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "chart",
+					Description: "The chart of png results (default `bar`).",
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "bar", Value: string(vote.ChartBar)},
+						{Name: "pie", Value: string(vote.ChartPie)},
+					},
+				},
+			},
+		},
+	}
+}
+
+// voteOptions returns the options shared by the create and
+// schedule subcommands.
+func voteOptions() []*discordgo.ApplicationCommandOption {
+	return []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "imageurl",
+			Description: "An optional image URL.",
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "timeout",
+			Description: "Timeout of the vote after it is posted (i.e. `1h`, `30m`, ...)",
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "mode",
+			Description: "How members vote (default `single`).",
+			Choices: []*discordgo.ApplicationCommandOptionChoice{
+				{Name: "single", Value: string(vote.ModeSingle)},
+				{Name: "multi", Value: string(vote.ModeMulti)},
+				{Name: "ranked", Value: string(vote.ModeRanked)},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionInteger,
+			Name:        "max_choices",
+			Description: "Choices a member can pick in multi or rank in ranked votes (default all, up to 5 ranks).",
+			MinValue:    &minMaxChoices,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "roles",
+			Description: "Only members with one of these roles may vote (i.e. `@Staff @Moderator`).",
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "weights",
+			Description: "Weights of the votes of roles (i.e. `@Council=3 @Staff=2`), others count once.",
+		},
 	}
 }
 
@@ -134,9 +247,11 @@ func (c *Vote) Run(ctx ken.Context) (err error) {
 
 	err = ctx.HandleSubCommands(
 		ken.SubCommandHandler{Name: "create", Run: c.create},
+		ken.SubCommandHandler{Name: "schedule", Run: c.schedule},
 		ken.SubCommandHandler{Name: "list", Run: c.list},
 		ken.SubCommandHandler{Name: "expire", Run: c.expire},
 		ken.SubCommandHandler{Name: "close", Run: c.close},
+		ken.SubCommandHandler{Name: "results", Run: c.results},
 	)
 
 	return
@@ -144,20 +259,136 @@ func (c *Vote) Run(ctx ken.Context) (err error) {
 
 func (c *Vote) create(ctx ken.SubCommandContext) (err error) {
 	db := ctx.Get(static.DiDatabase).(database.Database)
+	reqCtx := ctx.Get(static.DiContext).(context.Context)
+
+	ivote, errMsg, err := c.parseVote(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	if errMsg != "" {
+		return ctx.FollowUpError(errMsg, "").Send().Error
+	}
+
+	emb, err := ivote.AsEmbed(ctx.GetSession())
+	if err != nil {
+		return err
+	}
+
+	fum := ctx.FollowUp(true, &discordgo.WebhookParams{
+		Embeds:     []*discordgo.MessageEmbed{emb},
+		Components: ivote.Components(),
+	}).Send()
+	err = fum.Error
+	if err != nil {
+		return
+	}
+
+	ivote.MsgID = fum.Message.ID
+
+	err = db.AddUpdateVote(reqCtx, ivote)
+	if err != nil {
+		return err
+	}
+
+	vote.SetRunning(ivote)
+	return
+}
+
+func (c *Vote) schedule(ctx ken.SubCommandContext) (err error) {
+	db := ctx.Get(static.DiDatabase).(database.Database)
+	reqCtx := ctx.Get(static.DiContext).(context.Context)
+
+	now := time.Now()
+	starts, err := timeutils.ParseTime(ctx.Options().GetByName("start").StringValue(), now)
+	if err != nil {
+		return ctx.FollowUpError(
+			"Invalid start. Please use a duration like `2h` or a time in UTC like `2006-01-02 15:04`.", "").
+			Send().Error
+	}
+	if !starts.After(now) {
+		return ctx.FollowUpError(
+			"The start of the vote must be in the future.", "").
+			Send().Error
+	}
+
+	ivote, errMsg, err := c.parseVote(ctx, starts)
+	if err != nil {
+		return err
+	}
+	if errMsg != "" {
+		return ctx.FollowUpError(errMsg, "").Send().Error
+	}
+	ivote.Starts = starts
+
+	if channelV, ok := ctx.Options().GetByNameOptional("channel"); ok {
+		ivote.ChannelID = channelV.ChannelValue(ctx).ID
+	}
+
+	p := ctx.Get(static.DiPermissions).(permissions.PermsProvider)
+	errMsg, err = checkVoteChannel(reqCtx, p, ctx.GetSession(), ctx.GetEvent().GuildID,
+		ivote.ChannelID, ctx.User().ID, c.Perm())
+	if err != nil {
+		return err
+	}
+	if errMsg != "" {
+		return ctx.FollowUpError(errMsg, "").Send().Error
+	}
+
+	if err = db.AddUpdateVote(reqCtx, ivote); err != nil {
+		return err
+	}
+
+	return ctx.FollowUpEmbed(&discordgo.MessageEmbed{
+		Color: static.ColorDefault,
+		Description: fmt.Sprintf("Vote `%s` will be posted in <#%s> <t:%d:R>. "+
+			"You can cancel it with `/vote list` until then.", ivote.ID, ivote.ChannelID, starts.Unix()),
+	}).Send().Error
+}
+
+// checkVoteChannel returns a message for the user when they
+// may not schedule a vote into the given channel. The vote
+// is posted by the bot later, so the user must have the
+// permission perm in that channel, not only in the one the
+// command was used in, and must be able to write in it.
+func checkVoteChannel(
+	ctx context.Context,
+	p permissions.PermsProvider,
+	s *discordgo.Session,
+	guildID, channelID, userID, perm string,
+) (errMsg string, err error) {
+	ok, override, err := p.HasPerms(ctx, s, guildID, channelID, userID, perm)
+	if err != nil {
+		return "", err
+	}
+	if !ok && !override {
+		return fmt.Sprintf("You are not permitted to create votes in <#%s>.", channelID), nil
+	}
+
+	channelPerms, err := s.UserChannelPermissions(userID, channelID)
+	if err != nil {
+		return "", err
+	}
+	if channelPerms&discordgo.PermissionSendMessages == 0 {
+		return fmt.Sprintf("You can not send messages in <#%s>.", channelID), nil
+	}
+
+	return "", nil
+}
 
+// parseVote creates a vote of the options shared by the
+// create and schedule subcommands, which expires relative
+// to the given start. When the options are invalid, a
+// message for the user is returned.
+func (c *Vote) parseVote(ctx ken.SubCommandContext, starts time.Time) (ivote vote.Vote, errMsg string, err error) {
 	body := ctx.Options().GetByName("body").StringValue()
 	choices := ctx.Options().GetByName("choices").StringValue()
 	split := strings.Split(choices, ",")
-	if len(split) < 2 || len(split) > 10 {
-		return ctx.FollowUpError(
-			"Invalid arguments. Please use `help vote` go get help about how to use this command.", "").
-			Send().Error
+	if len(split) < 2 || len(split) > vote.MaxPossibilities {
+		return ivote, "Invalid arguments. Please use `help vote` go get help about how to use this command.", nil
 	}
 	for i, e := range split {
 		if len(e) < 1 {
-			return ctx.FollowUpError(
-				"Possibilities can not be empty.", "").
-				Send().Error
+			return ivote, "Possibilities can not be empty.", nil
 		}
 		split[i] = strings.Trim(e, " \t")
 	}
@@ -171,15 +402,48 @@ func (c *Vote) create(ctx ken.SubCommandContext) (err error) {
 	if expiresV, ok := ctx.Options().GetByNameOptional("timeout"); ok {
 		expiresDuration, err := timeutils.ParseDuration(expiresV.StringValue())
 		if err != nil {
-			return ctx.FollowUpError(
-				"Invalid duration format. Please take a look "+
-					"[here](https://golang.org/pkg/time/#ParseDuration) how to format duration parameter.", "").
-				Send().Error
+			return ivote, "Invalid duration format. Please take a look " +
+				"[here](https://golang.org/pkg/time/#ParseDuration) how to format duration parameter.", nil
+		}
+		expires = starts.Add(expiresDuration)
+	}
+
+	mode := vote.ModeSingle
+	if modeV, ok := ctx.Options().GetByNameOptional("mode"); ok {
+		mode = vote.Mode(modeV.StringValue())
+	}
+
+	var maxChoices int
+	if maxChoicesV, ok := ctx.Options().GetByNameOptional("max_choices"); ok {
+		if mode == vote.ModeSingle {
+			return ivote, "`max_choices` can only be used with the `multi` and `ranked` modes.", nil
+		}
+		maxChoices = int(maxChoicesV.IntValue())
+	}
+
+	var roles []string
+	if rolesV, ok := ctx.Options().GetByNameOptional("roles"); ok {
+		if roles, err = vote.ParseRoles(rolesV.StringValue()); err != nil {
+			return ivote, fmt.Sprintf("Invalid roles: %s.", err.Error()), nil
 		}
-		expires = time.Now().Add(expiresDuration)
 	}
 
-	ivote := vote.Vote{
+	var weights map[string]int
+	if weightsV, ok := ctx.Options().GetByNameOptional("weights"); ok {
+		if weights, err = vote.ParseWeights(weightsV.StringValue()); err != nil {
+			return ivote, fmt.Sprintf("Invalid weights: %s.", err.Error()), nil
+		}
+	}
+
+	unknown, err := unknownRoles(ctx.GetSession(), ctx.GetEvent().GuildID, roles, weights)
+	if err != nil {
+		return ivote, "", err
+	}
+	if len(unknown) != 0 {
+		return ivote, fmt.Sprintf("These roles do not exist in this guild: %s", strings.Join(unknown, ", ")), nil
+	}
+
+	ivote = vote.Vote{
 		ID:            ctx.GetEvent().ID,
 		MsgID:         "",
 		CreatorID:     ctx.User().ID,
@@ -187,58 +451,37 @@ func (c *Vote) create(ctx ken.SubCommandContext) (err error) {
 		ChannelID:     ctx.GetEvent().ChannelID,
 		Description:   body,
 		Possibilities: split,
+		Mode:          mode,
+		MaxChoices:    maxChoices,
+		Roles:         roles,
+		Weights:       weights,
 		ImageURL:      imgLink,
 		Expires:       expires,
 		Ticks:         make(map[string]*vote.Tick),
 	}
 
-	emb, err := ivote.AsEmbed(ctx.GetSession())
-	if err != nil {
-		return err
-	}
-
-	fum := ctx.FollowUpEmbed(emb).Send()
-	err = fum.Error
-	if err != nil {
-		return
-	}
-	var msg = fum.Message
+	return ivote, "", nil
+}
 
-	ivote.MsgID = msg.ID
-	err = ivote.AddReactions(ctx.GetSession())
-	if err != nil {
-		return err
-	}
+func (c *Vote) list(ctx ken.SubCommandContext) (err error) {
+	db := ctx.Get(static.DiDatabase).(database.Database)
+	reqCtx := ctx.Get(static.DiContext).(context.Context)
 
-	err = db.AddUpdateVote(ivote)
+	guildVotes, err := db.GetVotes(reqCtx, ctx.GetEvent().GuildID)
 	if err != nil {
 		return err
 	}
 
-	vote.VotesRunning[ivote.ID] = ivote
-	return
-}
-
-func (c *Vote) list(ctx ken.SubCommandContext) (err error) {
-	emb := &discordgo.MessageEmbed{
-		Description: "Your open votes on this guild:",
-		Color:       static.ColorDefault,
-		Fields:      make([]*discordgo.MessageEmbedField, 0),
-	}
-	for _, v := range vote.VotesRunning {
-		if v.GuildID == ctx.GetEvent().GuildID && v.CreatorID == ctx.User().ID {
-			emb.Fields = append(emb.Fields, v.AsField())
-		}
-	}
-	if len(emb.Fields) == 0 {
-		emb.Description = "You don't have any open votes on this guild."
-	}
-	err = ctx.FollowUpEmbed(emb).Send().Error
-	return err
+	votes := vote.UserVotes(guildVotes, ctx.GetEvent().GuildID, ctx.User().ID)
+	return ctx.FollowUp(true, &discordgo.WebhookParams{
+		Embeds:     []*discordgo.MessageEmbed{vote.ListEmbed(votes)},
+		Components: vote.CancelComponents(ctx.User().ID, votes),
+	}).Send().Error
 }
 
 func (c *Vote) expire(ctx ken.SubCommandContext) (err error) {
 	db, _ := ctx.Get(static.DiDatabase).(database.Database)
+	reqCtx := ctx.Get(static.DiContext).(context.Context)
 
 	expireDuration, err := timeutils.ParseDuration(ctx.Options().GetByName("timeout").StringValue())
 	if err != nil {
@@ -249,17 +492,19 @@ func (c *Vote) expire(ctx ken.SubCommandContext) (err error) {
 	}
 
 	id := ctx.Options().Get(0).StringValue()
-	var ivote *vote.Vote
-	for _, v := range vote.VotesRunning {
-		if v.GuildID == ctx.GetEvent().GuildID && v.ID == id {
-			ivote = &v
-		}
+	unlock := vote.Lock(id)
+	defer unlock()
+
+	ivote, ok := runningGuildVote(ctx.GetEvent().GuildID, id)
+	if !ok {
+		return ctx.FollowUpError("There is no running vote with this ID.", "").Send().Error
 	}
 
 	ivote.SetExpire(ctx.GetSession(), expireDuration)
-	if err = db.AddUpdateVote(*ivote); err != nil {
+	if err = db.AddUpdateVote(reqCtx, ivote); err != nil {
 		return err
 	}
+	vote.SetRunning(ivote)
 
 	return ctx.FollowUpEmbed(&discordgo.MessageEmbed{
 		Description: fmt.Sprintf("Vote will expire <t:%d:R>", ivote.Expires.Unix()),
@@ -268,6 +513,7 @@ func (c *Vote) expire(ctx ken.SubCommandContext) (err error) {
 
 func (c *Vote) close(ctx ken.SubCommandContext) (err error) {
 	db := ctx.Get(static.DiDatabase).(database.Database)
+	reqCtx := ctx.Get(static.DiContext).(context.Context)
 
 	state := vote.StateClosed
 
@@ -279,13 +525,15 @@ func (c *Vote) close(ctx ken.SubCommandContext) (err error) {
 
 	if strings.ToLower(id) == "all" {
 		var i int
-		for _, v := range vote.VotesRunning {
+		for _, v := range vote.RunningVotes() {
 			if v.GuildID == ctx.GetEvent().GuildID && v.CreatorID == ctx.User().ID {
-				go func(vC vote.Vote) {
-					db.DeleteVote(vC.ID)
-					vC.Close(ctx.GetSession(), state)
-				}(v)
-				i++
+				closed, err := closeVote(reqCtx, db, ctx.GetSession(), v.ID, state)
+				if err != nil {
+					return err
+				}
+				if closed {
+					i++
+				}
 			}
 		}
 		return ctx.FollowUpEmbed(&discordgo.MessageEmbed{
@@ -293,16 +541,13 @@ func (c *Vote) close(ctx ken.SubCommandContext) (err error) {
 		}).Send().Error
 	}
 
-	var ivote *vote.Vote
-	for _, v := range vote.VotesRunning {
-		if v.GuildID == ctx.GetEvent().GuildID && v.ID == id {
-			ivote = &v
-			break
-		}
+	ivote, found := runningGuildVote(ctx.GetEvent().GuildID, id)
+	if !found {
+		return ctx.FollowUpError("There is no running vote with this ID.", "").Send().Error
 	}
 
 	p := ctx.Get(static.DiPermissions).(*permissions.Permissions)
-	ok, override, err := p.HasPerms(ctx.GetSession(), ctx.GetEvent().GuildID, ctx.User().ID, "!"+ctx.GetCommand().(permissions.CommandPerms).Perm()+".close")
+	ok, override, err := p.HasPerms(reqCtx, ctx.GetSession(), ctx.GetEvent().GuildID, ctx.GetEvent().ChannelID, ctx.User().ID, "!"+ctx.GetCommand().(permissions.CommandPerms).Perm()+".close")
 	if err != nil {
 		return err
 	}
@@ -313,12 +558,7 @@ func (c *Vote) close(ctx ken.SubCommandContext) (err error) {
 			Send().Error
 	}
 
-	err = db.DeleteVote(ivote.ID)
-	if err != nil {
-		return err
-	}
-
-	if err = ivote.Close(ctx.GetSession(), state); err != nil {
+	if _, err = closeVote(reqCtx, db, ctx.GetSession(), ivote.ID, state); err != nil {
 		return
 	}
 
@@ -327,3 +567,144 @@ func (c *Vote) close(ctx ken.SubCommandContext) (err error) {
 	}).Send().Error
 	return
 }
+
+func (c *Vote) results(ctx ken.SubCommandContext) (err error) {
+	db := ctx.Get(static.DiDatabase).(database.Database)
+	reqCtx := ctx.Get(static.DiContext).(context.Context)
+
+	id := ctx.Options().GetByName("id").StringValue()
+	format := ctx.Options().GetByName("format").StringValue()
+
+	unlock := vote.Lock(id)
+	defer unlock()
+
+	v, ok := vote.GetRunning(id)
+	if !ok {
+		v, err = db.GetVote(reqCtx, id)
+		if err != nil && err != dberr.ErrNotFound {
+			return err
+		}
+	}
+	if v.ID == "" || v.GuildID != ctx.GetEvent().GuildID {
+		return ctx.FollowUpError(
+			fmt.Sprintf("There is no vote with the ID `%s` on this guild.", id), "").
+			Send().Error
+	}
+
+	var (
+		buf         bytes.Buffer
+		contentType string
+	)
+	switch format {
+	case "csv":
+		contentType = "text/csv"
+		err = v.WriteCSV(&buf)
+	case "json":
+		contentType = "application/json"
+		err = v.WriteJSON(&buf)
+	default:
+		kind := vote.ChartBar
+		if chartV, ok := ctx.Options().GetByNameOptional("chart"); ok {
+			kind = vote.ChartKind(chartV.StringValue())
+		}
+
+		var img []byte
+		img, err = v.Chart(kind)
+		if err == vote.ErrNoTicks {
+			return ctx.FollowUpError(
+				"Nobody has voted yet, so there is nothing to chart.", "").
+				Send().Error
+		}
+		contentType = "image/png"
+		buf.Write(img)
+	}
+	if err != nil {
+		return err
+	}
+
+	state := "running"
+	if !v.Closed.IsZero() {
+		state = fmt.Sprintf("closed <t:%d:R>", v.Closed.Unix())
+	}
+
+	return ctx.FollowUp(true, &discordgo.WebhookParams{
+		Embeds: []*discordgo.MessageEmbed{
+			{
+				Color: static.ColorDefault,
+				Description: fmt.Sprintf("Results of the vote `%s`, %s, with %d votes.",
+					v.ID, state, len(v.Ticks)),
+			},
+		},
+		Files: []*discordgo.File{
+			{
+				Name:        fmt.Sprintf("vote-%s-results.%s", v.ID, format),
+				ContentType: contentType,
+				Reader:      &buf,
+			},
+		},
+	}).Send().Error
+}
+
+// runningGuildVote returns the running vote with the given
+// ID when it belongs to the guild. Pending, closed and
+// unknown votes are not found.
+func runningGuildVote(guildID, id string) (vote.Vote, bool) {
+	v, ok := vote.GetRunning(id)
+	if !ok || v.GuildID != guildID {
+		return vote.Vote{}, false
+	}
+	return v, true
+}
+
+// closeVote closes the vote and keeps it with its results
+// in the database. The vote is stored as closed even when
+// its message could not be updated. closed is false when
+// the vote is not running anymore.
+func closeVote(ctx context.Context, db database.Database, s *discordgo.Session, id string, state vote.State) (closed bool, err error) {
+	unlock := vote.Lock(id)
+	defer unlock()
+
+	v, ok := vote.GetRunning(id)
+	if !ok {
+		return false, nil
+	}
+
+	closeErr := v.Close(s, state)
+	if err = db.AddUpdateVote(ctx, v); err != nil {
+		return false, err
+	}
+	return true, closeErr
+}
+
+// unknownRoles returns the IDs of the given roles and
+// weighted roles which do not exist in the guild.
+func unknownRoles(s *discordgo.Session, guildID string, roles []string, weights map[string]int) ([]string, error) {
+	if len(roles) == 0 && len(weights) == 0 {
+		return nil, nil
+	}
+
+	guild, err := discordutils.GetGuild(s, guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	exists := make(map[string]bool, len(guild.Roles))
+	for _, r := range guild.Roles {
+		exists[r.ID] = true
+	}
+
+	var unknown []string
+	for _, id := range roles {
+		if !exists[id] {
+			unknown = append(unknown, id)
+		}
+	}
+	for id := range weights {
+		if !exists[id] && !arrayutils.Contains(unknown, id) {
+			unknown = append(unknown, id)
+		}
+	}
+	sort.Strings(unknown)
+
+	return unknown, nil
+}
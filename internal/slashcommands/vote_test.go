@@ -0,0 +1,81 @@
+package slashcommands
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zekurio/daemon/internal/services/permissions"
+	"github.com/zekurio/daemon/internal/util/vote"
+)
+
+// channelPerms allows a permission only in the listed channels.
+type channelPerms struct {
+	permissions.PermsProvider
+
+	allowed map[string]bool
+}
+
+func (p channelPerms) HasPerms(_ context.Context, _ *discordgo.Session, _, channelID, _, _ string) (ok, override bool, err error) {
+	return p.allowed[channelID], false, nil
+}
+
+func TestCheckVoteChannel(t *testing.T) {
+	s := &discordgo.Session{State: discordgo.NewState()}
+	require.Nil(t, s.State.GuildAdd(&discordgo.Guild{
+		ID: "1",
+		Roles: []*discordgo.Role{
+			{ID: "1", Permissions: discordgo.PermissionViewChannel | discordgo.PermissionSendMessages},
+		},
+	}))
+	require.Nil(t, s.State.MemberAdd(&discordgo.Member{
+		GuildID: "1",
+		User:    &discordgo.User{ID: "40"},
+	}))
+	for _, ch := range []*discordgo.Channel{
+		{ID: "10", GuildID: "1"},
+		{ID: "11", GuildID: "1"},
+		{ID: "12", GuildID: "1", PermissionOverwrites: []*discordgo.PermissionOverwrite{
+			{ID: "1", Type: discordgo.PermissionOverwriteTypeRole, Deny: discordgo.PermissionSendMessages},
+		}},
+	} {
+		require.Nil(t, s.State.ChannelAdd(ch))
+	}
+
+	p := channelPerms{allowed: map[string]bool{"10": true, "12": true}}
+
+	errMsg, err := checkVoteChannel(context.Background(), p, s, "1", "10", "40", "dm.chat.vote")
+	require.Nil(t, err)
+	assert.Empty(t, errMsg)
+
+	// The vote permission is only granted in the channel
+	// the command is used in, not in the target channel.
+	errMsg, err = checkVoteChannel(context.Background(), p, s, "1", "11", "40", "dm.chat.vote")
+	require.Nil(t, err)
+	assert.Equal(t, "You are not permitted to create votes in <#11>.", errMsg)
+
+	errMsg, err = checkVoteChannel(context.Background(), p, s, "1", "12", "40", "dm.chat.vote")
+	require.Nil(t, err)
+	assert.Equal(t, "You can not send messages in <#12>.", errMsg)
+}
+
+func TestRunningGuildVote(t *testing.T) {
+	running := vote.Vote{ID: "1100000000000000001", GuildID: "1", MsgID: "m"}
+	vote.SetRunning(running)
+	defer vote.RemoveRunning(running.ID)
+
+	v, ok := runningGuildVote("1", running.ID)
+	require.True(t, ok)
+	assert.Equal(t, running.ID, v.ID)
+
+	// Votes of other guilds and pending or unknown votes,
+	// which are not running, are not found.
+	_, ok = runningGuildVote("2", running.ID)
+	assert.False(t, ok)
+
+	_, ok = runningGuildVote("1", "1100000000000000002")
+	assert.False(t, ok)
+}
@@ -0,0 +1,11 @@
+-- +goose Up
+
+CREATE TABLE IF NOT EXISTS guild_deletions (
+    guild_id VARCHAR(25) NOT NULL,
+    delete_after TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (guild_id)
+);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS guild_deletions;
@@ -0,0 +1,13 @@
+-- +goose Up
+
+CREATE TABLE IF NOT EXISTS scoped_permissions (
+    guild_id VARCHAR(25) NOT NULL,
+    scope_id VARCHAR(25) NOT NULL,
+    target_id VARCHAR(25) NOT NULL,
+    perms TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (guild_id, scope_id, target_id)
+);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS scoped_permissions;
@@ -0,0 +1,18 @@
+-- +goose Up
+
+CREATE TABLE IF NOT EXISTS permission_grants (
+    guild_id VARCHAR(25) NOT NULL,
+    scope_id VARCHAR(25) NOT NULL DEFAULT '',
+    target_id VARCHAR(25) NOT NULL,
+    target_type VARCHAR(10) NOT NULL,
+    rule TEXT NOT NULL,
+    channel_id VARCHAR(25) NOT NULL DEFAULT '',
+    expires TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (guild_id, scope_id, target_id, rule)
+);
+
+CREATE INDEX IF NOT EXISTS permission_grants_expires_idx ON permission_grants (expires);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS permission_grants;
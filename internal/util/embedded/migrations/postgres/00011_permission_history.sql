@@ -0,0 +1,20 @@
+-- +goose Up
+
+CREATE TABLE IF NOT EXISTS permission_history (
+    id VARCHAR(25) NOT NULL PRIMARY KEY,
+    guild_id VARCHAR(25) NOT NULL,
+    scope_id VARCHAR(25) NOT NULL DEFAULT '',
+    target_id VARCHAR(32) NOT NULL,
+    target_type VARCHAR(10) NOT NULL,
+    actor_id VARCHAR(25) NOT NULL DEFAULT '',
+    old_perms TEXT NOT NULL DEFAULT '',
+    new_perms TEXT NOT NULL DEFAULT '',
+    created TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS permission_history_guild_idx ON permission_history (guild_id, created);
+CREATE INDEX IF NOT EXISTS permission_history_actor_idx ON permission_history (actor_id);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS permission_history;
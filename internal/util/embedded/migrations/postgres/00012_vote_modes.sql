@@ -0,0 +1,15 @@
+-- +goose Up
+
+ALTER TABLE votes ADD COLUMN mode VARCHAR(10) NOT NULL DEFAULT 'single';
+
+ALTER TABLE votes ADD COLUMN max_choices INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE vote_ticks ADD COLUMN choices TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+
+ALTER TABLE vote_ticks DROP COLUMN choices;
+
+ALTER TABLE votes DROP COLUMN max_choices;
+
+ALTER TABLE votes DROP COLUMN mode;
@@ -0,0 +1,11 @@
+-- +goose Up
+
+ALTER TABLE votes ADD COLUMN closed TIMESTAMPTZ;
+
+ALTER TABLE vote_ticks ADD COLUMN created TIMESTAMPTZ;
+
+-- +goose Down
+
+ALTER TABLE vote_ticks DROP COLUMN created;
+
+ALTER TABLE votes DROP COLUMN closed;
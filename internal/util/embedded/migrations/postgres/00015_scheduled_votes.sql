@@ -0,0 +1,11 @@
+-- +goose Up
+
+ALTER TABLE votes ADD COLUMN starts TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS votes_starts_idx ON votes (starts);
+
+-- +goose Down
+
+DROP INDEX IF EXISTS votes_starts_idx;
+
+ALTER TABLE votes DROP COLUMN starts;
@@ -0,0 +1,51 @@
+-- +goose Up
+
+ALTER TABLE votes RENAME TO votes_legacy;
+
+ALTER TABLE autovoice RENAME TO autovoice_legacy;
+
+CREATE TABLE IF NOT EXISTS votes (
+    id VARCHAR(25) NOT NULL,
+    guild_id VARCHAR(25) NOT NULL DEFAULT '',
+    channel_id VARCHAR(25) NOT NULL DEFAULT '',
+    msg_id VARCHAR(25) NOT NULL DEFAULT '',
+    creator_id VARCHAR(25) NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    image_url TEXT NOT NULL DEFAULT '',
+    expires DATETIME,
+    choices TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS votes_guild_id_idx ON votes (guild_id, creator_id);
+
+CREATE INDEX IF NOT EXISTS votes_expires_idx ON votes (expires);
+
+CREATE TABLE IF NOT EXISTS vote_ticks (
+    vote_id VARCHAR(25) NOT NULL REFERENCES votes (id) ON DELETE CASCADE,
+    user_hash VARCHAR(64) NOT NULL,
+    tick INTEGER NOT NULL,
+    PRIMARY KEY (vote_id, user_hash)
+);
+
+CREATE TABLE IF NOT EXISTS autovoice (
+    channel_id VARCHAR(25) NOT NULL,
+    guild_id VARCHAR(25) NOT NULL DEFAULT '',
+    owner_id VARCHAR(25) NOT NULL DEFAULT '',
+    origin_channel_id VARCHAR(25) NOT NULL DEFAULT '',
+    PRIMARY KEY (channel_id)
+);
+
+CREATE INDEX IF NOT EXISTS autovoice_guild_id_idx ON autovoice (guild_id);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS vote_ticks;
+
+DROP TABLE IF EXISTS votes;
+
+DROP TABLE IF EXISTS autovoice;
+
+ALTER TABLE votes_legacy RENAME TO votes;
+
+ALTER TABLE autovoice_legacy RENAME TO autovoice;
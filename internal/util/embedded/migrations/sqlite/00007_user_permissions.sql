@@ -0,0 +1,12 @@
+-- +goose Up
+
+CREATE TABLE IF NOT EXISTS user_permissions (
+    guild_id VARCHAR(25) NOT NULL,
+    user_id VARCHAR(25) NOT NULL,
+    perms TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (guild_id, user_id)
+);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS user_permissions;
@@ -0,0 +1,12 @@
+-- +goose Up
+
+CREATE TABLE IF NOT EXISTS permission_groups (
+    guild_id VARCHAR(25) NOT NULL,
+    name VARCHAR(32) NOT NULL,
+    perms TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (guild_id, name)
+);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS permission_groups;
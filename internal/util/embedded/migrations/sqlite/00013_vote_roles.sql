@@ -0,0 +1,15 @@
+-- +goose Up
+
+ALTER TABLE votes ADD COLUMN roles TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE votes ADD COLUMN weights TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE vote_ticks ADD COLUMN weight INTEGER NOT NULL DEFAULT 1;
+
+-- +goose Down
+
+ALTER TABLE vote_ticks DROP COLUMN weight;
+
+ALTER TABLE votes DROP COLUMN weights;
+
+ALTER TABLE votes DROP COLUMN roles;
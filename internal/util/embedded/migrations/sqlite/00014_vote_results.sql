@@ -0,0 +1,11 @@
+-- +goose Up
+
+ALTER TABLE votes ADD COLUMN closed DATETIME;
+
+ALTER TABLE vote_ticks ADD COLUMN created DATETIME;
+
+-- +goose Down
+
+ALTER TABLE vote_ticks DROP COLUMN created;
+
+ALTER TABLE votes DROP COLUMN closed;
@@ -0,0 +1,411 @@
+// Package guildbackup converts the settings of a guild into a
+// portable backup which can be imported into the same or into
+// another guild.
+package guildbackup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/zekurio/daemon/internal/services/database"
+	"github.com/zekurio/daemon/internal/util/vote"
+	"github.com/zekurio/daemon/pkg/perms"
+)
+
+// Version is the current version of the backup format.
+// Version 2 added the rules of users, scopes and groups and
+// the pending grants, backups of version 1 are imported
+// without them.
+const Version = 2
+
+var ErrUnsupportedVersion = errors.New("unsupported backup version")
+
+// Backup is the exported configuration of a guild.
+//
+// Roles and Channels contain the names of all referenced
+// roles and channels, so that they can be looked up by
+// name when the backup is imported into another guild.
+type Backup struct {
+	Version     int                    `json:"version"`
+	GuildID     string                 `json:"guild_id"`
+	GuildName   string                 `json:"guild_name"`
+	ExportedAt  time.Time              `json:"exported_at"`
+	Roles       map[string]string      `json:"roles"`
+	Channels    map[string]Channel     `json:"channels"`
+	AutoRoles   []string               `json:"autoroles"`
+	AutoVoice   []string               `json:"autovoice"`
+	Permissions map[string]perms.Array `json:"permissions"`
+	// UserPermissions are keyed by user ID, which is the
+	// same in every guild.
+	UserPermissions map[string]perms.Array `json:"user_permissions"`
+	// ScopedPermissions are keyed by channel and then by
+	// the ID of a role or, if it is none of the roles, a user.
+	ScopedPermissions map[string]map[string]perms.Array `json:"scoped_permissions"`
+	PermissionGroups  map[string]perms.Array            `json:"permission_groups"`
+	PermissionGrants  []Grant                           `json:"permission_grants"`
+	Votes             []vote.Vote                       `json:"votes"`
+}
+
+// Grant is a pending permission grant.
+type Grant struct {
+	ScopeID    string    `json:"scope_id,omitempty"`
+	TargetID   string    `json:"target_id"`
+	TargetType string    `json:"target_type"`
+	Rule       string    `json:"rule"`
+	ChannelID  string    `json:"channel_id,omitempty"`
+	Expires    time.Time `json:"expires"`
+}
+
+// Channel identifies a channel by its name and type.
+type Channel struct {
+	Name string                `json:"name"`
+	Type discordgo.ChannelType `json:"type"`
+}
+
+// UnresolvedError is returned by Resolve when roles or
+// channels of the backup could not be found in the guild.
+type UnresolvedError struct {
+	Roles    []string
+	Channels []string
+}
+
+func (e *UnresolvedError) Error() string {
+	var parts []string
+	if len(e.Roles) != 0 {
+		parts = append(parts, "roles: "+strings.Join(e.Roles, ", "))
+	}
+	if len(e.Channels) != 0 {
+		parts = append(parts, "channels: "+strings.Join(e.Channels, ", "))
+	}
+	return "unresolved " + strings.Join(parts, "; ")
+}
+
+// New creates a backup of the given guild data. References
+// to roles and channels which do not exist anymore are
+// dropped.
+func New(guild *discordgo.Guild, channels []*discordgo.Channel, data database.GuildData) Backup {
+	b := Backup{
+		Version:           Version,
+		GuildID:           guild.ID,
+		GuildName:         guild.Name,
+		ExportedAt:        time.Now(),
+		Roles:             make(map[string]string),
+		Channels:          make(map[string]Channel),
+		AutoRoles:         []string{},
+		AutoVoice:         []string{},
+		Permissions:       make(map[string]perms.Array),
+		UserPermissions:   make(map[string]perms.Array),
+		ScopedPermissions: make(map[string]map[string]perms.Array),
+		PermissionGroups:  make(map[string]perms.Array),
+		PermissionGrants:  []Grant{},
+		Votes:             []vote.Vote{},
+	}
+
+	roles := make(map[string]*discordgo.Role, len(guild.Roles))
+	for _, r := range guild.Roles {
+		roles[r.ID] = r
+	}
+
+	chans := make(map[string]*discordgo.Channel, len(channels))
+	for _, c := range channels {
+		chans[c.ID] = c
+	}
+
+	addRole := func(id string) bool {
+		r, ok := roles[id]
+		if ok {
+			b.Roles[id] = r.Name
+		}
+		return ok
+	}
+
+	addChannel := func(id string) bool {
+		c, ok := chans[id]
+		if ok {
+			b.Channels[id] = Channel{Name: c.Name, Type: c.Type}
+		}
+		return ok
+	}
+
+	for _, id := range data.AutoRoles {
+		if addRole(id) {
+			b.AutoRoles = append(b.AutoRoles, id)
+		}
+	}
+
+	for _, id := range data.AutoVoice {
+		if addChannel(id) {
+			b.AutoVoice = append(b.AutoVoice, id)
+		}
+	}
+
+	for id, p := range data.Permissions {
+		if len(p) != 0 && addRole(id) {
+			b.Permissions[id] = p
+		}
+	}
+
+	for id, p := range data.UserPermissions {
+		if len(p) != 0 {
+			b.UserPermissions[id] = p
+		}
+	}
+
+	for scopeID, targets := range data.ScopedPermissions {
+		if !addChannel(scopeID) {
+			continue
+		}
+		for id, p := range targets {
+			if len(p) == 0 {
+				continue
+			}
+			addRole(id)
+			if b.ScopedPermissions[scopeID] == nil {
+				b.ScopedPermissions[scopeID] = make(map[string]perms.Array)
+			}
+			b.ScopedPermissions[scopeID][id] = p
+		}
+	}
+
+	for name, p := range data.PermissionGroups {
+		if len(p) != 0 {
+			b.PermissionGroups[name] = p
+		}
+	}
+
+	for _, g := range data.PermissionGrants {
+		if g.ScopeID != "" && !addChannel(g.ScopeID) {
+			continue
+		}
+		if g.TargetType == database.GrantTargetRole && !addRole(g.TargetID) {
+			continue
+		}
+		// The expiry is only announced when the channel
+		// still exists.
+		if g.ChannelID != "" && !addChannel(g.ChannelID) {
+			g.ChannelID = ""
+		}
+		b.PermissionGrants = append(b.PermissionGrants, Grant{
+			ScopeID:    g.ScopeID,
+			TargetID:   g.TargetID,
+			TargetType: g.TargetType,
+			Rule:       g.Rule,
+			ChannelID:  g.ChannelID,
+			Expires:    g.Expires,
+		})
+	}
+
+	for _, v := range data.Votes {
+		if !addChannel(v.ChannelID) {
+			continue
+		}
+
+		// Roles restricting or weighting the vote which do
+		// not exist anymore are dropped.
+		mapVoteRoles(&v, func(id string) (string, bool) {
+			return id, addRole(id)
+		})
+		b.Votes = append(b.Votes, v)
+	}
+
+	return b
+}
+
+// mapVoteRoles replaces the IDs of the roles restricting
+// and weighting the vote with the ones returned by mapID.
+// Roles which can not be mapped are dropped.
+func mapVoteRoles(v *vote.Vote, mapID func(id string) (string, bool)) {
+	roles := v.Roles
+	v.Roles = nil
+	for _, id := range roles {
+		if mapped, ok := mapID(id); ok {
+			v.Roles = append(v.Roles, mapped)
+		}
+	}
+
+	weights := v.Weights
+	v.Weights = nil
+	for id, w := range weights {
+		if mapped, ok := mapID(id); ok {
+			if v.Weights == nil {
+				v.Weights = make(map[string]int, len(weights))
+			}
+			v.Weights[mapped] = w
+		}
+	}
+}
+
+// Decode reads a backup from r and checks its version.
+func Decode(r io.Reader) (b Backup, err error) {
+	if err = json.NewDecoder(r).Decode(&b); err != nil {
+		return
+	}
+
+	if b.Version < 1 || b.Version > Version {
+		err = fmt.Errorf("%w: %d", ErrUnsupportedVersion, b.Version)
+	}
+
+	return
+}
+
+// Resolve maps the roles and channels of the backup to the
+// ones of the given guild and returns the resulting data.
+//
+// A role or channel is first looked up by its ID and then
+// by its name, for channels the type must match as well.
+// When any of them can not be mapped unambiguously, an
+// *UnresolvedError is returned. Votes and grants which
+// already expired and closed votes are dropped.
+func (b Backup) Resolve(guild *discordgo.Guild, channels []*discordgo.Channel) (database.GuildData, error) {
+	var unresolved UnresolvedError
+
+	roleIDs := make(map[string]string, len(b.Roles))
+	for id, name := range b.Roles {
+		var matches []string
+		for _, r := range guild.Roles {
+			if r.ID == id {
+				matches = []string{r.ID}
+				break
+			}
+			if r.Name == name {
+				matches = append(matches, r.ID)
+			}
+		}
+		if len(matches) != 1 {
+			unresolved.Roles = append(unresolved.Roles, name)
+			continue
+		}
+		roleIDs[id] = matches[0]
+	}
+
+	channelIDs := make(map[string]string, len(b.Channels))
+	for id, ch := range b.Channels {
+		var matches []string
+		for _, c := range channels {
+			if c.ID == id && c.Type == ch.Type {
+				matches = []string{c.ID}
+				break
+			}
+			if c.Name == ch.Name && c.Type == ch.Type {
+				matches = append(matches, c.ID)
+			}
+		}
+		if len(matches) != 1 {
+			unresolved.Channels = append(unresolved.Channels, ch.Name)
+			continue
+		}
+		channelIDs[id] = matches[0]
+	}
+
+	if len(unresolved.Roles) != 0 || len(unresolved.Channels) != 0 {
+		sort.Strings(unresolved.Roles)
+		sort.Strings(unresolved.Channels)
+		return database.GuildData{}, &unresolved
+	}
+
+	data := database.GuildData{
+		AutoRoles:         make([]string, 0, len(b.AutoRoles)),
+		AutoVoice:         make([]string, 0, len(b.AutoVoice)),
+		Permissions:       make(map[string]perms.Array, len(b.Permissions)),
+		UserPermissions:   make(map[string]perms.Array, len(b.UserPermissions)),
+		ScopedPermissions: make(database.ScopedPermissions, len(b.ScopedPermissions)),
+		PermissionGroups:  make(map[string]perms.Array, len(b.PermissionGroups)),
+		PermissionGrants:  make([]database.PermissionGrant, 0, len(b.PermissionGrants)),
+		Votes:             make([]vote.Vote, 0, len(b.Votes)),
+	}
+
+	// Targets which are none of the roles of the backup
+	// are users, whose IDs are kept.
+	mapTarget := func(id string) string {
+		if mapped, ok := roleIDs[id]; ok {
+			return mapped
+		}
+		return id
+	}
+
+	for _, id := range b.AutoRoles {
+		if mapped, ok := roleIDs[id]; ok {
+			data.AutoRoles = append(data.AutoRoles, mapped)
+		}
+	}
+
+	for _, id := range b.AutoVoice {
+		if mapped, ok := channelIDs[id]; ok {
+			data.AutoVoice = append(data.AutoVoice, mapped)
+		}
+	}
+
+	for id, p := range b.Permissions {
+		if mapped, ok := roleIDs[id]; ok {
+			data.Permissions[mapped] = p
+		}
+	}
+
+	for id, p := range b.UserPermissions {
+		data.UserPermissions[id] = p
+	}
+
+	for scopeID, targets := range b.ScopedPermissions {
+		mappedScope, ok := channelIDs[scopeID]
+		if !ok {
+			continue
+		}
+		scoped := make(map[string]perms.Array, len(targets))
+		for id, p := range targets {
+			scoped[mapTarget(id)] = p
+		}
+		data.ScopedPermissions[mappedScope] = scoped
+	}
+
+	for name, p := range b.PermissionGroups {
+		data.PermissionGroups[name] = p
+	}
+
+	now := time.Now()
+	for _, g := range b.PermissionGrants {
+		if g.Expires.Before(now) {
+			continue
+		}
+		grant := database.PermissionGrant{
+			GuildID:    guild.ID,
+			TargetID:   g.TargetID,
+			TargetType: g.TargetType,
+			Rule:       g.Rule,
+			ChannelID:  channelIDs[g.ChannelID],
+			Expires:    g.Expires,
+		}
+		if g.ScopeID != "" {
+			grant.ScopeID = channelIDs[g.ScopeID]
+		}
+		if g.TargetType == database.GrantTargetRole {
+			grant.TargetID = roleIDs[g.TargetID]
+		}
+		if grant.ScopeID == "" && g.ScopeID != "" || grant.TargetID == "" {
+			continue
+		}
+		data.PermissionGrants = append(data.PermissionGrants, grant)
+	}
+
+	for _, v := range b.Votes {
+		mapped, ok := channelIDs[v.ChannelID]
+		if !ok || !v.Closed.IsZero() || (!v.Expires.IsZero() && v.Expires.Before(now)) {
+			continue
+		}
+		v.GuildID = guild.ID
+		v.ChannelID = mapped
+		mapVoteRoles(&v, func(id string) (string, bool) {
+			mapped, ok := roleIDs[id]
+			return mapped, ok
+		})
+		data.Votes = append(data.Votes, v)
+	}
+
+	return data, nil
+}
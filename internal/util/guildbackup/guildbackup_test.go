@@ -0,0 +1,211 @@
+package guildbackup
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zekurio/daemon/internal/services/database"
+	"github.com/zekurio/daemon/internal/util/vote"
+	"github.com/zekurio/daemon/pkg/perms"
+)
+
+var (
+	sourceGuild = &discordgo.Guild{
+		ID:   "100",
+		Name: "source",
+		Roles: []*discordgo.Role{
+			{ID: "100", Name: "@everyone"},
+			{ID: "101", Name: "Admin"},
+			{ID: "102", Name: "Member"},
+		},
+	}
+	sourceChannels = []*discordgo.Channel{
+		{ID: "110", Name: "general", Type: discordgo.ChannelTypeGuildText},
+		{ID: "111", Name: "lobby", Type: discordgo.ChannelTypeGuildVoice},
+	}
+
+	targetGuild = &discordgo.Guild{
+		ID:   "200",
+		Name: "target",
+		Roles: []*discordgo.Role{
+			{ID: "200", Name: "@everyone"},
+			{ID: "201", Name: "Admin"},
+			{ID: "202", Name: "Member"},
+		},
+	}
+	targetChannels = []*discordgo.Channel{
+		{ID: "210", Name: "general", Type: discordgo.ChannelTypeGuildText},
+		{ID: "211", Name: "lobby", Type: discordgo.ChannelTypeGuildVoice},
+		{ID: "212", Name: "lobby", Type: discordgo.ChannelTypeGuildText},
+	}
+)
+
+func sourceData() database.GuildData {
+	return database.GuildData{
+		AutoRoles: []string{"102", "199"},
+		AutoVoice: []string{"111"},
+		Permissions: map[string]perms.Array{
+			"100": {"+dm.chat.*"},
+			"101": {"+dm.guild.*"},
+			"199": {"+dm.etc.*"},
+		},
+		UserPermissions: map[string]perms.Array{
+			"300": {"+dm.chat.*"},
+		},
+		ScopedPermissions: database.ScopedPermissions{
+			"110": {"101": {"-dm.chat.vote"}, "300": {"+dm.chat.vote"}},
+			"199": {"101": {"+dm.etc.*"}},
+		},
+		PermissionGroups: map[string]perms.Array{
+			"mods": {"+dm.guild.*"},
+		},
+		PermissionGrants: []database.PermissionGrant{
+			{
+				GuildID: "100", ScopeID: "110", TargetID: "102", TargetType: database.GrantTargetRole,
+				Rule: "+dm.chat.vote", ChannelID: "110", Expires: time.Now().Add(time.Hour),
+			},
+			{
+				GuildID: "100", TargetID: "300", TargetType: database.GrantTargetUser,
+				Rule: "+dm.guild.*", ChannelID: "199", Expires: time.Now().Add(time.Hour),
+			},
+			{
+				GuildID: "100", TargetID: "199", TargetType: database.GrantTargetRole,
+				Rule: "+dm.guild.*", Expires: time.Now().Add(time.Hour),
+			},
+			{
+				GuildID: "100", TargetID: "300", TargetType: database.GrantTargetUser,
+				Rule: "+dm.chat.*", Expires: time.Now().Add(-time.Hour),
+			},
+		},
+		Votes: []vote.Vote{
+			{
+				ID: "120", GuildID: "100", ChannelID: "110", Possibilities: []string{"a", "b"},
+				Roles: []string{"101", "198"}, Weights: map[string]int{"102": 3, "198": 2},
+			},
+			{ID: "121", GuildID: "100", ChannelID: "110", Expires: time.Now().Add(-time.Hour)},
+			{ID: "122", GuildID: "100", ChannelID: "110", Closed: time.Now().Add(-time.Hour)},
+		},
+	}
+}
+
+func TestNew(t *testing.T) {
+	b := New(sourceGuild, sourceChannels, sourceData())
+
+	assert.Equal(t, Version, b.Version)
+	assert.Equal(t, "100", b.GuildID)
+	assert.Equal(t, []string{"102"}, b.AutoRoles)
+	assert.Equal(t, []string{"111"}, b.AutoVoice)
+	assert.NotContains(t, b.Permissions, "199")
+	assert.Equal(t, map[string]perms.Array{"300": {"+dm.chat.*"}}, b.UserPermissions)
+	assert.Equal(t, map[string]map[string]perms.Array{
+		"110": {"101": {"-dm.chat.vote"}, "300": {"+dm.chat.vote"}},
+	}, b.ScopedPermissions)
+	assert.Equal(t, map[string]perms.Array{"mods": {"+dm.guild.*"}}, b.PermissionGroups)
+	require.Len(t, b.PermissionGrants, 3)
+	assert.Equal(t, "102", b.PermissionGrants[0].TargetID)
+	assert.Equal(t, "", b.PermissionGrants[1].ChannelID)
+	assert.Len(t, b.Votes, 3)
+	assert.Equal(t, []string{"101"}, b.Votes[0].Roles)
+	assert.Equal(t, map[string]int{"102": 3}, b.Votes[0].Weights)
+	assert.Equal(t, map[string]string{
+		"100": "@everyone",
+		"101": "Admin",
+		"102": "Member",
+	}, b.Roles)
+	assert.Equal(t, map[string]Channel{
+		"110": {Name: "general", Type: discordgo.ChannelTypeGuildText},
+		"111": {Name: "lobby", Type: discordgo.ChannelTypeGuildVoice},
+	}, b.Channels)
+}
+
+func TestDecode(t *testing.T) {
+	var buf bytes.Buffer
+	require.Nil(t, json.NewEncoder(&buf).Encode(New(sourceGuild, sourceChannels, sourceData())))
+
+	b, err := Decode(&buf)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"102"}, b.AutoRoles)
+
+	// Backups of version 1 have no rules of users, scopes
+	// and groups and no grants.
+	b, err = Decode(bytes.NewBufferString(`{"version": 1, "autoroles": ["102"]}`))
+	require.Nil(t, err)
+	assert.Equal(t, []string{"102"}, b.AutoRoles)
+	assert.Empty(t, b.UserPermissions)
+
+	_, err = Decode(bytes.NewBufferString(`{"version": 3}`))
+	assert.ErrorIs(t, err, ErrUnsupportedVersion)
+
+	_, err = Decode(bytes.NewBufferString(`not json`))
+	assert.NotNil(t, err)
+}
+
+func TestResolve(t *testing.T) {
+	b := New(sourceGuild, sourceChannels, sourceData())
+
+	data, err := b.Resolve(targetGuild, targetChannels)
+	require.Nil(t, err)
+
+	assert.Equal(t, []string{"202"}, data.AutoRoles)
+	assert.Equal(t, []string{"211"}, data.AutoVoice)
+	assert.Equal(t, map[string]perms.Array{
+		"200": {"+dm.chat.*"},
+		"201": {"+dm.guild.*"},
+	}, data.Permissions)
+	assert.Equal(t, map[string]perms.Array{"300": {"+dm.chat.*"}}, data.UserPermissions)
+	assert.Equal(t, database.ScopedPermissions{
+		"210": {"201": {"-dm.chat.vote"}, "300": {"+dm.chat.vote"}},
+	}, data.ScopedPermissions)
+	assert.Equal(t, map[string]perms.Array{"mods": {"+dm.guild.*"}}, data.PermissionGroups)
+
+	// The expired grant is dropped.
+	require.Len(t, data.PermissionGrants, 2)
+	assert.Equal(t, database.PermissionGrant{
+		GuildID: "200", ScopeID: "210", TargetID: "202", TargetType: database.GrantTargetRole,
+		Rule: "+dm.chat.vote", ChannelID: "210", Expires: b.PermissionGrants[0].Expires,
+	}, data.PermissionGrants[0])
+	assert.Equal(t, "300", data.PermissionGrants[1].TargetID)
+
+	require.Len(t, data.Votes, 1)
+	assert.Equal(t, "120", data.Votes[0].ID)
+	assert.Equal(t, "200", data.Votes[0].GuildID)
+	assert.Equal(t, "210", data.Votes[0].ChannelID)
+	assert.Equal(t, []string{"201"}, data.Votes[0].Roles)
+	assert.Equal(t, map[string]int{"202": 3}, data.Votes[0].Weights)
+}
+
+func TestResolveSameGuild(t *testing.T) {
+	b := New(sourceGuild, sourceChannels, sourceData())
+
+	// Roles are matched by ID first, so renamed
+	// roles are still found in the same guild.
+	renamed := *sourceGuild
+	renamed.Roles = append([]*discordgo.Role{}, sourceGuild.Roles...)
+	renamed.Roles[1] = &discordgo.Role{ID: "101", Name: "Moderator"}
+
+	data, err := b.Resolve(&renamed, sourceChannels)
+	require.Nil(t, err)
+	assert.Equal(t, perms.Array{"+dm.guild.*"}, data.Permissions["101"])
+}
+
+func TestResolveUnresolved(t *testing.T) {
+	b := New(sourceGuild, sourceChannels, sourceData())
+
+	guild := *targetGuild
+	guild.Roles = append(append([]*discordgo.Role{}, targetGuild.Roles[:2]...),
+		&discordgo.Role{ID: "203", Name: "Admin"})
+	channels := targetChannels[1:]
+
+	_, err := b.Resolve(&guild, channels)
+
+	var uerr *UnresolvedError
+	require.ErrorAs(t, err, &uerr)
+	assert.Equal(t, []string{"Admin", "Member"}, uerr.Roles)
+	assert.Equal(t, []string{"general"}, uerr.Channels)
+}
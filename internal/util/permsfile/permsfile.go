@@ -0,0 +1,272 @@
+// Package permsfile converts the permission rules of a guild
+// into a JSON or TOML file which can be edited and imported
+// again with /perms import.
+package permsfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/zekurio/daemon/pkg/arrayutils"
+	"github.com/zekurio/daemon/pkg/perms"
+)
+
+// Format is the encoding of a rules file.
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatTOML Format = "toml"
+)
+
+// FormatOf returns the format matching the extension
+// of the given file name.
+func FormatOf(name string) (Format, bool) {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".json":
+		return FormatJSON, true
+	case ".toml":
+		return FormatTOML, true
+	}
+	return "", false
+}
+
+// ContentType returns the MIME type of the format.
+func (f Format) ContentType() string {
+	if f == FormatTOML {
+		return "application/toml"
+	}
+	return "application/json"
+}
+
+// File contains the guild wide rules of roles and users.
+//
+// Roles are keyed by their name, or by their ID when the
+// name is not unique in the guild. Users are keyed by
+// their ID.
+type File struct {
+	Roles map[string]perms.Array `json:"roles" toml:"roles"`
+	Users map[string]perms.Array `json:"users,omitempty" toml:"users,omitempty"`
+}
+
+// UnresolvedError is returned by Resolve when entries of
+// the file could not be mapped to roles or users.
+type UnresolvedError struct {
+	Roles []string
+	Users []string
+}
+
+func (e *UnresolvedError) Error() string {
+	var parts []string
+	if len(e.Roles) != 0 {
+		parts = append(parts, "roles: "+strings.Join(e.Roles, ", "))
+	}
+	if len(e.Users) != 0 {
+		parts = append(parts, "users: "+strings.Join(e.Users, ", "))
+	}
+	return "unresolved " + strings.Join(parts, "; ")
+}
+
+// New creates a file of the given rules. Rules of roles
+// which do not exist anymore are dropped.
+func New(roles []*discordgo.Role, rolePerms, userPerms map[string]perms.Array) File {
+	f := File{
+		Roles: make(map[string]perms.Array, len(rolePerms)),
+		Users: make(map[string]perms.Array, len(userPerms)),
+	}
+
+	names := make(map[string]int, len(roles))
+	for _, r := range roles {
+		names[r.Name]++
+	}
+
+	for _, r := range roles {
+		p, ok := rolePerms[r.ID]
+		if !ok || len(p) == 0 {
+			continue
+		}
+		key := r.Name
+		if names[r.Name] > 1 {
+			key = r.ID
+		}
+		f.Roles[key] = p
+	}
+
+	for id, p := range userPerms {
+		if len(p) != 0 {
+			f.Users[id] = p
+		}
+	}
+
+	return f
+}
+
+// Encode writes the file in the given format to w.
+func (f File) Encode(w io.Writer, format Format) error {
+	if format == FormatTOML {
+		return toml.NewEncoder(w).Encode(f)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(f)
+}
+
+// Decode reads a file in the given format from r. Unknown
+// keys are rejected to catch typos.
+func Decode(r io.Reader, format Format) (f File, err error) {
+	if format == FormatTOML {
+		var md toml.MetaData
+		md, err = toml.NewDecoder(r).Decode(&f)
+		if err != nil {
+			return
+		}
+		if undecoded := md.Undecoded(); len(undecoded) != 0 {
+			err = fmt.Errorf("unknown key %s", undecoded[0])
+		}
+		return
+	}
+
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	err = dec.Decode(&f)
+	return
+}
+
+// InvalidRules returns the rules of the file which are not
+// prefixed with + or - or whose permission is not accepted
+// by isValid. References to groups are passed to isValid
+// as they are.
+func (f File) InvalidRules(isValid func(dn string) bool) []string {
+	seen := make(map[string]struct{})
+	var invalid []string
+
+	check := func(rules perms.Array) {
+		for _, rule := range rules {
+			if _, ok := seen[rule]; ok {
+				continue
+			}
+			seen[rule] = struct{}{}
+			if _, ok := perms.GroupName(rule); ok {
+				if !isValid(rule) {
+					invalid = append(invalid, rule)
+				}
+				continue
+			}
+			if len(rule) < 2 || (rule[0] != '+' && rule[0] != '-') || !isValid(rule[1:]) {
+				invalid = append(invalid, rule)
+			}
+		}
+	}
+
+	for _, rules := range f.Roles {
+		check(rules)
+	}
+	for _, rules := range f.Users {
+		check(rules)
+	}
+
+	sort.Strings(invalid)
+	return invalid
+}
+
+// Resolve maps the entries of the file to the IDs of the
+// given roles and returns the rules of roles and users.
+//
+// A role is first looked up by its ID and then by its name.
+// When any of them can not be mapped unambiguously or a
+// user key is not an ID, an *UnresolvedError is returned.
+func (f File) Resolve(roles []*discordgo.Role) (rolePerms, userPerms map[string]perms.Array, err error) {
+	var unresolved UnresolvedError
+
+	rolePerms = make(map[string]perms.Array, len(f.Roles))
+	for key, p := range f.Roles {
+		var matches []string
+		for _, r := range roles {
+			if r.ID == key {
+				matches = []string{r.ID}
+				break
+			}
+			if r.Name == key {
+				matches = append(matches, r.ID)
+			}
+		}
+		if len(matches) != 1 {
+			unresolved.Roles = append(unresolved.Roles, key)
+			continue
+		}
+		rolePerms[matches[0]] = rolePerms[matches[0]].Merge(p, true)
+	}
+
+	userPerms = make(map[string]perms.Array, len(f.Users))
+	for key, p := range f.Users {
+		if _, err := strconv.ParseUint(key, 10, 64); err != nil {
+			unresolved.Users = append(unresolved.Users, key)
+			continue
+		}
+		userPerms[key] = userPerms[key].Merge(p, true)
+	}
+
+	if len(unresolved.Roles) != 0 || len(unresolved.Users) != 0 {
+		sort.Strings(unresolved.Roles)
+		sort.Strings(unresolved.Users)
+		return nil, nil, &unresolved
+	}
+
+	return rolePerms, userPerms, nil
+}
+
+// Change describes how the rules of a target change.
+type Change struct {
+	TargetID string
+	// Previous are the rules before the change.
+	Previous perms.Array
+	Rules    perms.Array
+	Added    []string
+	Removed  []string
+}
+
+// Diff compares the rules of the targets before and after
+// and returns the changes ordered by target ID. Targets
+// missing in after are cleared.
+func Diff(before, after map[string]perms.Array) []Change {
+	ids := make(map[string]struct{}, len(before)+len(after))
+	for id := range before {
+		ids[id] = struct{}{}
+	}
+	for id := range after {
+		ids[id] = struct{}{}
+	}
+
+	var changes []Change
+	for id := range ids {
+		c := Change{TargetID: id, Previous: before[id], Rules: after[id]}
+		for _, rule := range after[id] {
+			if !arrayutils.Contains(before[id], rule) {
+				c.Added = append(c.Added, rule)
+			}
+		}
+		for _, rule := range before[id] {
+			if !arrayutils.Contains(after[id], rule) {
+				c.Removed = append(c.Removed, rule)
+			}
+		}
+		if len(c.Added) != 0 || len(c.Removed) != 0 {
+			changes = append(changes, c)
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].TargetID < changes[j].TargetID
+	})
+
+	return changes
+}
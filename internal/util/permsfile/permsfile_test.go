@@ -0,0 +1,149 @@
+package permsfile
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zekurio/daemon/pkg/perms"
+)
+
+var roles = []*discordgo.Role{
+	{ID: "100", Name: "@everyone"},
+	{ID: "101", Name: "Admin"},
+	{ID: "102", Name: "Member"},
+	{ID: "103", Name: "Member"},
+}
+
+func TestFormatOf(t *testing.T) {
+	f, ok := FormatOf("rules.TOML")
+	assert.True(t, ok)
+	assert.Equal(t, FormatTOML, f)
+
+	f, ok = FormatOf("daemon-1-perms.json")
+	assert.True(t, ok)
+	assert.Equal(t, FormatJSON, f)
+
+	_, ok = FormatOf("rules.yml")
+	assert.False(t, ok)
+}
+
+func TestNew(t *testing.T) {
+	f := New(roles, map[string]perms.Array{
+		"100": {"+dm.chat.*"},
+		"102": {"+dm.etc.*"},
+		"199": {"+dm.guild.*"},
+		"101": {},
+	}, map[string]perms.Array{
+		"200": {"-dm.chat.vote"},
+	})
+
+	assert.Equal(t, map[string]perms.Array{
+		"@everyone": {"+dm.chat.*"},
+		"102":       {"+dm.etc.*"},
+	}, f.Roles)
+	assert.Equal(t, map[string]perms.Array{
+		"200": {"-dm.chat.vote"},
+	}, f.Users)
+}
+
+func TestEncodeDecode(t *testing.T) {
+	f := New(roles, map[string]perms.Array{
+		"100": {"+dm.chat.*", "-dm.chat.vote"},
+		"101": {"+dm.guild.*"},
+	}, map[string]perms.Array{
+		"200": {"+dm.chat.vote"},
+	})
+
+	for _, format := range []Format{FormatJSON, FormatTOML} {
+		var buf bytes.Buffer
+		require.Nil(t, f.Encode(&buf, format))
+
+		decoded, err := Decode(&buf, format)
+		require.Nil(t, err, format)
+		assert.Equal(t, f, decoded, format)
+	}
+}
+
+func TestDecodeUnknownKeys(t *testing.T) {
+	_, err := Decode(bytes.NewBufferString(`{"role": {"Admin": ["+dm.guild.*"]}}`), FormatJSON)
+	assert.NotNil(t, err)
+
+	_, err = Decode(bytes.NewBufferString("[role]\nAdmin = [\"+dm.guild.*\"]\n"), FormatTOML)
+	assert.NotNil(t, err)
+
+	f, err := Decode(bytes.NewBufferString("[roles]\nAdmin = [\"+dm.guild.*\"]\n"), FormatTOML)
+	require.Nil(t, err)
+	assert.Equal(t, perms.Array{"+dm.guild.*"}, f.Roles["Admin"])
+}
+
+func TestInvalidRules(t *testing.T) {
+	f := File{
+		Roles: map[string]perms.Array{
+			"Admin": {"+dm.guild.*", "dm.chat.vote", "+", "@group:moderator"},
+		},
+		Users: map[string]perms.Array{
+			"200": {"-dm.unknown", "+dm.guild.*", "@group:unknown"},
+		},
+	}
+
+	invalid := f.InvalidRules(func(dn string) bool {
+		return dn == "dm.guild.*" || dn == "@group:moderator"
+	})
+	assert.Equal(t, []string{"+", "-dm.unknown", "@group:unknown", "dm.chat.vote"}, invalid)
+}
+
+func TestResolve(t *testing.T) {
+	f := File{
+		Roles: map[string]perms.Array{
+			"Admin": {"+dm.guild.*"},
+			"103":   {"+dm.etc.*", "-dm.etc.*"},
+		},
+		Users: map[string]perms.Array{
+			"200": {"-dm.chat.vote"},
+		},
+	}
+
+	rolePerms, userPerms, err := f.Resolve(roles)
+	require.Nil(t, err)
+	assert.Equal(t, map[string]perms.Array{
+		"101": {"+dm.guild.*"},
+		"103": {"-dm.etc.*"},
+	}, rolePerms)
+	assert.Equal(t, map[string]perms.Array{
+		"200": {"-dm.chat.vote"},
+	}, userPerms)
+
+	f.Roles["Member"] = perms.Array{"+dm.chat.*"}
+	f.Roles["Moderator"] = perms.Array{"+dm.chat.*"}
+	f.Users["someone"] = perms.Array{"+dm.chat.*"}
+
+	_, _, err = f.Resolve(roles)
+	uerr, ok := err.(*UnresolvedError)
+	require.True(t, ok)
+	assert.Equal(t, []string{"Member", "Moderator"}, uerr.Roles)
+	assert.Equal(t, []string{"someone"}, uerr.Users)
+}
+
+func TestDiff(t *testing.T) {
+	changes := Diff(map[string]perms.Array{
+		"1": {"+dm.chat.*", "-dm.chat.vote"},
+		"2": {"+dm.etc.*"},
+		"3": {"+dm.guild.*"},
+	}, map[string]perms.Array{
+		"1": {"+dm.chat.*", "+dm.chat.vote"},
+		"2": {"+dm.etc.*"},
+		"4": {"+dm.guild.*"},
+	})
+
+	assert.Equal(t, []Change{
+		{TargetID: "1", Previous: perms.Array{"+dm.chat.*", "-dm.chat.vote"},
+			Rules: perms.Array{"+dm.chat.*", "+dm.chat.vote"},
+			Added: []string{"+dm.chat.vote"}, Removed: []string{"-dm.chat.vote"}},
+		{TargetID: "3", Previous: perms.Array{"+dm.guild.*"}, Removed: []string{"+dm.guild.*"}},
+		{TargetID: "4", Rules: perms.Array{"+dm.guild.*"}, Added: []string{"+dm.guild.*"}},
+	}, changes)
+}
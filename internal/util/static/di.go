@@ -6,5 +6,7 @@ const (
 	DiDatabase       = "di-database"
 	DiCommandHandler = "di-commandhandler"
 	DiPermissions    = "di-permissions"
+	DiPermsCache     = "di-permscache"
 	DiScheduler      = "di-scheduler"
+	DiContext        = "di-context"
 )
@@ -0,0 +1,122 @@
+package vote
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/wcharczuk/go-chart"
+	"github.com/wcharczuk/go-chart/drawing"
+)
+
+// ChartKind is a type for the kind of a result chart
+type ChartKind string
+
+const (
+	ChartPie ChartKind = "pie"
+	ChartBar ChartKind = "bar"
+)
+
+const (
+	chartSize = 512
+	// barWidth and barSpacing size the bars of bar charts,
+	// which grow wider than chartSize for many choices.
+	barWidth   = 40
+	barSpacing = 16
+	// maxBarLabel is the maximum length of the label below
+	// a bar.
+	maxBarLabel = 14
+)
+
+// ErrNoTicks is returned when a chart is rendered for a
+// vote without any ticks.
+var ErrNoTicks = errors.New("vote has no ticks")
+
+// resultCounts returns the weighted counts of each
+// possibility shown in charts, which are the counts of the
+// final instant-runoff round for ranked votes.
+func (v *Vote) resultCounts() []int {
+	if v.Mode == ModeRanked {
+		rounds, _ := v.InstantRunoff()
+		return rounds[len(rounds)-1].Counts
+	}
+	return v.Counts()
+}
+
+// Chart renders the results of the vote as a PNG image.
+func (v *Vote) Chart(kind ChartKind) ([]byte, error) {
+	counts := v.resultCounts()
+
+	max := 0
+	for _, n := range counts {
+		if n > max {
+			max = n
+		}
+	}
+	if max == 0 {
+		return nil, ErrNoTicks
+	}
+
+	values := make([]chart.Value, len(v.Possibilities))
+	for i, p := range v.Possibilities {
+		values[i] = chart.Value{
+			Value: float64(counts[i]),
+			Label: p,
+		}
+	}
+
+	var (
+		buff bytes.Buffer
+		err  error
+	)
+
+	switch kind {
+	case ChartBar:
+		for i := range values {
+			values[i].Label = truncate(fmt.Sprintf("%d. %s", i+1, values[i].Label), maxBarLabel)
+		}
+
+		width := len(values)*(barWidth+barSpacing) + 2*barSpacing + 64
+		if width < chartSize {
+			width = chartSize
+		}
+
+		bar := chart.BarChart{
+			Width:      width,
+			Height:     chartSize,
+			BarWidth:   barWidth,
+			BarSpacing: barSpacing,
+			Bars:       values,
+			XAxis:      chart.StyleShow(),
+			YAxis: chart.YAxis{
+				Style: chart.StyleShow(),
+				// An explicit range keeps bars of equal
+				// height from collapsing the axis.
+				Range: &chart.ContinuousRange{Min: 0, Max: float64(max)},
+				ValueFormatter: func(v interface{}) string {
+					return fmt.Sprintf("%.0f", v)
+				},
+			},
+			Background: chart.Style{
+				FillColor: drawing.ColorTransparent,
+			},
+		}
+		err = bar.Render(chart.PNG, &buff)
+
+	default:
+		pie := chart.PieChart{
+			Width:  chartSize,
+			Height: chartSize,
+			Values: values,
+			Background: chart.Style{
+				FillColor: drawing.ColorTransparent,
+			},
+		}
+		err = pie.Render(chart.PNG, &buff)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return buff.Bytes(), nil
+}
@@ -0,0 +1,117 @@
+package vote
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/zekurio/daemon/internal/util/static"
+)
+
+const (
+	// cancelIDPrefix prefixes the custom ID of the select
+	// menu to cancel pending votes, followed by the ID of
+	// the user whose votes are listed.
+	cancelIDPrefix = "vote-cancel:"
+
+	// maxListed is the maximum number of votes in a list,
+	// which is limited by the fields of an embed and the
+	// options of a select menu.
+	maxListed = 25
+)
+
+// UserVotes returns the running votes and the pending votes
+// of the given guild votes created by the user, running
+// votes first and pending votes by their start time.
+func UserVotes(guildVotes map[string]Vote, guildID, userID string) []Vote {
+	var votes []Vote
+	for _, v := range RunningVotes() {
+		if v.GuildID == guildID && v.CreatorID == userID {
+			votes = append(votes, v)
+		}
+	}
+	for _, v := range guildVotes {
+		if v.Pending() && v.GuildID == guildID && v.CreatorID == userID {
+			votes = append(votes, v)
+		}
+	}
+
+	sort.Slice(votes, func(i, j int) bool {
+		if votes[i].Pending() != votes[j].Pending() {
+			return !votes[i].Pending()
+		}
+		if !votes[i].Starts.Equal(votes[j].Starts) {
+			return votes[i].Starts.Before(votes[j].Starts)
+		}
+		return votes[i].ID < votes[j].ID
+	})
+
+	if len(votes) > maxListed {
+		votes = votes[:maxListed]
+	}
+	return votes
+}
+
+// ListEmbed returns an embed listing the given votes.
+func ListEmbed(votes []Vote) *discordgo.MessageEmbed {
+	emb := &discordgo.MessageEmbed{
+		Description: "Your open and scheduled votes on this guild:",
+		Color:       static.ColorDefault,
+		Fields:      make([]*discordgo.MessageEmbedField, 0, len(votes)),
+	}
+	for _, v := range votes {
+		emb.Fields = append(emb.Fields, v.AsField())
+	}
+	if len(emb.Fields) == 0 {
+		emb.Description = "You don't have any open or scheduled votes on this guild."
+	}
+	return emb
+}
+
+// CancelComponents returns a select menu to cancel the
+// pending ones of the given votes, which only the user
+// they are listed for can use. It is empty when none of
+// the votes is pending.
+func CancelComponents(userID string, votes []Vote) []discordgo.MessageComponent {
+	var options []discordgo.SelectMenuOption
+	for _, v := range votes {
+		if !v.Pending() {
+			continue
+		}
+		options = append(options, discordgo.SelectMenuOption{
+			Label: truncate(fmt.Sprintf("%s · %s", v.ID, v.Description), maxOptionLabel),
+			Value: v.ID,
+		})
+	}
+
+	if len(options) == 0 {
+		return []discordgo.MessageComponent{}
+	}
+
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.SelectMenu{
+					CustomID:    cancelIDPrefix + userID,
+					Placeholder: "Cancel a scheduled vote",
+					MaxValues:   1,
+					Options:     options,
+				},
+			},
+		},
+	}
+}
+
+// ParseCancel returns the ID of the user the list belongs
+// to and the ID of the vote to cancel selected with the
+// given component interaction. ok is false when the
+// component is not a cancel menu.
+func ParseCancel(data discordgo.MessageComponentInteractionData) (userID, voteID string, ok bool) {
+	userID, ok = strings.CutPrefix(data.CustomID, cancelIDPrefix)
+	if !ok || userID == "" || len(data.Values) != 1 || data.Values[0] == "" {
+		return "", "", false
+	}
+	return userID, data.Values[0], true
+}
@@ -0,0 +1,68 @@
+package vote
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserVotes(t *testing.T) {
+	now := time.Now()
+	running := []Vote{
+		{ID: "1", GuildID: "g", CreatorID: "u", MsgID: "m1"},
+		{ID: "2", GuildID: "g", CreatorID: "other", MsgID: "m2"},
+		{ID: "3", GuildID: "other", CreatorID: "u", MsgID: "m3"},
+	}
+	for _, v := range running {
+		SetRunning(v)
+		defer RemoveRunning(v.ID)
+	}
+	guildVotes := map[string]Vote{
+		"1": running[0],
+		"4": {ID: "4", GuildID: "g", CreatorID: "u", Starts: now.Add(2 * time.Hour)},
+		"5": {ID: "5", GuildID: "g", CreatorID: "u", Starts: now.Add(time.Hour)},
+		"6": {ID: "6", GuildID: "g", CreatorID: "other", Starts: now.Add(time.Hour)},
+		"7": {ID: "7", GuildID: "g", CreatorID: "u", Closed: now},
+	}
+
+	votes := UserVotes(guildVotes, "g", "u")
+
+	ids := make([]string, len(votes))
+	for i, v := range votes {
+		ids[i] = v.ID
+	}
+	assert.Equal(t, []string{"1", "5", "4"}, ids)
+}
+
+func TestCancelComponents(t *testing.T) {
+	votes := []Vote{
+		{ID: "1", MsgID: "m1", Description: "Running"},
+		{ID: "2", Description: "Pizza or pasta?", Starts: time.Now()},
+	}
+
+	rows := CancelComponents("u", votes)
+	require.Len(t, rows, 1)
+	menu := rows[0].(discordgo.ActionsRow).Components[0].(discordgo.SelectMenu)
+	assert.Equal(t, []discordgo.SelectMenuOption{
+		{Label: "2 · Pizza or pasta?", Value: "2"},
+	}, menu.Options)
+
+	userID, voteID, ok := ParseCancel(discordgo.MessageComponentInteractionData{
+		CustomID:      menu.CustomID,
+		ComponentType: discordgo.SelectMenuComponent,
+		Values:        []string{"2"},
+	})
+	assert.True(t, ok)
+	assert.Equal(t, "u", userID)
+	assert.Equal(t, "2", voteID)
+
+	assert.Empty(t, CancelComponents("u", votes[:1]))
+
+	_, _, ok = ParseCancel(discordgo.MessageComponentInteractionData{
+		CustomID: "vote:2", Values: []string{"1"},
+	})
+	assert.False(t, ok)
+}
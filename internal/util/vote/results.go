@@ -0,0 +1,187 @@
+package vote
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Results are the exported results of a vote. Choices are
+// numbered from 1 like in the vote message and voters are
+// only identified by the hashes their ticks are stored under.
+type Results struct {
+	ID          string         `json:"id"`
+	GuildID     string         `json:"guild_id"`
+	Description string         `json:"description"`
+	Mode        Mode           `json:"mode"`
+	Expires     *time.Time     `json:"expires,omitempty"`
+	Closed      *time.Time     `json:"closed,omitempty"`
+	Choices     []ResultChoice `json:"choices"`
+	Rounds      []ResultRound  `json:"rounds,omitempty"`
+	// Winner is the winning choice of ranked votes, it is
+	// 0 when no choice has a majority.
+	Winner   int            `json:"winner,omitempty"`
+	Ticks    []ResultTick   `json:"ticks"`
+	Weights  map[string]int `json:"weights,omitempty"`
+	Roles    []string       `json:"roles,omitempty"`
+	Exported time.Time      `json:"exported"`
+}
+
+// ResultChoice is the total of a possibility.
+type ResultChoice struct {
+	Choice      int    `json:"choice"`
+	Possibility string `json:"possibility"`
+	// Votes is the weighted count of the possibility.
+	Votes  int `json:"votes"`
+	Voters int `json:"voters"`
+}
+
+// ResultRound is an instant-runoff round of a ranked vote.
+type ResultRound struct {
+	Votes      []int `json:"votes"`
+	Eliminated []int `json:"eliminated,omitempty"`
+	Exhausted  int   `json:"exhausted,omitempty"`
+}
+
+// ResultTick is the tick of a single voter.
+type ResultTick struct {
+	Voter   string     `json:"voter"`
+	Time    *time.Time `json:"time,omitempty"`
+	Choices []int      `json:"choices"`
+	Weight  int        `json:"weight"`
+}
+
+// Results returns the results of the vote. Ticks are
+// ordered by the time they were cast.
+func (v *Vote) Results() Results {
+	r := Results{
+		ID:          v.ID,
+		GuildID:     v.GuildID,
+		Description: v.Description,
+		Mode:        v.Mode,
+		Expires:     timePtr(v.Expires),
+		Closed:      timePtr(v.Closed),
+		Choices:     make([]ResultChoice, len(v.Possibilities)),
+		Ticks:       make([]ResultTick, 0, len(v.Ticks)),
+		Roles:       v.Roles,
+		Weights:     v.Weights,
+		Exported:    time.Now(),
+	}
+	if r.Mode == "" {
+		r.Mode = ModeSingle
+	}
+
+	counts := v.Counts()
+	for i, p := range v.Possibilities {
+		r.Choices[i] = ResultChoice{Choice: i + 1, Possibility: p, Votes: counts[i]}
+	}
+
+	for _, t := range v.Ticks {
+		picks := t.Picks()
+		if v.Mode == ModeRanked {
+			picks = picks[:1]
+		}
+		for _, c := range picks {
+			if c >= 0 && c < len(r.Choices) {
+				r.Choices[c].Voters++
+			}
+		}
+
+		rt := ResultTick{
+			Voter:   t.UserID,
+			Time:    timePtr(t.Time),
+			Choices: make([]int, 0, len(t.Picks())),
+			Weight:  t.weight(),
+		}
+		for _, c := range t.Picks() {
+			rt.Choices = append(rt.Choices, c+1)
+		}
+		r.Ticks = append(r.Ticks, rt)
+	}
+
+	// Ticks cast before their time was recorded come first.
+	sort.Slice(r.Ticks, func(i, j int) bool {
+		ti, tj := r.Ticks[i].Time, r.Ticks[j].Time
+		if (ti == nil) != (tj == nil) {
+			return ti == nil
+		}
+		if ti != nil && !ti.Equal(*tj) {
+			return ti.Before(*tj)
+		}
+		return r.Ticks[i].Voter < r.Ticks[j].Voter
+	})
+
+	if v.Mode == ModeRanked {
+		rounds, winner := v.InstantRunoff()
+		for _, round := range rounds {
+			rr := ResultRound{Votes: round.Counts, Exhausted: round.Exhausted}
+			for _, e := range round.Eliminated {
+				rr.Eliminated = append(rr.Eliminated, e+1)
+			}
+			r.Rounds = append(r.Rounds, rr)
+		}
+		r.Winner = winner + 1
+	}
+
+	return r
+}
+
+// WriteJSON writes the results of the vote as JSON to w.
+func (v *Vote) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v.Results())
+}
+
+// WriteCSV writes the results of the vote as CSV to w. The
+// totals of every choice are followed by an empty line and
+// the participation over time, one row per voter in the
+// order the votes were cast.
+func (v *Vote) WriteCSV(w io.Writer) error {
+	r := v.Results()
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"choice", "possibility", "votes", "voters"})
+	for _, c := range r.Choices {
+		cw.Write([]string{
+			strconv.Itoa(c.Choice), c.Possibility, strconv.Itoa(c.Votes), strconv.Itoa(c.Voters),
+		})
+	}
+
+	cw.Write(nil)
+
+	cw.Write([]string{"time", "voter", "choices", "weight", "participants", "total_weight"})
+	var total int
+	for i, t := range r.Ticks {
+		total += t.Weight
+
+		var ts string
+		if t.Time != nil {
+			ts = t.Time.UTC().Format(time.RFC3339)
+		}
+
+		choices := make([]string, len(t.Choices))
+		for j, c := range t.Choices {
+			choices[j] = strconv.Itoa(c)
+		}
+
+		cw.Write([]string{
+			ts, t.Voter, strings.Join(choices, ";"), strconv.Itoa(t.Weight),
+			strconv.Itoa(i + 1), strconv.Itoa(total),
+		})
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,89 @@
+package vote
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newResultsVote() Vote {
+	start := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	v := newTestVote(3)
+	v.Mode = ModeMulti
+	v.Ticks = map[string]*Tick{
+		"hash-b": {UserID: "hash-b", Tick: 0, Choices: []int{0, 2}, Weight: 2, Time: start.Add(time.Minute)},
+		"hash-a": {UserID: "hash-a", Tick: 2, Choices: []int{2}, Time: start},
+		"hash-c": {UserID: "hash-c", Tick: 1},
+	}
+	return v
+}
+
+func TestWriteCSV(t *testing.T) {
+	v := newResultsVote()
+
+	var buf bytes.Buffer
+	require.Nil(t, v.WriteCSV(&buf))
+	assert.Equal(t, ""+
+		"choice,possibility,votes,voters\n"+
+		"1,choice 0,2,1\n"+
+		"2,choice 1,1,1\n"+
+		"3,choice 2,3,2\n"+
+		"\n"+
+		"time,voter,choices,weight,participants,total_weight\n"+
+		",hash-c,2,1,1,1\n"+
+		"2023-05-01T12:00:00Z,hash-a,3,1,2,2\n"+
+		"2023-05-01T12:01:00Z,hash-b,1;3,2,3,4\n",
+		buf.String())
+}
+
+func TestWriteJSONRanked(t *testing.T) {
+	v := newRankedVote(3,
+		[]int{0, 1},
+		[]int{1},
+		[]int{2, 1},
+		[]int{1},
+	)
+
+	var buf bytes.Buffer
+	require.Nil(t, v.WriteJSON(&buf))
+
+	var res Results
+	require.Nil(t, json.Unmarshal(buf.Bytes(), &res))
+	assert.Equal(t, ModeRanked, res.Mode)
+	assert.Equal(t, 2, res.Winner)
+	assert.Equal(t, []ResultRound{
+		{Votes: []int{1, 2, 1}, Eliminated: []int{1, 3}},
+		{Votes: []int{0, 4, 0}},
+	}, res.Rounds)
+	require.Len(t, res.Ticks, 4)
+	assert.Equal(t, []int{1, 2}, res.Ticks[0].Choices)
+	assert.Nil(t, res.Closed)
+}
+
+func TestChart(t *testing.T) {
+	v := newResultsVote()
+
+	for _, kind := range []ChartKind{ChartPie, ChartBar} {
+		img, err := v.Chart(kind)
+		require.Nil(t, err, kind)
+		assert.True(t, bytes.HasPrefix(img, []byte("\x89PNG")), kind)
+	}
+
+	// Bars of equal height must not collapse the axis.
+	v.Ticks = map[string]*Tick{
+		"hash-a": {UserID: "hash-a", Tick: 0},
+		"hash-b": {UserID: "hash-b", Tick: 1},
+		"hash-c": {UserID: "hash-c", Tick: 2},
+	}
+	_, err := v.Chart(ChartBar)
+	assert.Nil(t, err)
+
+	v.Ticks = map[string]*Tick{}
+	_, err = v.Chart(ChartBar)
+	assert.ErrorIs(t, err, ErrNoTicks)
+}
@@ -0,0 +1,146 @@
+package vote
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zekurio/daemon/pkg/arrayutils"
+)
+
+// MaxWeight is the maximum weight of a role.
+const MaxWeight = 1000
+
+// ErrNotEligible is returned when a member without any
+// of the roles of a restricted vote ticks it.
+var ErrNotEligible = errors.New("not eligible")
+
+// weight returns the weight of the tick. Ticks recorded
+// before votes were weighted count once.
+func (t *Tick) weight() int {
+	if t.Weight < 1 {
+		return 1
+	}
+	return t.Weight
+}
+
+// Eligible returns true if a member with the given roles
+// may tick the vote.
+func (v *Vote) Eligible(roleIDs []string) bool {
+	return len(v.Roles) == 0 || arrayutils.ContainsAny(v.Roles, roleIDs...)
+}
+
+// WeightOf returns the weight of a tick of a member with
+// the given roles, which is the weight of their heaviest
+// weighted role or 1 if none of their roles is weighted.
+func (v *Vote) WeightOf(roleIDs []string) int {
+	weight := 0
+	for _, id := range roleIDs {
+		if w := v.Weights[id]; w > weight {
+			weight = w
+		}
+	}
+
+	if weight < 1 {
+		return 1
+	}
+	return weight
+}
+
+// TotalWeight returns the summed weight of all ticks.
+func (v *Vote) TotalWeight() (total int) {
+	for _, t := range v.Ticks {
+		total += t.weight()
+	}
+	return
+}
+
+// FormatRoles lists the roles of which a member needs
+// one to tick the vote as mentions.
+func (v *Vote) FormatRoles() string {
+	mentions := make([]string, len(v.Roles))
+	for i, id := range v.Roles {
+		mentions[i] = "<@&" + id + ">"
+	}
+	return strings.Join(mentions, ", ")
+}
+
+// formatWeights lists the weighted roles, heaviest first.
+func (v *Vote) formatWeights() string {
+	ids := make([]string, 0, len(v.Weights))
+	for id := range v.Weights {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if v.Weights[ids[i]] != v.Weights[ids[j]] {
+			return v.Weights[ids[i]] > v.Weights[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+
+	parts := make([]string, 0, len(ids)+1)
+	for _, id := range ids {
+		parts = append(parts, fmt.Sprintf("<@&%s> `×%d`", id, v.Weights[id]))
+	}
+	parts = append(parts, "others `×1`")
+	return strings.Join(parts, " · ")
+}
+
+// ParseRoles parses a list of role mentions or IDs
+// separated by spaces or commas.
+func ParseRoles(s string) ([]string, error) {
+	var roles []string
+	seen := make(map[string]struct{})
+	for _, field := range splitFields(s) {
+		id, err := parseRoleID(field)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		roles = append(roles, id)
+	}
+	return roles, nil
+}
+
+// ParseWeights parses a list of role mentions or IDs with
+// their weight, i.e. "<@&1>=3 <@&2>=2", separated by spaces
+// or commas.
+func ParseWeights(s string) (map[string]int, error) {
+	weights := make(map[string]int)
+	for _, field := range splitFields(s) {
+		role, weightStr, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, fmt.Errorf("missing weight of role %s", field)
+		}
+		id, err := parseRoleID(role)
+		if err != nil {
+			return nil, err
+		}
+		weight, err := strconv.Atoi(weightStr)
+		if err != nil || weight < 1 || weight > MaxWeight {
+			return nil, fmt.Errorf("weight of role %s must be a number from 1 to %d", role, MaxWeight)
+		}
+		weights[id] = weight
+	}
+	return weights, nil
+}
+
+func splitFields(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n'
+	})
+}
+
+// parseRoleID returns the ID of a role mention or ID.
+func parseRoleID(s string) (string, error) {
+	id := strings.TrimSuffix(strings.TrimPrefix(s, "<@&"), ">")
+	if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+		return "", fmt.Errorf("invalid role %s", s)
+	}
+	return id, nil
+}
@@ -0,0 +1,68 @@
+package vote
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRoles(t *testing.T) {
+	roles, err := ParseRoles("<@&1>, 2 <@&1>")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"1", "2"}, roles)
+
+	roles, err = ParseRoles("")
+	require.Nil(t, err)
+	assert.Empty(t, roles)
+
+	_, err = ParseRoles("<@&1> @Staff")
+	assert.NotNil(t, err)
+}
+
+func TestParseWeights(t *testing.T) {
+	weights, err := ParseWeights("<@&1>=3, 2=2")
+	require.Nil(t, err)
+	assert.Equal(t, map[string]int{"1": 3, "2": 2}, weights)
+
+	for _, s := range []string{"<@&1>", "<@&1>=0", "<@&1>=1001", "<@&1>=x", "Staff=2"} {
+		_, err = ParseWeights(s)
+		assert.NotNil(t, err, s)
+	}
+}
+
+func TestEligibleAndWeights(t *testing.T) {
+	v := newTestVote(3)
+	v.Ticks = map[string]*Tick{}
+	assert.True(t, v.Eligible(nil))
+
+	v.Roles = []string{"1", "2"}
+	v.Weights = map[string]int{"2": 3, "3": 2}
+	assert.False(t, v.Eligible(nil))
+	assert.False(t, v.Eligible([]string{"3"}))
+	assert.True(t, v.Eligible([]string{"3", "2"}))
+
+	assert.Equal(t, 1, v.WeightOf([]string{"1"}))
+	assert.Equal(t, 3, v.WeightOf([]string{"3", "2"}))
+
+	_, err := v.Tick(&discordgo.Member{User: &discordgo.User{ID: "40"}, Roles: []string{"3"}},
+		Selection{Choices: []int{0}})
+	assert.ErrorIs(t, err, ErrNotEligible)
+
+	tick, err := v.Tick(&discordgo.Member{User: &discordgo.User{ID: "40"}, Roles: []string{"2"}},
+		Selection{Choices: []int{0}})
+	require.Nil(t, err)
+	assert.Equal(t, 3, tick.Weight)
+
+	tick, err = v.Tick(&discordgo.Member{User: &discordgo.User{ID: "41"}, Roles: []string{"1"}},
+		Selection{Choices: []int{1}})
+	require.Nil(t, err)
+	assert.Equal(t, 1, tick.Weight)
+
+	// Ticks recorded before weights count once.
+	v.Ticks["legacy"] = &Tick{UserID: "legacy", Tick: 1}
+
+	assert.Equal(t, []int{3, 2, 0}, v.Counts())
+	assert.Equal(t, 5, v.TotalWeight())
+}
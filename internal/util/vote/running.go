@@ -0,0 +1,88 @@
+package vote
+
+import "sync"
+
+var (
+	runningMtx   sync.RWMutex
+	votesRunning = map[string]Vote{}
+
+	locksMtx sync.Mutex
+	locks    = map[string]*voteLock{}
+)
+
+// voteLock serializes the changes of one vote. It is
+// removed from the locks when nobody holds or waits for
+// it anymore.
+type voteLock struct {
+	sync.Mutex
+	refs int
+}
+
+// GetRunning returns the running vote with the given ID.
+func GetRunning(id string) (v Vote, ok bool) {
+	runningMtx.RLock()
+	defer runningMtx.RUnlock()
+
+	v, ok = votesRunning[id]
+	return v, ok
+}
+
+// SetRunning adds the vote to the running votes or
+// replaces the running vote with the same ID.
+func SetRunning(v Vote) {
+	runningMtx.Lock()
+	defer runningMtx.Unlock()
+
+	votesRunning[v.ID] = v
+}
+
+// RemoveRunning removes the vote with the given ID from
+// the running votes.
+func RemoveRunning(id string) {
+	runningMtx.Lock()
+	defer runningMtx.Unlock()
+
+	delete(votesRunning, id)
+}
+
+// RunningVotes returns all running votes.
+func RunningVotes() []Vote {
+	runningMtx.RLock()
+	defer runningMtx.RUnlock()
+
+	votes := make([]Vote, 0, len(votesRunning))
+	for _, v := range votesRunning {
+		votes = append(votes, v)
+	}
+	return votes
+}
+
+// Lock locks the vote with the given ID and returns the
+// function to unlock it. It must be held while the ticks
+// of a running vote are changed or read, as the copies of
+// a vote share them. The vote has to be read again with
+// GetRunning after locking, as it might have been closed
+// in the meantime.
+func Lock(id string) (unlock func()) {
+	locksMtx.Lock()
+	l, ok := locks[id]
+	if !ok {
+		l = &voteLock{}
+		locks[id] = l
+	}
+	l.refs++
+	locksMtx.Unlock()
+
+	l.Lock()
+
+	return func() {
+		l.Unlock()
+
+		locksMtx.Lock()
+		defer locksMtx.Unlock()
+
+		if l.refs--; l.refs == 0 {
+			delete(locks, id)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package vote
+
+import (
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunningParallelTicks(t *testing.T) {
+	const members = 50
+
+	v := Vote{
+		ID:            "1100000000000000001",
+		GuildID:       "g",
+		MsgID:         "m",
+		Possibilities: []string{"a", "b"},
+		Ticks:         map[string]*Tick{},
+	}
+	SetRunning(v)
+	defer RemoveRunning(v.ID)
+
+	var (
+		wg    sync.WaitGroup
+		start = make(chan struct{})
+	)
+	for i := 0; i < members; i++ {
+		wg.Add(2)
+
+		go func(i int) {
+			defer wg.Done()
+			<-start
+
+			unlock := Lock(v.ID)
+			defer unlock()
+
+			running, ok := GetRunning(v.ID)
+			if !assert.True(t, ok) {
+				return
+			}
+			// Yield, so that the ticks interleave when the
+			// vote is not locked.
+			runtime.Gosched()
+
+			m := &discordgo.Member{User: &discordgo.User{ID: strconv.Itoa(1100000000000001000 + i)}}
+			_, err := running.Tick(m, Selection{VoteID: v.ID, Choices: []int{i % 2}})
+			assert.Nil(t, err)
+			SetRunning(running)
+		}(i)
+
+		// Listing the running votes does not need the lock
+		// of the vote.
+		go func() {
+			defer wg.Done()
+			<-start
+			_ = RunningVotes()
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	running, ok := GetRunning(v.ID)
+	require.True(t, ok)
+	assert.Len(t, running.Ticks, members)
+
+	locksMtx.Lock()
+	defer locksMtx.Unlock()
+	assert.Empty(t, locks)
+}
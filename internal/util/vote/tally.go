@@ -0,0 +1,105 @@
+package vote
+
+// Round is one round of an instant-runoff tally.
+type Round struct {
+	// Counts are the weighted ballots ranking each
+	// possibility highest among the remaining ones.
+	// Eliminated possibilities are counted as 0.
+	Counts []int
+	// Eliminated are the possibilities eliminated at the
+	// end of the round.
+	Eliminated []int
+	// Exhausted is the weight of the ballots ranking none
+	// of the remaining possibilities.
+	Exhausted int
+}
+
+// Counts returns the weighted ticks of each possibility.
+// Ticks of multi votes count for every chosen possibility,
+// ranked votes only count the first choice.
+func (v *Vote) Counts() []int {
+	counts := make([]int, len(v.Possibilities))
+	for _, t := range v.Ticks {
+		choices := t.Picks()
+		if v.Mode == ModeRanked {
+			choices = choices[:1]
+		}
+		for _, c := range choices {
+			if c >= 0 && c < len(counts) {
+				counts[c] += t.weight()
+			}
+		}
+	}
+	return counts
+}
+
+// InstantRunoff tallies a ranked vote. Every round, each
+// ballot counts with its weight for its highest ranked
+// remaining choice. A possibility with a majority of the
+// counted weight wins, otherwise the possibilities with the
+// least weight are eliminated. When all remaining
+// possibilities tie, there is no winner and winner is -1,
+// as it is without ballots.
+func (v *Vote) InstantRunoff() (rounds []Round, winner int) {
+	eliminated := make([]bool, len(v.Possibilities))
+	remaining := len(v.Possibilities)
+
+	for remaining > 0 {
+		round := Round{Counts: make([]int, len(v.Possibilities))}
+		total := 0
+		for _, t := range v.Ticks {
+			choice := -1
+			for _, c := range t.Picks() {
+				if c >= 0 && c < len(eliminated) && !eliminated[c] {
+					choice = c
+					break
+				}
+			}
+			if choice < 0 {
+				round.Exhausted += t.weight()
+				continue
+			}
+			round.Counts[choice] += t.weight()
+			total += t.weight()
+		}
+
+		if total == 0 {
+			rounds = append(rounds, round)
+			return rounds, -1
+		}
+
+		fewest := -1
+		for i, n := range round.Counts {
+			if eliminated[i] {
+				continue
+			}
+			if 2*n > total {
+				rounds = append(rounds, round)
+				return rounds, i
+			}
+			if fewest < 0 || n < fewest {
+				fewest = n
+			}
+		}
+
+		for i, n := range round.Counts {
+			if !eliminated[i] && n == fewest {
+				round.Eliminated = append(round.Eliminated, i)
+			}
+		}
+		rounds = append(rounds, round)
+
+		// The last possibilities tie, eliminating them would
+		// leave no winner behind.
+		if len(round.Eliminated) == remaining {
+			return rounds, -1
+		}
+
+		for _, i := range round.Eliminated {
+			eliminated[i] = true
+		}
+		remaining -= len(round.Eliminated)
+	}
+
+	return rounds, -1
+}
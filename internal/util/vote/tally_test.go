@@ -0,0 +1,121 @@
+package vote
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newRankedVote(possibilities int, ballots ...[]int) Vote {
+	v := newTestVote(possibilities)
+	v.Mode = ModeRanked
+	v.Ticks = make(map[string]*Tick, len(ballots))
+	for i, b := range ballots {
+		id := fmt.Sprintf("hash-%d", i)
+		v.Ticks[id] = &Tick{UserID: id, Tick: b[0], Choices: b}
+	}
+	return v
+}
+
+func TestCounts(t *testing.T) {
+	v := newTestVote(3)
+	v.Ticks = map[string]*Tick{
+		"a": {Tick: 0},
+		"b": {Tick: 2},
+		"c": {Tick: 2},
+	}
+	assert.Equal(t, []int{1, 0, 2}, v.Counts())
+
+	v.Mode = ModeMulti
+	v.Ticks["a"].Choices = []int{0, 1, 2}
+	assert.Equal(t, []int{1, 1, 3}, v.Counts())
+
+	v.Mode = ModeRanked
+	v.Ticks["a"].Choices = []int{-1, 1, 2}
+	assert.Equal(t, []int{0, 1, 2}, v.Counts())
+}
+
+func TestInstantRunoff(t *testing.T) {
+	v := newRankedVote(3,
+		[]int{0, 1},
+		[]int{0, 1},
+		[]int{1, 0},
+		[]int{1, 2},
+		[]int{2, 1},
+	)
+
+	rounds, winner := v.InstantRunoff()
+	assert.Equal(t, 1, winner)
+	assert.Equal(t, []Round{
+		{Counts: []int{2, 2, 1}, Eliminated: []int{2}},
+		{Counts: []int{2, 3, 0}},
+	}, rounds)
+}
+
+func TestInstantRunoffMajority(t *testing.T) {
+	v := newRankedVote(3,
+		[]int{2},
+		[]int{2, 0},
+		[]int{1},
+	)
+
+	rounds, winner := v.InstantRunoff()
+	assert.Equal(t, 2, winner)
+	assert.Len(t, rounds, 1)
+}
+
+func TestInstantRunoffExhausted(t *testing.T) {
+	v := newRankedVote(4,
+		[]int{0},
+		[]int{0},
+		[]int{1},
+		[]int{1},
+		[]int{2},
+		[]int{3, 0},
+	)
+
+	rounds, winner := v.InstantRunoff()
+	assert.Equal(t, 0, winner)
+	assert.Equal(t, []Round{
+		{Counts: []int{2, 2, 1, 1}, Eliminated: []int{2, 3}},
+		{Counts: []int{3, 2, 0, 0}, Exhausted: 1},
+	}, rounds)
+}
+
+func TestInstantRunoffTie(t *testing.T) {
+	v := newRankedVote(3,
+		[]int{0, 2},
+		[]int{1, 2},
+	)
+
+	rounds, winner := v.InstantRunoff()
+	assert.Equal(t, -1, winner)
+	assert.Equal(t, []Round{
+		{Counts: []int{1, 1, 0}, Eliminated: []int{2}},
+		{Counts: []int{1, 1, 0}, Eliminated: []int{0, 1}},
+	}, rounds)
+
+	v = newRankedVote(3)
+	rounds, winner = v.InstantRunoff()
+	assert.Equal(t, -1, winner)
+	assert.Len(t, rounds, 1)
+}
+
+func TestInstantRunoffWeighted(t *testing.T) {
+	v := newRankedVote(3,
+		[]int{0, 1},
+		[]int{1},
+		[]int{1},
+		[]int{2, 0},
+	)
+	v.Ticks["hash-0"].Weight = 3
+	v.Ticks["hash-3"].Weight = 2
+
+	rounds, winner := v.InstantRunoff()
+	assert.Equal(t, 0, winner)
+	assert.Equal(t, []Round{
+		{Counts: []int{3, 2, 2}, Eliminated: []int{1, 2}},
+		{Counts: []int{5, 0, 0}, Exhausted: 2},
+	}, rounds)
+}
@@ -4,13 +4,14 @@ import (
 	"bytes"
 	"encoding/base64"
 	"encoding/gob"
+	"errors"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
-	"github.com/wcharczuk/go-chart"
-	"github.com/wcharczuk/go-chart/drawing"
 	"github.com/zekurio/daemon/internal/util/static"
 	"github.com/zekurio/daemon/pkg/discordutils"
 	"github.com/zekurio/daemon/pkg/hashutils"
@@ -28,14 +29,88 @@ type Vote struct {
 	Expires       time.Time
 	Possibilities []string
 	Ticks         map[string]*Tick
+	// Mode is how members tick the vote, votes created
+	// before modes were introduced have no mode and are
+	// single choice votes.
+	Mode Mode
+	// MaxChoices limits the choices a member can pick in
+	// multi votes or rank in ranked votes, 0 means all.
+	MaxChoices int
+	// Roles are the IDs of the roles of which a member needs
+	// one to tick the vote, everyone may tick if it is empty.
+	Roles []string
+	// Weights are the weights of ticks by role ID.
+	Weights map[string]int
+	// Closed is when the vote was closed or expired, it
+	// is zero while the vote is running.
+	Closed time.Time
+	// Starts is when a scheduled vote is posted. Until
+	// then, the vote is pending and has no message.
+	Starts time.Time
 }
 
 // Tick is a struct for a tick
 type Tick struct {
 	UserID string
-	Tick   int
+	// Tick is the chosen possibility of single choice votes
+	// and the first choice of multi and ranked votes.
+	Tick int
+	// Choices are the chosen possibilities of multi votes
+	// or the ranking of ranked votes, where -1 marks ranks
+	// without choice. It is empty for single choice votes.
+	Choices []int
+	// Weight is what the tick counts for, the weight of the
+	// heaviest weighted role of the member when ticking.
+	Weight int
+	// Time is when the member first ticked the vote.
+	Time time.Time
 }
 
+// Picks returns the chosen possibilities of the tick,
+// ordered by rank for ranked votes.
+func (t *Tick) Picks() []int {
+	if len(t.Choices) == 0 {
+		return []int{t.Tick}
+	}
+
+	picks := make([]int, 0, len(t.Choices))
+	for _, c := range t.Choices {
+		if c >= 0 {
+			picks = append(picks, c)
+		}
+	}
+	return picks
+}
+
+// Mode is a type for the way a vote is ticked
+type Mode string
+
+const (
+	// ModeSingle votes let members pick one possibility.
+	ModeSingle Mode = "single"
+	// ModeMulti votes let members pick up to MaxChoices
+	// possibilities.
+	ModeMulti Mode = "multi"
+	// ModeRanked votes let members rank up to MaxChoices
+	// possibilities and are tallied by instant-runoff.
+	ModeRanked Mode = "ranked"
+)
+
+// Selection is a choice made with a component of
+// the vote message.
+type Selection struct {
+	VoteID string
+	// Rank is the position chosen with a select menu of a
+	// ranked vote starting at 1, it is 0 for other votes.
+	Rank int
+	// Choices are the chosen possibilities.
+	Choices []int
+}
+
+// ErrInvalidSelection is returned when a selection does
+// not fit the possibilities or the mode of the vote.
+var ErrInvalidSelection = errors.New("invalid selection")
+
 // State is a type for the state of a vote
 type State int
 
@@ -46,10 +121,38 @@ const (
 	StateExpired
 )
 
-// VotesRunning is a map of all running votes
-var VotesRunning = map[string]Vote{}
-
-var Emotes = strings.Fields("\u0031\u20E3 \u0032\u20E3 \u0033\u20E3 \u0034\u20E3 \u0035\u20E3 \u0036\u20E3 \u0037\u20E3 \u0038\u20E3 \u0039\u20E3 \u0030\u20E3")
+const (
+	// MaxPossibilities is the maximum number of choices of
+	// a vote, which is the option limit of select menus.
+	MaxPossibilities = 25
+
+	// MsgIDPosting is stored as the message ID of a scheduled
+	// vote while it is posted, so that it is posted only once.
+	MsgIDPosting = "posting"
+
+	// maxButtons is the number of choices up to which a
+	// button is shown per choice instead of a select menu.
+	maxButtons = 10
+	// buttonsPerRow is the maximum number of buttons in
+	// one row of message components.
+	buttonsPerRow = 5
+	// maxRanks is the maximum number of ranks of ranked
+	// votes, as there is a select menu per rank and a
+	// message has at most 5 rows of components.
+	maxRanks = 5
+	// maxFieldValue is the maximum length of the value of
+	// an embed field.
+	maxFieldValue = 1024
+
+	maxButtonLabel = 80
+	maxOptionLabel = 100
+
+	// customIDPrefix prefixes the custom IDs of the message
+	// components of votes. Buttons append the ID of the vote
+	// and the index of the choice, select menus only the ID
+	// of the vote.
+	customIDPrefix = "vote:"
+)
 
 // Unmarshal decodes a vote from a string
 func Unmarshal(data string) (v Vote, err error) {
@@ -91,10 +194,19 @@ func (v *Vote) AsEmbed(s *discordgo.Session, voteState ...State) (*discordgo.Mes
 		state = voteState[0]
 	}
 
-	creator, err := s.User(v.CreatorID)
-	if err != nil {
-		return nil, err
+	// Votes of users who deleted their data have no creator.
+	author := &discordgo.MessageEmbedAuthor{Name: "Deleted user"}
+	if v.CreatorID != "" {
+		creator, err := s.User(v.CreatorID)
+		if err != nil {
+			return nil, err
+		}
+		author = &discordgo.MessageEmbedAuthor{
+			IconURL: creator.AvatarURL("16x16"),
+			Name:    creator.Username + "#" + creator.Discriminator,
+		}
 	}
+
 	title := "Open Vote"
 	color := static.ColorDefault
 	expires := fmt.Sprintf("Expires <t:%d:R>", v.Expires.Unix())
@@ -114,82 +226,86 @@ func (v *Vote) AsEmbed(s *discordgo.Session, voteState ...State) (*discordgo.Mes
 		expires = fmt.Sprintf("Expired <t:%d:R>", v.Expires.Unix())
 	}
 
-	totalTicks := make(map[int]int)
-	for _, t := range v.Ticks {
-		if _, ok := totalTicks[t.Tick]; !ok {
-			totalTicks[t.Tick] = 1
-		} else {
-			totalTicks[t.Tick]++
-		}
-	}
+	counts := v.Counts()
 
+	// Single choice votes show the share of all ticks, multi
+	// votes the share of members picking the possibility.
+	total := v.TotalWeight()
 	description := v.Description + "\n\n"
 	for i, p := range v.Possibilities {
-		description += fmt.Sprintf("%s    %s  -  `%d`\n", Emotes[i], p, totalTicks[i])
+		description += fmt.Sprintf("**%d.**    %s  -  `%d`", i+1, p, counts[i])
+		if total > 0 {
+			description += fmt.Sprintf("  *(%d%%)*", 100*counts[i]/total)
+		}
+		description += "\n"
 	}
 
 	emb := &discordgo.MessageEmbed{
 		Color:       color,
 		Title:       title,
 		Description: description,
-		Author: &discordgo.MessageEmbedAuthor{
-			IconURL: creator.AvatarURL("16x16"),
-			Name:    creator.Username + "#" + creator.Discriminator,
-		},
-		Fields: []*discordgo.MessageEmbedField{
-			{
-				Name:   expires,
-				Value:  "",
-				Inline: false,
-			},
-			{
-				Name:   fmt.Sprintf("ID `%s`", v.ID),
-				Value:  "",
-				Inline: false,
-			},
-		},
+		Author:      author,
 	}
 
-	if len(totalTicks) > 0 && (state == StateClosed || state == StateExpired) {
-
-		values := make([]chart.Value, len(v.Possibilities))
+	switch v.Mode {
+	case ModeMulti:
+		emb.Fields = append(emb.Fields, &discordgo.MessageEmbedField{
+			Name: fmt.Sprintf("Multiple choice, up to %d per member", v.maxChoices()),
+		})
+	case ModeRanked:
+		rounds, winner := v.InstantRunoff()
+		emb.Fields = append(emb.Fields, &discordgo.MessageEmbedField{
+			Name:  fmt.Sprintf("Ranked choice, up to %d ranks per member", v.maxChoices()),
+			Value: v.formatRounds(rounds, winner),
+		})
+	}
 
-		for i, p := range v.Possibilities {
-			values[i] = chart.Value{
-				Value: float64(totalTicks[i]),
-				Label: p,
-			}
-		}
+	if len(v.Roles) != 0 {
+		emb.Fields = append(emb.Fields, &discordgo.MessageEmbedField{
+			Name:  "Restricted to",
+			Value: v.FormatRoles(),
+		})
+	}
+	if len(v.Weights) != 0 {
+		emb.Fields = append(emb.Fields, &discordgo.MessageEmbedField{
+			Name:  "Weighted by role",
+			Value: v.formatWeights(),
+		})
+	}
 
-		pie := chart.PieChart{
-			Width:  512,
-			Height: 512,
-			Values: values,
-			Background: chart.Style{
-				FillColor: drawing.ColorTransparent,
-			},
-		}
+	emb.Fields = append(emb.Fields,
+		&discordgo.MessageEmbedField{
+			Name:   expires,
+			Value:  "",
+			Inline: false,
+		},
+		&discordgo.MessageEmbedField{
+			Name:   fmt.Sprintf("ID `%s`", v.ID),
+			Value:  "",
+			Inline: false,
+		})
 
-		imgData := []byte{}
-		buff := bytes.NewBuffer(imgData)
-		err = pie.Render(chart.PNG, buff)
-		if err != nil {
+	if len(v.Ticks) > 0 && (state == StateClosed || state == StateExpired) {
+		img, err := v.Chart(ChartPie)
+		if err != nil && err != ErrNoTicks {
 			return nil, err
 		}
 
-		_, err := s.ChannelMessageSendComplex(v.ChannelID, &discordgo.MessageSend{
-			File: &discordgo.File{
-				Name:   fmt.Sprintf("vote_chart_%s.png", v.ID),
-				Reader: buff,
-			},
-			Reference: &discordgo.MessageReference{
-				MessageID: v.MsgID,
-				ChannelID: v.ChannelID,
-				GuildID:   v.GuildID,
-			},
-		})
-		if err != nil {
-			return nil, err
+		if img != nil {
+			_, err = s.ChannelMessageSendComplex(v.ChannelID, &discordgo.MessageSend{
+				File: &discordgo.File{
+					Name:   fmt.Sprintf("vote_chart_%s.png", v.ID),
+					Reader: bytes.NewReader(img),
+				},
+				Reference: &discordgo.MessageReference{
+					MessageID: v.MsgID,
+					ChannelID: v.ChannelID,
+					GuildID:   v.GuildID,
+				},
+			})
+			if err != nil {
+				return nil, err
+			}
 		}
 	}
 
@@ -202,6 +318,88 @@ func (v *Vote) AsEmbed(s *discordgo.Session, voteState ...State) (*discordgo.Mes
 	return emb, nil
 }
 
+// formatRounds lists the counts of the remaining
+// possibilities of every instant-runoff round.
+func (v *Vote) formatRounds(rounds []Round, winner int) string {
+	if len(v.Ticks) == 0 {
+		return "*No rankings yet.*"
+	}
+
+	var (
+		res        strings.Builder
+		eliminated = make([]bool, len(v.Possibilities))
+	)
+
+	for n, round := range rounds {
+		parts := make([]string, 0, len(v.Possibilities))
+		for i, p := range v.Possibilities {
+			if !eliminated[i] {
+				parts = append(parts, fmt.Sprintf("%s `%d`", p, round.Counts[i]))
+			}
+		}
+		line := fmt.Sprintf("**Round %d:** %s", n+1, strings.Join(parts, " · "))
+		if round.Exhausted > 0 {
+			line += fmt.Sprintf(" · *%d exhausted*", round.Exhausted)
+		}
+
+		if len(round.Eliminated) != 0 && n < len(rounds)-1 {
+			names := make([]string, len(round.Eliminated))
+			for i, e := range round.Eliminated {
+				names[i] = v.Possibilities[e]
+				eliminated[e] = true
+			}
+			line += "\n*Eliminated " + strings.Join(names, ", ") + "*"
+		}
+
+		if res.Len()+len(line) > maxFieldValue-50 {
+			res.WriteString("...\n")
+			break
+		}
+		res.WriteString(line + "\n")
+	}
+
+	if winner >= 0 {
+		res.WriteString(fmt.Sprintf("**Winner:** %s", v.Possibilities[winner]))
+	} else {
+		res.WriteString("**No majority**, the remaining choices tie.")
+	}
+
+	return res.String()
+}
+
+// Pending returns true if the vote is scheduled and has
+// not been posted yet.
+func (v *Vote) Pending() bool {
+	return v.MsgID == "" && !v.Starts.IsZero()
+}
+
+// Posting returns true if the scheduled vote has been
+// claimed to be posted, but its message has not been
+// stored yet.
+func (v *Vote) Posting() bool {
+	return v.MsgID == MsgIDPosting
+}
+
+// Post sends the vote message with the components to tick
+// it into the channel of the vote and sets its message ID.
+func (v *Vote) Post(s *discordgo.Session) (*discordgo.Message, error) {
+	emb, err := v.AsEmbed(s)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := s.ChannelMessageSendComplex(v.ChannelID, &discordgo.MessageSend{
+		Embeds:     []*discordgo.MessageEmbed{emb},
+		Components: v.Components(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	v.MsgID = msg.ID
+	return msg, nil
+}
+
 // AsField returns a vode as a discordgo.MessageEmbedField
 func (v *Vote) AsField() *discordgo.MessageEmbedField {
 	shortenedDescription := v.Description
@@ -214,6 +412,14 @@ func (v *Vote) AsField() *discordgo.MessageEmbedField {
 		expiresTxt = fmt.Sprintf("**Expires <t:%d:R>**", v.Expires.Unix())
 	}
 
+	if v.Pending() {
+		return &discordgo.MessageEmbedField{
+			Name: fmt.Sprintf("ID `%s`", v.ID),
+			Value: fmt.Sprintf("**Description:** %s\n**Starts <t:%d:R>** in <#%s>\n%s",
+				shortenedDescription, v.Starts.Unix(), v.ChannelID, expiresTxt),
+		}
+	}
+
 	return &discordgo.MessageEmbedField{
 		Name: fmt.Sprintf("ID `%s`", v.ID),
 		Value: fmt.Sprintf("**Description:** %s\n%s\n`%d votes`\n[*Jump to message*](%s)",
@@ -224,65 +430,317 @@ func (v *Vote) AsField() *discordgo.MessageEmbedField {
 	}
 }
 
-// AddReactions adds the vote reactions to the vote message
-func (v *Vote) AddReactions(s *discordgo.Session) error {
-	for i := 0; i < len(v.Possibilities); i++ {
-		err := s.MessageReactionAdd(v.ChannelID, v.MsgID, Emotes[i])
-		if err != nil {
-			return err
+// Components returns the message components to tick the
+// vote. Single choice votes have a button per choice or a
+// select menu when there are too many choices for buttons,
+// multi votes a select menu for multiple choices and ranked
+// votes a select menu per rank.
+func (v *Vote) Components() []discordgo.MessageComponent {
+	switch {
+	case v.Mode == ModeMulti:
+		return []discordgo.MessageComponent{
+			v.selectMenu(customIDPrefix+v.ID,
+				fmt.Sprintf("Select up to %d choices", v.maxChoices()), v.maxChoices()),
+		}
+	case v.Mode == ModeRanked:
+		rows := make([]discordgo.MessageComponent, v.maxChoices())
+		for r := range rows {
+			rows[r] = v.selectMenu(fmt.Sprintf("%s%s:%d", customIDPrefix, v.ID, r+1),
+				fmt.Sprintf("Choice %d", r+1), 1)
+		}
+		return rows
+	case len(v.Possibilities) > maxButtons:
+		return []discordgo.MessageComponent{
+			v.selectMenu(customIDPrefix+v.ID, "Select your choice", 1),
+		}
+	}
+
+	var rows []discordgo.MessageComponent
+	for start := 0; start < len(v.Possibilities); start += buttonsPerRow {
+		end := start + buttonsPerRow
+		if end > len(v.Possibilities) {
+			end = len(v.Possibilities)
+		}
+
+		buttons := make([]discordgo.MessageComponent, 0, end-start)
+		for i := start; i < end; i++ {
+			buttons = append(buttons, discordgo.Button{
+				CustomID: fmt.Sprintf("%s%s:%d", customIDPrefix, v.ID, i),
+				Label:    truncate(fmt.Sprintf("%d. %s", i+1, v.Possibilities[i]), maxButtonLabel),
+				Style:    discordgo.SecondaryButton,
+			})
 		}
+		rows = append(rows, discordgo.ActionsRow{Components: buttons})
 	}
-	return nil
+
+	return rows
 }
 
-// Tick maps the specificed tick from a user to a vote
-func (v *Vote) Tick(s *discordgo.Session, userID string, tick int) (err error) {
-	if userID, err = hashutils.HashSnowflake(userID, []byte(v.ID)); err != nil {
-		return
+// selectMenu returns a row with a select menu of all
+// possibilities allowing up to maxValues choices.
+func (v *Vote) selectMenu(customID, placeholder string, maxValues int) discordgo.ActionsRow {
+	options := make([]discordgo.SelectMenuOption, len(v.Possibilities))
+	for i, p := range v.Possibilities {
+		options[i] = discordgo.SelectMenuOption{
+			Label: truncate(fmt.Sprintf("%d. %s", i+1, p), maxOptionLabel),
+			Value: strconv.Itoa(i),
+		}
 	}
 
-	if t, ok := v.Ticks[userID]; ok {
-		t.Tick = tick
-	} else {
-		v.Ticks[userID] = &Tick{
-			UserID: userID,
-			Tick:   tick,
+	return discordgo.ActionsRow{
+		Components: []discordgo.MessageComponent{
+			discordgo.SelectMenu{
+				CustomID:    customID,
+				Placeholder: placeholder,
+				MaxValues:   maxValues,
+				Options:     options,
+			},
+		},
+	}
+}
+
+// maxChoices returns the number of possibilities a member
+// can pick in multi votes or rank in ranked votes.
+func (v *Vote) maxChoices() int {
+	n := len(v.Possibilities)
+	if v.MaxChoices > 0 && v.MaxChoices < n {
+		n = v.MaxChoices
+	}
+	if v.Mode == ModeRanked && n > maxRanks {
+		n = maxRanks
+	}
+	return n
+}
+
+// ParseComponent returns the selection made with the given
+// component interaction. ok is false when the component does
+// not belong to a vote.
+//
+// Buttons carry the ID of the vote and the chosen possibility
+// in their custom ID, select menus the ID of the vote and,
+// for ranked votes, the rank.
+func ParseComponent(data discordgo.MessageComponentInteractionData) (sel Selection, ok bool) {
+	rest, ok := strings.CutPrefix(data.CustomID, customIDPrefix)
+	if !ok {
+		return Selection{}, false
+	}
+
+	values := data.Values
+	voteID, suffix, hasSuffix := strings.Cut(rest, ":")
+	if data.ComponentType == discordgo.SelectMenuComponent {
+		if hasSuffix {
+			if sel.Rank, _ = strconv.Atoi(suffix); sel.Rank < 1 {
+				return Selection{}, false
+			}
 		}
+	} else if hasSuffix {
+		values = []string{suffix}
+	} else {
+		return Selection{}, false
 	}
 
-	emb, err := v.AsEmbed(s)
-	if err != nil {
-		return
+	if voteID == "" || len(values) == 0 {
+		return Selection{}, false
 	}
 
-	_, err = s.ChannelMessageEditEmbed(v.ChannelID, v.MsgID, emb)
-	return
+	sel.VoteID = voteID
+	sel.Choices = make([]int, len(values))
+	for i, value := range values {
+		c, err := strconv.Atoi(value)
+		if err != nil || c < 0 {
+			return Selection{}, false
+		}
+		sel.Choices[i] = c
+	}
+
+	return sel, true
 }
 
-// SetExpire sets the expiration time of the vote and updates the message
-func (v *Vote) SetExpire(s *discordgo.Session, d time.Duration) error {
-	v.Expires = time.Now().Add(d)
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-3]) + "..."
+	}
+	return s
+}
 
+// Update renders the open vote into its message again.
+func (v *Vote) Update(s *discordgo.Session) error {
 	emb, err := v.AsEmbed(s)
 	if err != nil {
 		return err
 	}
-	_, err = s.ChannelMessageEditEmbed(v.ChannelID, v.MsgID, emb)
 
+	// The components are always sent along, as editing a
+	// message without them removes them.
+	_, err = s.ChannelMessageEditComplex(&discordgo.MessageEdit{
+		ID:         v.MsgID,
+		Channel:    v.ChannelID,
+		Embeds:     []*discordgo.MessageEmbed{emb},
+		Components: v.Components(),
+	})
 	return err
 }
 
+// MigrateReactions replaces the reactions of votes created
+// before votes were ticked with message components. It
+// returns false when the message has components already.
+func (v *Vote) MigrateReactions(s *discordgo.Session) (bool, error) {
+	msg, err := s.ChannelMessage(v.ChannelID, v.MsgID)
+	if err != nil {
+		return false, err
+	}
+
+	if len(msg.Components) != 0 {
+		return false, nil
+	}
+
+	if err = v.Update(s); err != nil {
+		return false, err
+	}
+
+	return true, s.MessageReactionsRemoveAll(v.ChannelID, v.MsgID)
+}
+
+// Tick records the selection of a member and returns the
+// resulting tick of the member. The vote message has to be
+// updated afterwards.
+func (v *Vote) Tick(m *discordgo.Member, sel Selection) (*Tick, error) {
+	if !v.Eligible(m.Roles) {
+		return nil, ErrNotEligible
+	}
+
+	hash, err := HashUserID(v.ID, m.User.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := v.apply(hash, sel)
+	if err != nil {
+		return nil, err
+	}
+
+	t.Weight = v.WeightOf(m.Roles)
+	if t.Time.IsZero() {
+		t.Time = time.Now()
+	}
+	return t, nil
+}
+
+// apply records the selection under the given hash of
+// the user.
+func (v *Vote) apply(hash string, sel Selection) (*Tick, error) {
+	for _, c := range sel.Choices {
+		if c < 0 || c >= len(v.Possibilities) {
+			return nil, ErrInvalidSelection
+		}
+	}
+
+	t, ok := v.Ticks[hash]
+	if !ok {
+		t = &Tick{UserID: hash}
+	}
+
+	switch v.Mode {
+	case ModeMulti:
+		if len(sel.Choices) == 0 || len(sel.Choices) > v.maxChoices() {
+			return nil, ErrInvalidSelection
+		}
+		choices := append([]int{}, sel.Choices...)
+		sort.Ints(choices)
+		for i := 1; i < len(choices); i++ {
+			if choices[i] == choices[i-1] {
+				return nil, ErrInvalidSelection
+			}
+		}
+		t.Choices = choices
+
+	case ModeRanked:
+		if len(sel.Choices) != 1 || sel.Rank < 1 || sel.Rank > v.maxChoices() {
+			return nil, ErrInvalidSelection
+		}
+
+		// A possibility can only be ranked once, so it is
+		// moved when it was ranked before.
+		ranking := make([]int, len(t.Choices))
+		copy(ranking, t.Choices)
+		for len(ranking) < sel.Rank {
+			ranking = append(ranking, -1)
+		}
+		for i, c := range ranking {
+			if c == sel.Choices[0] {
+				ranking[i] = -1
+			}
+		}
+		ranking[sel.Rank-1] = sel.Choices[0]
+		for ranking[len(ranking)-1] < 0 {
+			ranking = ranking[:len(ranking)-1]
+		}
+		t.Choices = ranking
+
+	default:
+		if len(sel.Choices) != 1 {
+			return nil, ErrInvalidSelection
+		}
+		t.Choices = nil
+	}
+
+	if len(t.Choices) == 0 {
+		t.Tick = sel.Choices[0]
+	} else {
+		t.Tick = t.Picks()[0]
+	}
+
+	v.Ticks[hash] = t
+	return t, nil
+}
+
+// FormatTick lists the possibilities picked with the
+// tick, as a ranking for ranked votes.
+func (v *Vote) FormatTick(t *Tick) string {
+	if v.Mode == ModeRanked {
+		lines := make([]string, 0, len(t.Choices))
+		for r, c := range t.Choices {
+			if c >= 0 {
+				lines = append(lines, fmt.Sprintf("%d. **%s**", r+1, v.Possibilities[c]))
+			}
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	picks := t.Picks()
+	names := make([]string, len(picks))
+	for i, c := range picks {
+		names[i] = "**" + v.Possibilities[c] + "**"
+	}
+	return strings.Join(names, ", ")
+}
+
+// HashUserID returns the hash under which the tick of the
+// given user is stored for the vote with the given ID.
+func HashUserID(voteID, userID string) (string, error) {
+	return hashutils.HashSnowflake(userID, []byte(voteID))
+}
+
+// SetExpire sets the expiration time of the vote and updates the message
+func (v *Vote) SetExpire(s *discordgo.Session, d time.Duration) error {
+	v.Expires = time.Now().Add(d)
+
+	return v.Update(s)
+}
+
 // Close closes the vote and removes it from the running votes
 func (v *Vote) Close(s *discordgo.Session, voteState State) error {
-	delete(VotesRunning, v.ID)
+	RemoveRunning(v.ID)
+	v.Closed = time.Now()
 	emb, err := v.AsEmbed(s, voteState)
 	if err != nil {
 		return err
 	}
-	_, err = s.ChannelMessageEditEmbed(v.ChannelID, v.MsgID, emb)
-	if err != nil {
-		return err
-	}
-	err = s.MessageReactionsRemoveAll(v.ChannelID, v.MsgID)
+	_, err = s.ChannelMessageEditComplex(&discordgo.MessageEdit{
+		ID:         v.MsgID,
+		Channel:    v.ChannelID,
+		Embeds:     []*discordgo.MessageEmbed{emb},
+		Components: []discordgo.MessageComponent{},
+	})
 	return err
 }
@@ -0,0 +1,155 @@
+package vote
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestVote(possibilities int) Vote {
+	v := Vote{ID: "1100000000000000001"}
+	for i := 0; i < possibilities; i++ {
+		v.Possibilities = append(v.Possibilities, fmt.Sprintf("choice %d", i))
+	}
+	return v
+}
+
+func TestComponentsButtons(t *testing.T) {
+	v := newTestVote(7)
+
+	rows := v.Components()
+	require.Len(t, rows, 2)
+	assert.Len(t, rows[0].(discordgo.ActionsRow).Components, 5)
+	assert.Len(t, rows[1].(discordgo.ActionsRow).Components, 2)
+
+	b := rows[1].(discordgo.ActionsRow).Components[1].(discordgo.Button)
+	assert.Equal(t, "7. choice 6", b.Label)
+
+	sel, ok := ParseComponent(discordgo.MessageComponentInteractionData{
+		CustomID:      b.CustomID,
+		ComponentType: discordgo.ButtonComponent,
+	})
+	assert.True(t, ok)
+	assert.Equal(t, Selection{VoteID: v.ID, Choices: []int{6}}, sel)
+}
+
+func TestComponentsSelectMenu(t *testing.T) {
+	v := newTestVote(MaxPossibilities)
+
+	rows := v.Components()
+	require.Len(t, rows, 1)
+	menu := rows[0].(discordgo.ActionsRow).Components[0].(discordgo.SelectMenu)
+	require.Len(t, menu.Options, MaxPossibilities)
+
+	sel, ok := ParseComponent(discordgo.MessageComponentInteractionData{
+		CustomID:      menu.CustomID,
+		ComponentType: discordgo.SelectMenuComponent,
+		Values:        []string{menu.Options[12].Value},
+	})
+	assert.True(t, ok)
+	assert.Equal(t, Selection{VoteID: v.ID, Choices: []int{12}}, sel)
+}
+
+func TestComponentsModes(t *testing.T) {
+	v := newTestVote(7)
+	v.Mode = ModeMulti
+	v.MaxChoices = 3
+
+	rows := v.Components()
+	require.Len(t, rows, 1)
+	menu := rows[0].(discordgo.ActionsRow).Components[0].(discordgo.SelectMenu)
+	assert.Equal(t, 3, menu.MaxValues)
+
+	sel, ok := ParseComponent(discordgo.MessageComponentInteractionData{
+		CustomID:      menu.CustomID,
+		ComponentType: discordgo.SelectMenuComponent,
+		Values:        []string{"4", "1"},
+	})
+	assert.True(t, ok)
+	assert.Equal(t, Selection{VoteID: v.ID, Choices: []int{4, 1}}, sel)
+
+	// Ranked votes have a select menu per rank, but
+	// not more than fit into a message.
+	v.Mode = ModeRanked
+	v.MaxChoices = 0
+	rows = v.Components()
+	require.Len(t, rows, maxRanks)
+	menu = rows[2].(discordgo.ActionsRow).Components[0].(discordgo.SelectMenu)
+	assert.Equal(t, 1, menu.MaxValues)
+
+	sel, ok = ParseComponent(discordgo.MessageComponentInteractionData{
+		CustomID:      menu.CustomID,
+		ComponentType: discordgo.SelectMenuComponent,
+		Values:        []string{"5"},
+	})
+	assert.True(t, ok)
+	assert.Equal(t, Selection{VoteID: v.ID, Rank: 3, Choices: []int{5}}, sel)
+}
+
+func TestParseComponentInvalid(t *testing.T) {
+	for _, data := range []discordgo.MessageComponentInteractionData{
+		{CustomID: "confirm-1", ComponentType: discordgo.ButtonComponent},
+		{CustomID: "vote:1", ComponentType: discordgo.ButtonComponent},
+		{CustomID: "vote:1:x", ComponentType: discordgo.ButtonComponent},
+		{CustomID: "vote:1:-1", ComponentType: discordgo.ButtonComponent},
+		{CustomID: "vote::1", ComponentType: discordgo.ButtonComponent},
+		{CustomID: "vote:1", ComponentType: discordgo.SelectMenuComponent},
+		{CustomID: "vote:1:0", ComponentType: discordgo.SelectMenuComponent, Values: []string{"1"}},
+		{CustomID: "vote:1", ComponentType: discordgo.SelectMenuComponent, Values: []string{"1", "x"}},
+	} {
+		_, ok := ParseComponent(data)
+		assert.False(t, ok, data.CustomID)
+	}
+}
+
+func TestApply(t *testing.T) {
+	v := newTestVote(4)
+	v.Ticks = map[string]*Tick{}
+
+	tick, err := v.apply("a", Selection{Choices: []int{2}})
+	require.Nil(t, err)
+	assert.Equal(t, &Tick{UserID: "a", Tick: 2}, tick)
+
+	_, err = v.apply("a", Selection{Choices: []int{4}})
+	assert.ErrorIs(t, err, ErrInvalidSelection)
+	_, err = v.apply("a", Selection{Choices: []int{1, 2}})
+	assert.ErrorIs(t, err, ErrInvalidSelection)
+
+	v.Mode = ModeMulti
+	v.MaxChoices = 2
+	tick, err = v.apply("a", Selection{Choices: []int{3, 1}})
+	require.Nil(t, err)
+	assert.Equal(t, &Tick{UserID: "a", Tick: 1, Choices: []int{1, 3}}, tick)
+	assert.Equal(t, "**choice 1**, **choice 3**", v.FormatTick(tick))
+
+	_, err = v.apply("a", Selection{Choices: []int{0, 1, 2}})
+	assert.ErrorIs(t, err, ErrInvalidSelection)
+	_, err = v.apply("a", Selection{Choices: []int{1, 1}})
+	assert.ErrorIs(t, err, ErrInvalidSelection)
+
+	v.Mode = ModeRanked
+	v.MaxChoices = 3
+	v.Ticks = map[string]*Tick{}
+	tick, err = v.apply("a", Selection{Rank: 2, Choices: []int{3}})
+	require.Nil(t, err)
+	assert.Equal(t, &Tick{UserID: "a", Tick: 3, Choices: []int{-1, 3}}, tick)
+
+	tick, err = v.apply("a", Selection{Rank: 1, Choices: []int{0}})
+	require.Nil(t, err)
+	assert.Equal(t, []int{0, 3}, tick.Choices)
+
+	// Ranking a possibility again moves it.
+	tick, err = v.apply("a", Selection{Rank: 3, Choices: []int{0}})
+	require.Nil(t, err)
+	assert.Equal(t, &Tick{UserID: "a", Tick: 3, Choices: []int{-1, 3, 0}}, tick)
+	assert.Equal(t, []int{3, 0}, tick.Picks())
+	assert.Equal(t, "2. **choice 3**\n3. **choice 0**", v.FormatTick(tick))
+
+	_, err = v.apply("a", Selection{Rank: 4, Choices: []int{1}})
+	assert.ErrorIs(t, err, ErrInvalidSelection)
+	_, err = v.apply("a", Selection{Choices: []int{1}})
+	assert.ErrorIs(t, err, ErrInvalidSelection)
+}
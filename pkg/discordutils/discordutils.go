@@ -46,6 +46,16 @@ func SendEmbedMessageDM(session *discordgo.Session, userID string, embed *discor
 	return
 }
 
+// SendMessageComplexDM sends complex message to user
+func SendMessageComplexDM(session *discordgo.Session, userID string, data *discordgo.MessageSend) (msg *discordgo.Message, err error) {
+	ch, err := session.UserChannelCreate(userID)
+	if err != nil {
+		return
+	}
+	msg, err = session.ChannelMessageSendComplex(ch.ID, data)
+	return
+}
+
 // GetMember returns member from guild
 func GetMember(session *discordgo.Session, guildID, userID string) (*discordgo.Member, error) {
 	member, err := session.State.Member(guildID, userID)
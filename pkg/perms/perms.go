@@ -1,15 +1,122 @@
+// Package perms implements permission rules and their
+// evaluation.
+//
+// A permission is identified by a domain name (DN) of
+// segments separated by dots, i.e. "dm.chat.vote". A rule
+// allows or denies a pattern of DNs:
+//
+//	rule    = ( "+" | "-" ) pattern
+//	pattern = segment *( "." segment )
+//	segment = "*" | 1*( "a"-"z" | "A"-"Z" | "0"-"9" | "_" | "-" )
+//
+// A "*" segment in the middle of a pattern matches exactly
+// one segment, so "dm.*.vote" matches "dm.chat.vote" but
+// not "dm.chat.sub.vote". A trailing "*" matches any number
+// of segments including none, so "dm.*" matches "dm" itself
+// and every permission below it.
+//
+// A needed permission prefixed with "!" is explicit and
+// only matched by rules of exactly that DN, wildcards never
+// apply to it.
+//
+// Rule arrays may also reference named groups of rules as
+// "@group:" followed by the group name, i.e. "@group:moderator".
+// References are expanded before the rules are evaluated and
+// are ignored by the evaluation itself.
+//
+// When multiple rules match, the most specific one decides.
+// A rule matching the DN exactly is more specific than any
+// wildcard rule, wildcard rules are ranked by their number
+// of literal segments. When the most specific rules allow
+// and deny, deny wins.
+//
+// Rules of sources with a different precedence are kept in
+// separate arrays of Tiers instead of being merged into one.
+// The first tier with a rule matching a DN decides it, so a
+// member rule "+dm.chat.*" in an earlier tier than a role
+// rule "-dm.*.vote" allows "dm.chat.vote", although both
+// rules have the same score.
 package perms
 
 import (
 	"strings"
 )
 
-var maxMatch = 2 ^ 10
+// maxMatch is the score of a rule matching the needed
+// permission exactly. Wildcard rules score the number of
+// their literal segments, which is always lower as DNs of
+// maxMatch or more segments are never matched.
+const maxMatch = 1 << 10
 
-// matchPermission checks if the given permission matches the given permission
+// GroupPrefix prefixes references to permission groups.
+const GroupPrefix = "@group:"
+
+// maxGroupName is the maximum length of a group name.
+const maxGroupName = 32
+
+// GroupName returns the name of the group referenced by
+// rule and whether rule is a group reference at all.
+func GroupName(rule string) (string, bool) {
+	return strings.CutPrefix(rule, GroupPrefix)
+}
+
+// ValidGroupName returns true if name consists of 1 to 32
+// lowercase letters, digits, "_" or "-".
+func ValidGroupName(name string) bool {
+	if name == "" || len(name) > maxGroupName {
+		return false
+	}
+
+	for _, r := range name {
+		if !isSegmentRune(r) || r >= 'A' && r <= 'Z' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ValidPattern returns true if pattern is a valid pattern
+// of a rule without the "+" or "-" prefix.
+func ValidPattern(pattern string) bool {
+	if pattern == "" {
+		return false
+	}
+
+	for _, segment := range strings.Split(pattern, ".") {
+		if segment == "*" {
+			continue
+		}
+		if segment == "" {
+			return false
+		}
+		for _, r := range segment {
+			if !isSegmentRune(r) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// Matches returns true if pattern matches the DN.
+func Matches(pattern, dn string) bool {
+	return matchPermission(dn, pattern) >= 0
+}
+
+func isSegmentRune(r rune) bool {
+	return r >= 'a' && r <= 'z' ||
+		r >= 'A' && r <= 'Z' ||
+		r >= '0' && r <= '9' ||
+		r == '_' || r == '-'
+}
+
+// matchPermission returns the score of perm matching the
+// needed permission or -1 if it does not match.
 func matchPermission(neededPerm, perm string) int {
 
-	if neededPerm == "" {
+	if neededPerm == "" || perm == "" {
 		return -1
 	}
 
@@ -28,25 +135,38 @@ func matchPermission(neededPerm, perm string) int {
 		return -1
 	}
 
-	// Check for wildcards now
-	toCheckSegments := strings.Split(neededPerm, ".")
-	assembledPerm := ""
-	for i, segment := range toCheckSegments {
-		if assembledPerm == "" {
-			assembledPerm = segment
-		} else {
-			assembledPerm += "." + segment
+	neededSegments := strings.Split(neededPerm, ".")
+	if len(neededSegments) >= maxMatch {
+		return -1
+	}
+
+	segments := strings.Split(perm, ".")
+	score := 0
+	for i, segment := range segments {
+		if segment == "*" && i == len(segments)-1 {
+			// A trailing wildcard matches all remaining
+			// segments, also when there are none left.
+			return score
 		}
 
-		// check if the perm matches with the assembled perm
-		// including the wildcard
-		if perm == assembledPerm+".*" {
-			return i
+		if i >= len(neededSegments) {
+			return -1
 		}
 
+		switch {
+		case segment == "*":
+		case segment == neededSegments[i]:
+			score++
+		default:
+			return -1
+		}
+	}
+
+	if len(segments) != len(neededSegments) {
+		return -1
 	}
 
-	return -1
+	return score
 
 }
 
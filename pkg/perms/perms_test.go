@@ -1,6 +1,9 @@
 package perms
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestMatchPermission(t *testing.T) {
 	testMatch := func(neededPerm, perm string, exp int) {
@@ -11,12 +14,32 @@ func TestMatchPermission(t *testing.T) {
 	}
 
 	testMatch("foo.bar.baz", "foo.bar.baz", maxMatch)
-	testMatch("foo.bar.baz", "foo.bar.*", 1)
-	testMatch("foo.bar.baz", "foo.*", 0)
+	testMatch("foo.bar.baz", "foo.bar.*", 2)
+	testMatch("foo.bar.baz", "foo.*", 1)
+	testMatch("foo.bar.baz", "*", 0)
+
+	testMatch("foo.bar.baz", "foo.*.baz", 2)
+	testMatch("foo.bar.baz", "*.bar.*", 1)
+	testMatch("foo.bar.baz", "*.*.baz", 1)
+	testMatch("foo.bar.baz", "foo.*.*", 1)
+	testMatch("foo.bar.baz", "foo.*.bar", -1)
+	testMatch("foo.bar.baz.qux", "foo.*.baz", -1)
+	testMatch("foo.bar", "foo.*.bar", -1)
+	testMatch("foo", "foo.*", 1)
+	testMatch("foo.bar", "foo.bar.*", 2)
+	testMatch("foo.bar", "foo.*.*", 1)
+	testMatch("foo", "bar.*", -1)
+	testMatch("foo", "foo.bar.*", -1)
+	testMatch("foo.bar.baz", "foo.bar", -1)
+	testMatch("foo.bar", "foo.bar.baz", -1)
 
 	testMatch("!foo.bar.baz", "foo.bar.baz", maxMatch)
 	testMatch("!foo.bar.baz", "foo.bar.*", -1)
 	testMatch("!foo.bar.baz", "foo.*", -1)
+	testMatch("!foo.bar.baz", "foo.*.baz", -1)
+
+	testMatch("", "foo.*", -1)
+	testMatch("foo", "", -1)
 
 }
 
@@ -31,12 +54,67 @@ func TestCheckPermission(t *testing.T) {
 	testCheck("foo.bar.baz", "+foo.bar.baz", maxMatch, true)
 	testCheck("foo.bar.baz", "-foo.bar.baz", maxMatch, false)
 
-	testCheck("foo.bar.baz", "+foo.bar.*", 1, true)
-	testCheck("foo.bar.baz", "-foo.bar.*", 1, false)
+	testCheck("foo.bar.baz", "+foo.bar.*", 2, true)
+	testCheck("foo.bar.baz", "-foo.bar.*", 2, false)
 
-	testCheck("foo.bar.baz", "+foo.*", 0, true)
-	testCheck("foo.bar.baz", "-foo.*", 0, false)
+	testCheck("foo.bar.baz", "+foo.*", 1, true)
+	testCheck("foo.bar.baz", "-foo.*", 1, false)
+
+	testCheck("foo.bar.baz", "+foo.*.baz", 2, true)
+	testCheck("foo.bar.baz", "-foo.*.baz", 2, false)
 
 	testCheck("foo.bar.baz", "foo.bar.baz", -1, false)
 
 }
+
+func TestMaxMatch(t *testing.T) {
+	// Deep wildcard rules must never tie with exact ones.
+	segments := make([]string, 20)
+	for i := range segments {
+		segments[i] = "s"
+	}
+	dn := strings.Join(segments, ".")
+	wildcard := strings.Join(segments[:len(segments)-1], ".") + ".*"
+
+	if res := matchPermission(dn, wildcard); res >= maxMatch {
+		t.Errorf("wildcard score %d is not below %d", res, maxMatch)
+	}
+}
+
+func TestValidPattern(t *testing.T) {
+	for _, pattern := range []string{"dm", "dm.chat.vote", "dm.*", "dm.*.vote", "*", "dm.guild.config.auto_role-2"} {
+		if !ValidPattern(pattern) {
+			t.Errorf("%q is valid", pattern)
+		}
+	}
+
+	for _, pattern := range []string{"", ".", "dm.", ".dm", "dm..vote", "dm.ch at", "dm.**", "dm.c*", "+dm.chat", "!dm.chat"} {
+		if ValidPattern(pattern) {
+			t.Errorf("%q is invalid", pattern)
+		}
+	}
+}
+
+func TestGroupName(t *testing.T) {
+	if name, ok := GroupName("@group:moderator"); !ok || name != "moderator" {
+		t.Errorf("group name was %q / %t (expected moderator / true)", name, ok)
+	}
+
+	if _, ok := GroupName("+dm.chat.*"); ok {
+		t.Error("rule is no group reference")
+	}
+}
+
+func TestValidGroupName(t *testing.T) {
+	for _, name := range []string{"moderator", "event-host", "tier_2"} {
+		if !ValidGroupName(name) {
+			t.Errorf("%q is valid", name)
+		}
+	}
+
+	for _, name := range []string{"", "Moderator", "event host", "mod.team", strings.Repeat("a", 33)} {
+		if ValidGroupName(name) {
+			t.Errorf("%q is invalid", name)
+		}
+	}
+}
@@ -76,16 +76,40 @@ func (p Array) Merge(newPerms Array, override bool) Array {
 
 // Has checks if the permission array has the given permission
 func (p Array) Has(neededPerm string) bool {
-	match := -1
-	allow := false
+	matches, winner := p.Check(neededPerm)
+	return winner >= 0 && matches[winner].Allow
+}
 
-	for _, perm := range p {
+// Match describes how a single rule of an array matches
+// a needed permission. A Score below 0 means that the
+// rule does not apply, higher scores are more specific.
+type Match struct {
+	Rule  string
+	Score int
+	Allow bool
+}
+
+// Check matches every rule of the array against the given
+// permission and returns the matches in order together with
+// the index of the deciding rule, which is -1 if no rule
+// applies. On equal scores, a denying rule wins over an
+// allowing one and otherwise the first rule wins, so the
+// verdict does not depend on the order of the rules.
+//
+// The array does not know where its rules come from, rules
+// merged into it only replace rules of the same pattern.
+// Rules of sources which take precedence over others are
+// checked as Tiers instead.
+func (p Array) Check(neededPerm string) (matches []Match, winner int) {
+	matches = make([]Match, len(p))
+	winner = -1
+	best := -1
+	for i, perm := range p {
 		m, a := checkPermission(neededPerm, perm)
-		if m > match {
-			allow, match = a, m
+		matches[i] = Match{Rule: perm, Score: m, Allow: a}
+		if m > best || (m >= 0 && m == best && !a && matches[winner].Allow) {
+			best, winner = m, i
 		}
 	}
-
-	return allow
-
+	return
 }
@@ -265,4 +265,42 @@ func TestHas(t *testing.T) {
 	if p.Has("") {
 		t.Error("check failed")
 	}
+
+	// A trailing wildcard also matches the permission it
+	// is appended to.
+	if !(Array{"+dm.*"}).Has("dm") {
+		t.Error("check failed")
+	}
+
+	if (Array{"+dm.*"}).Has("!dm") {
+		t.Error("check failed")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	p := Array{"+foo.*", "-foo.bar.*", "+foo.bar.baz", "-foo.bar.baz"}
+
+	matches, winner := p.Check("foo.bar.baz")
+	if winner != 3 {
+		t.Errorf("winner was %d (expected 3)", winner)
+	}
+	exp := []Match{
+		{"+foo.*", 1, true},
+		{"-foo.bar.*", 2, false},
+		{"+foo.bar.baz", maxMatch, true},
+		{"-foo.bar.baz", maxMatch, false},
+	}
+	for i, m := range matches {
+		if m != exp[i] {
+			t.Errorf("match %d was %+v (expected %+v)", i, m, exp[i])
+		}
+	}
+
+	matches, winner = p.Check("x.y")
+	if winner != -1 {
+		t.Errorf("winner was %d (expected -1)", winner)
+	}
+	if len(matches) != len(p) {
+		t.Errorf("got %d matches (expected %d)", len(matches), len(p))
+	}
 }
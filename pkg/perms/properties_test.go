@@ -0,0 +1,154 @@
+package perms
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+// testDN is a random DN of up to five segments from a small
+// alphabet, so that generated rules often overlap.
+type testDN []string
+
+func (testDN) Generate(r *rand.Rand, size int) reflect.Value {
+	dn := make(testDN, 1+r.Intn(5))
+	for i := range dn {
+		dn[i] = string(rune('a' + r.Intn(3)))
+	}
+	return reflect.ValueOf(dn)
+}
+
+func (d testDN) String() string {
+	return strings.Join(d, ".")
+}
+
+// generalize replaces the segments of the DN selected by mask
+// with wildcards and, if trailing is set, the last segment
+// with a trailing wildcard.
+func (d testDN) generalize(mask uint8, trailing bool) string {
+	segments := make([]string, len(d))
+	for i, s := range d {
+		segments[i] = s
+		if mask&(1<<i) != 0 {
+			segments[i] = "*"
+		}
+	}
+	if trailing {
+		segments[len(segments)-1] = "*"
+	}
+	return strings.Join(segments, ".")
+}
+
+// testRules is a random array of rules generalized from
+// random DNs.
+type testRules Array
+
+func (testRules) Generate(r *rand.Rand, size int) reflect.Value {
+	rules := make(testRules, r.Intn(8))
+	for i := range rules {
+		dn := testDN{}.Generate(r, size).Interface().(testDN)
+		sign := "+"
+		if r.Intn(2) == 0 {
+			sign = "-"
+		}
+		rules[i] = sign + dn.generalize(uint8(r.Intn(32)), r.Intn(3) == 0)
+	}
+	return reflect.ValueOf(rules)
+}
+
+func checkProperty(t *testing.T, f any) {
+	t.Helper()
+	if err := quick.Check(f, &quick.Config{MaxCount: 2000}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPropertyGeneralizationMatches(t *testing.T) {
+	checkProperty(t, func(dn testDN, mask uint8, trailing bool) bool {
+		pattern := dn.generalize(mask, trailing)
+		return ValidPattern(pattern) && Matches(pattern, dn.String())
+	})
+}
+
+func TestPropertyOrderIndependent(t *testing.T) {
+	checkProperty(t, func(rules testRules, dn testDN, seed int64) bool {
+		p := Array(rules)
+		shuffled := make(Array, len(p))
+		copy(shuffled, p)
+		rand.New(rand.NewSource(seed)).Shuffle(len(shuffled), func(i, j int) {
+			shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+		})
+		return p.Has(dn.String()) == shuffled.Has(dn.String())
+	})
+}
+
+func TestPropertyExactRuleDecides(t *testing.T) {
+	checkProperty(t, func(rules testRules, dn testDN) bool {
+		var p Array
+		for _, rule := range rules {
+			if rule[1:] != dn.String() {
+				p = append(p, rule)
+			}
+		}
+		return append(p, "+"+dn.String()).Has(dn.String()) &&
+			!append(p, "-"+dn.String()).Has(dn.String())
+	})
+}
+
+func TestPropertyMoreSpecificWins(t *testing.T) {
+	checkProperty(t, func(dn testDN, mask uint8, extra uint8) bool {
+		// general has all wildcards of specific and
+		// one more.
+		var literals []int
+		for i := range dn {
+			if mask&(1<<i) == 0 {
+				literals = append(literals, i)
+			}
+		}
+		if len(literals) == 0 {
+			return true
+		}
+		bit := uint8(1) << literals[int(extra)%len(literals)]
+
+		specific := dn.generalize(mask, false)
+		general := dn.generalize(mask|bit, false)
+
+		return !(Array{"+" + general, "-" + specific}).Has(dn.String()) &&
+			(Array{"-" + general, "+" + specific}).Has(dn.String())
+	})
+}
+
+func TestPropertyDenyWinsTies(t *testing.T) {
+	checkProperty(t, func(rules testRules, dn testDN, mask uint8, trailing bool) bool {
+		pattern := dn.generalize(mask, trailing)
+		p := append(Array(rules), "+"+pattern, "-"+pattern)
+
+		// The pattern is allowed and denied, so only a more
+		// specific rule can allow the permission.
+		_, winner := p.Check(dn.String())
+		return !p.Has(dn.String()) || p[winner][0] == '+' &&
+			matchPermission(dn.String(), p[winner][1:]) > matchPermission(dn.String(), pattern)
+	})
+}
+
+func TestPropertyExplicit(t *testing.T) {
+	checkProperty(t, func(rules testRules, dn testDN, allow, deny bool) bool {
+		p := Array(rules)
+		if allow {
+			p = append(p, "+"+dn.String())
+		}
+		if deny {
+			p = append(p, "-"+dn.String())
+		}
+
+		var hasAllow, hasDeny bool
+		for _, rule := range p {
+			hasAllow = hasAllow || rule == "+"+dn.String()
+			hasDeny = hasDeny || rule == "-"+dn.String()
+		}
+
+		return p.Has("!"+dn.String()) == (hasAllow && !hasDeny)
+	})
+}
@@ -0,0 +1,37 @@
+package perms
+
+// Tiers are rule arrays ordered by their precedence, the
+// first one takes precedence over all following ones. A
+// permission is decided by the first tier with a rule
+// matching it, the rules of later tiers only apply to
+// permissions no rule of an earlier tier matches.
+type Tiers []Array
+
+// Has checks if the tiers allow the given permission.
+func (t Tiers) Has(neededPerm string) bool {
+	tier, matches, winner := t.Check(neededPerm)
+	return tier >= 0 && matches[winner].Allow
+}
+
+// Check returns the index of the tier deciding the given
+// permission together with the matches of its rules and
+// the index of the deciding rule, see Array.Check. tier is
+// -1 if no rule of any tier applies.
+func (t Tiers) Check(neededPerm string) (tier int, matches []Match, winner int) {
+	for i, a := range t {
+		if matches, winner = a.Check(neededPerm); winner >= 0 {
+			return i, matches, winner
+		}
+	}
+	return -1, nil, -1
+}
+
+// Flatten returns the rules of all tiers in the order of
+// their precedence.
+func (t Tiers) Flatten() Array {
+	var res Array
+	for _, a := range t {
+		res = append(res, a...)
+	}
+	return res
+}
@@ -0,0 +1,62 @@
+package perms
+
+import (
+	"testing"
+)
+
+func TestTiersHas(t *testing.T) {
+	tiers := Tiers{
+		{"+dm.chat.*"},
+		nil,
+		{"-dm.*.vote", "+dm.guild.*"},
+	}
+
+	// The earlier tier decides even though the rule of the
+	// later one is as specific.
+	if !tiers.Has("dm.chat.vote") {
+		t.Error("check failed")
+	}
+
+	if tiers.Has("dm.guild.vote") {
+		t.Error("check failed")
+	}
+
+	if !tiers.Has("dm.guild.config") {
+		t.Error("check failed")
+	}
+
+	if tiers.Has("x.y") {
+		t.Error("check failed")
+	}
+
+	if (Tiers{}).Has("dm.chat.vote") {
+		t.Error("check failed")
+	}
+}
+
+func TestTiersCheck(t *testing.T) {
+	tiers := Tiers{
+		{"+dm.chat.autovoice"},
+		{"+dm.chat.*", "-dm.chat.vote"},
+	}
+
+	tier, matches, winner := tiers.Check("dm.chat.vote")
+	if tier != 1 || winner != 1 {
+		t.Errorf("decided by %d/%d (expected 1/1)", tier, winner)
+	}
+	if len(matches) != 2 || matches[winner].Allow {
+		t.Errorf("unexpected matches %+v", matches)
+	}
+
+	tier, _, winner = tiers.Check("x.y")
+	if tier != -1 || winner != -1 {
+		t.Errorf("decided by %d/%d (expected -1/-1)", tier, winner)
+	}
+}
+
+func TestTiersFlatten(t *testing.T) {
+	tiers := Tiers{{"+a"}, nil, {"-b", "+c"}}
+	if !tiers.Flatten().Equals(Array{"+a", "-b", "+c"}) {
+		t.Errorf("flattened to %v", tiers.Flatten())
+	}
+}
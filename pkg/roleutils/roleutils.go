@@ -2,8 +2,11 @@ package roleutils
 
 import (
 	"errors"
+	"sort"
 
 	"github.com/bwmarrin/discordgo"
+
+	"github.com/zekurio/daemon/pkg/discordutils"
 )
 
 // GetRoleByID returns the role with the given ID
@@ -37,21 +40,25 @@ func Sort(roles []*discordgo.Role, reversed bool) []*discordgo.Role {
 	return roles
 }
 
-// GetSortedMemberRoles returns the guilds roles sorted either ascending or
-// descending. Can also include the @everyone role.
+// GetSortedMemberRoles returns the members roles sorted by their
+// position, the highest role first, or the lowest role first when
+// reversed is true. Can also include the @everyone role.
+//
+// The member and roles are taken from the state cache if
+// possible.
 func GetSortedMemberRoles(session *discordgo.Session, guildID, memberID string, includeEveryone, reversed bool) ([]*discordgo.Role, error) {
-	member, err := session.GuildMember(guildID, memberID)
+	member, err := discordutils.GetMember(session, guildID, memberID)
 	if err != nil {
 		return nil, err
 	}
 
-	roles, err := session.GuildRoles(guildID)
+	guild, err := discordutils.GetGuild(session, guildID)
 	if err != nil {
 		return nil, err
 	}
 
 	rolesMap := make(map[string]*discordgo.Role)
-	for _, r := range roles {
+	for _, r := range guild.Roles {
 		rolesMap[r.ID] = r
 	}
 
@@ -64,23 +71,52 @@ func GetSortedMemberRoles(session *discordgo.Session, guildID, memberID string,
 		}
 	}
 
-	if includeEveryone {
-		mRoles[applied] = rolesMap[guildID]
+	if everyone, ok := rolesMap[guildID]; includeEveryone && ok {
+		mRoles[applied] = everyone
 		applied++
 	}
 
 	mRoles = mRoles[:applied]
 
-	return Sort(mRoles, reversed), nil
+	// The roles of the state are not ordered by their position.
+	sortByPosition(mRoles, reversed)
+
+	return mRoles, nil
 }
 
 // GetSortedGuildRoles returns the guilds roles sorted either ascending or
 // descending.
 func GetSortedGuildRoles(session *discordgo.Session, guildID string, reversed bool) ([]*discordgo.Role, error) {
-	roles, err := session.GuildRoles(guildID)
+	guild, err := discordutils.GetGuild(session, guildID)
 	if err != nil {
 		return nil, err
 	}
 
+	// The roles of the state must not be reordered.
+	roles := make([]*discordgo.Role, len(guild.Roles))
+	copy(roles, guild.Roles)
+
 	return Sort(roles, reversed), nil
 }
+
+// sortByPosition sorts the roles by their position, the highest
+// role first, or the lowest role first when reversed is true. Of
+// roles with the same position, the older one ranks higher, like
+// Discord does it.
+func sortByPosition(roles []*discordgo.Role, reversed bool) {
+	sort.SliceStable(roles, func(i, j int) bool {
+		a, b := roles[i], roles[j]
+		if a.Position == b.Position {
+			return olderThan(a.ID, b.ID) != reversed
+		}
+		return (a.Position > b.Position) != reversed
+	})
+}
+
+// olderThan returns true if the snowflake a is lower than b.
+func olderThan(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
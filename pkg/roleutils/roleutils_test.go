@@ -0,0 +1,56 @@
+package roleutils
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+)
+
+func ids(roles []*discordgo.Role) (res []string) {
+	for _, r := range roles {
+		res = append(res, r.ID)
+	}
+	return
+}
+
+func TestSortByPosition(t *testing.T) {
+	roles := []*discordgo.Role{
+		{ID: "20", Position: 1},
+		{ID: "1", Position: 0},
+		{ID: "30", Position: 3},
+		{ID: "100", Position: 1},
+	}
+
+	sortByPosition(roles, false)
+	assert.Equal(t, []string{"30", "20", "100", "1"}, ids(roles))
+
+	sortByPosition(roles, true)
+	assert.Equal(t, []string{"1", "100", "20", "30"}, ids(roles))
+}
+
+func TestGetSortedMemberRoles(t *testing.T) {
+	s := &discordgo.Session{State: discordgo.NewState()}
+	assert.Nil(t, s.State.GuildAdd(&discordgo.Guild{
+		ID: "1",
+		Roles: []*discordgo.Role{
+			{ID: "1", Position: 0},
+			{ID: "2", Position: 2},
+			{ID: "3", Position: 1},
+			{ID: "4", Position: 3},
+		},
+	}))
+	assert.Nil(t, s.State.MemberAdd(&discordgo.Member{
+		GuildID: "1",
+		User:    &discordgo.User{ID: "40"},
+		Roles:   []string{"3", "4", "2"},
+	}))
+
+	roles, err := GetSortedMemberRoles(s, "1", "40", true, false)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"4", "2", "3", "1"}, ids(roles))
+
+	roles, err = GetSortedMemberRoles(s, "1", "40", false, true)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"3", "2", "4"}, ids(roles))
+}
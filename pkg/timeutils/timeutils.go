@@ -9,3 +9,18 @@ func ParseDuration(s string) (time.Duration, error) {
 	}
 	return d, nil
 }
+
+// ParseTime parses s as a duration from now (i.e. `2h`),
+// an RFC 3339 time or a time in UTC formatted like
+// `2006-01-02 15:04`.
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	if d, err := ParseDuration(s); err == nil {
+		return now.Add(d), nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	return time.Parse("2006-01-02 15:04", s)
+}
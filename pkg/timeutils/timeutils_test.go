@@ -0,0 +1,28 @@
+package timeutils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	got, err := ParseTime("1h30m", now)
+	require.Nil(t, err)
+	assert.Equal(t, now.Add(90*time.Minute), got)
+
+	got, err = ParseTime("2023-05-02T10:00:00+02:00", now)
+	require.Nil(t, err)
+	assert.True(t, got.Equal(time.Date(2023, 5, 2, 8, 0, 0, 0, time.UTC)))
+
+	got, err = ParseTime("2023-05-02 10:00", now)
+	require.Nil(t, err)
+	assert.Equal(t, time.Date(2023, 5, 2, 10, 0, 0, 0, time.UTC), got)
+
+	_, err = ParseTime("tomorrow", now)
+	assert.NotNil(t, err)
+}